@@ -0,0 +1,241 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"time"
+
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// OperationLimits are the limits for the number of nodes or items
+// in a single service call. 0 means that the server has no limit.
+//
+// See Part 5, 6.3.11 OperationLimitsType
+type OperationLimits struct {
+	MaxNodesPerRead                          uint32
+	MaxNodesPerHistoryReadData               uint32
+	MaxNodesPerHistoryReadEvents             uint32
+	MaxNodesPerWrite                         uint32
+	MaxNodesPerHistoryUpdateData             uint32
+	MaxNodesPerHistoryUpdateEvents           uint32
+	MaxNodesPerMethodCall                    uint32
+	MaxNodesPerBrowse                        uint32
+	MaxNodesPerRegisterNodes                 uint32
+	MaxNodesPerTranslateBrowsePathsToNodeIDs uint32
+	MaxNodesPerNodeManagement                uint32
+	MaxMonitoredItemsPerCall                 uint32
+}
+
+// ServerCapabilities describes the capabilities and limits of a server.
+// Values which the server does not provide are left at their zero value
+// which means that there is no limit.
+//
+// See Part 5, 6.3.2 ServerCapabilitiesType
+type ServerCapabilities struct {
+	// ServerProfiles are the URIs of the profiles the server supports.
+	ServerProfiles []string
+
+	// LocaleIDs are the locales the server supports.
+	LocaleIDs []string
+
+	// MinSupportedSampleRate is the minimum sampling interval
+	// of the server.
+	MinSupportedSampleRate time.Duration
+
+	MaxBrowseContinuationPoints  uint16
+	MaxQueryContinuationPoints   uint16
+	MaxHistoryContinuationPoints uint16
+
+	MaxArrayLength      uint32
+	MaxStringLength     uint32
+	MaxByteStringLength uint32
+
+	OperationLimits OperationLimits
+}
+
+// ReadServerCapabilities reads the ServerCapabilities object of the
+// server including the operation limits in a single Read request.
+func (c *Client) ReadServerCapabilities() (*ServerCapabilities, error) {
+	caps := &ServerCapabilities{}
+	lim := &caps.OperationLimits
+
+	type field struct {
+		id  uint32
+		set func(v *ua.Variant)
+	}
+	u16 := func(p *uint16) func(v *ua.Variant) { return func(v *ua.Variant) { *p = uint16(v.Uint()) } }
+	u32 := func(p *uint32) func(v *ua.Variant) { return func(v *ua.Variant) { *p = uint32(v.Uint()) } }
+	strs := func(p *[]string) func(v *ua.Variant) { return func(v *ua.Variant) { *p, _ = v.Value().([]string) } }
+
+	fields := []field{
+		{id.Server_ServerCapabilities_ServerProfileArray, strs(&caps.ServerProfiles)},
+		{id.Server_ServerCapabilities_LocaleIDArray, strs(&caps.LocaleIDs)},
+		{id.Server_ServerCapabilities_MinSupportedSampleRate, func(v *ua.Variant) {
+			caps.MinSupportedSampleRate = time.Duration(v.Float() * float64(time.Millisecond))
+		}},
+		{id.Server_ServerCapabilities_MaxBrowseContinuationPoints, u16(&caps.MaxBrowseContinuationPoints)},
+		{id.Server_ServerCapabilities_MaxQueryContinuationPoints, u16(&caps.MaxQueryContinuationPoints)},
+		{id.Server_ServerCapabilities_MaxHistoryContinuationPoints, u16(&caps.MaxHistoryContinuationPoints)},
+		{id.Server_ServerCapabilities_MaxArrayLength, u32(&caps.MaxArrayLength)},
+		{id.Server_ServerCapabilities_MaxStringLength, u32(&caps.MaxStringLength)},
+		{id.Server_ServerCapabilities_MaxByteStringLength, u32(&caps.MaxByteStringLength)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerRead, u32(&lim.MaxNodesPerRead)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerHistoryReadData, u32(&lim.MaxNodesPerHistoryReadData)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerHistoryReadEvents, u32(&lim.MaxNodesPerHistoryReadEvents)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerWrite, u32(&lim.MaxNodesPerWrite)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerHistoryUpdateData, u32(&lim.MaxNodesPerHistoryUpdateData)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerHistoryUpdateEvents, u32(&lim.MaxNodesPerHistoryUpdateEvents)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerMethodCall, u32(&lim.MaxNodesPerMethodCall)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerBrowse, u32(&lim.MaxNodesPerBrowse)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerRegisterNodes, u32(&lim.MaxNodesPerRegisterNodes)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerTranslateBrowsePathsToNodeIDs, u32(&lim.MaxNodesPerTranslateBrowsePathsToNodeIDs)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxNodesPerNodeManagement, u32(&lim.MaxNodesPerNodeManagement)},
+		{id.Server_ServerCapabilities_OperationLimits_MaxMonitoredItemsPerCall, u32(&lim.MaxMonitoredItemsPerCall)},
+	}
+
+	req := &ua.ReadRequest{
+		NodesToRead: make([]*ua.ReadValueID, len(fields)),
+	}
+	for i, f := range fields {
+		req.NodesToRead[i] = &ua.ReadValueID{NodeID: ua.NewNumericNodeID(0, f.id)}
+	}
+
+	res, err := c.Read(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Results) != len(req.NodesToRead) {
+		return nil, ua.StatusBadUnexpectedError
+	}
+
+	// all capabilities are optional
+	for i, dv := range res.Results {
+		if dv.Status != ua.StatusOK || dv.Value == nil {
+			continue
+		}
+		fields[i].set(dv.Value)
+	}
+
+	return caps, nil
+}
+
+// ServerCapabilities returns the capabilities the client has been tuned
+// with or nil if the client has not read them.
+func (c *Client) ServerCapabilities() *ServerCapabilities {
+	caps, _ := c.caps.Load().(*ServerCapabilities)
+	return caps
+}
+
+// TuneFromServerCapabilities adjusts the client to the capabilities of the
+// server:
+//
+//   - Read, Write and Browse requests with more nodes than the operation
+//     limits allow are split into multiple requests.
+//   - The per service in-flight limits for Browse, Query and HistoryRead
+//     requests are set to the number of continuation points. See
+//     LimitInflightFromServer.
+//   - The sampling interval of monitored items which are created or
+//     modified with Subscription.Monitor and ModifyMonitoredItems is
+//     raised to MinSupportedSampleRate. Negative intervals which select
+//     the publishing interval are not changed.
+//
+// The decoder limit ua.MaxVariantArrayLength is a process-wide setting
+// which is shared by all clients and is therefore not changed. If the
+// server sends longer arrays than the limit allows the application has
+// to raise it before connecting. The difference is only logged.
+//
+// With the AutoTune option the client calls this after connecting.
+func (c *Client) TuneFromServerCapabilities(caps *ServerCapabilities) {
+	if caps == nil {
+		return
+	}
+	c.caps.Store(caps)
+	c.limitInflight(caps)
+
+	if n := int(caps.MaxArrayLength); n > ua.MaxVariantArrayLength {
+		debug.Printf("client: server allows arrays with %d elements but ua.MaxVariantArrayLength is %d", n, ua.MaxVariantArrayLength)
+	}
+}
+
+// autoTune reads the server capabilities and tunes the client if the
+// AutoTune option is set. Errors are not fatal since the capabilities
+// are optional.
+func (c *Client) autoTune() {
+	if !c.cfg.AutoTune {
+		return
+	}
+	caps, err := c.ReadServerCapabilities()
+	if err != nil {
+		debug.Printf("client: auto-tune: cannot read server capabilities: %v", err)
+		return
+	}
+	c.TuneFromServerCapabilities(caps)
+}
+
+// samplingParameters returns p with the sampling interval raised to the
+// minimum sample rate of the server. p is copied if it has to be changed.
+func (c *Client) samplingParameters(p *ua.MonitoringParameters) *ua.MonitoringParameters {
+	caps := c.ServerCapabilities()
+	if p == nil || caps == nil || caps.MinSupportedSampleRate <= 0 {
+		return p
+	}
+	min := float64(caps.MinSupportedSampleRate) / float64(time.Millisecond)
+	if p.SamplingInterval < 0 || p.SamplingInterval >= min {
+		return p
+	}
+	cp := *p
+	cp.SamplingInterval = min
+	return &cp
+}
+
+// operationLimit returns the maximum number of nodes per request
+// for the given limit or 0 if there is no limit.
+func (c *Client) operationLimit(f func(l *OperationLimits) uint32) int {
+	if caps := c.ServerCapabilities(); caps != nil {
+		return int(f(&caps.OperationLimits))
+	}
+	return 0
+}
+
+// chunks splits n items into chunks of at most max items and returns the
+// start and end index of each chunk. A max of 0 means no limit.
+func chunks(n, max int) [][2]int {
+	if max <= 0 || n <= max {
+		return [][2]int{{0, n}}
+	}
+	var c [][2]int
+	for i := 0; i < n; i += max {
+		j := i + max
+		if j > n {
+			j = n
+		}
+		c = append(c, [2]int{i, j})
+	}
+	return c
+}
+
+// appendDiagnosticInfos appends the diagnostic infos of a chunk with n
+// results to the diagnostic infos of the previous chunks with prev results.
+// The server may omit the diagnostic infos of a chunk and the missing
+// entries are filled with empty diagnostic infos so that the index of
+// a diagnostic info still matches the index of its result.
+func appendDiagnosticInfos(infos []*ua.DiagnosticInfo, prev int, chunk []*ua.DiagnosticInfo, n int) []*ua.DiagnosticInfo {
+	if len(infos) == 0 && len(chunk) == 0 {
+		return infos
+	}
+	for len(infos) < prev {
+		infos = append(infos, &ua.DiagnosticInfo{})
+	}
+	if len(chunk) == n {
+		return append(infos, chunk...)
+	}
+	for i := 0; i < n; i++ {
+		infos = append(infos, &ua.DiagnosticInfo{})
+	}
+	return infos
+}
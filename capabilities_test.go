@@ -0,0 +1,236 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		n, max int
+		want   [][2]int
+	}{
+		{"no limit", 5, 0, [][2]int{{0, 5}}},
+		{"empty", 0, 2, [][2]int{{0, 0}}},
+		{"below limit", 2, 3, [][2]int{{0, 2}}},
+		{"at limit", 3, 3, [][2]int{{0, 3}}},
+		{"multiple", 6, 3, [][2]int{{0, 3}, {3, 6}}},
+		{"remainder", 7, 3, [][2]int{{0, 3}, {3, 6}, {6, 7}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verify.Values(t, "", chunks(tt.n, tt.max), tt.want)
+		})
+	}
+}
+
+func TestAppendDiagnosticInfos(t *testing.T) {
+	a, b, c := &ua.DiagnosticInfo{SymbolicID: 1}, &ua.DiagnosticInfo{SymbolicID: 2}, &ua.DiagnosticInfo{SymbolicID: 3}
+	empty := &ua.DiagnosticInfo{}
+
+	tests := []struct {
+		name  string
+		infos []*ua.DiagnosticInfo
+		prev  int
+		chunk []*ua.DiagnosticInfo
+		n     int
+		want  []*ua.DiagnosticInfo
+	}{
+		{"none", nil, 2, nil, 2, nil},
+		{"both", []*ua.DiagnosticInfo{a}, 1, []*ua.DiagnosticInfo{b, c}, 2, []*ua.DiagnosticInfo{a, b, c}},
+		{"previous missing", nil, 2, []*ua.DiagnosticInfo{c}, 1, []*ua.DiagnosticInfo{empty, empty, c}},
+		{"chunk missing", []*ua.DiagnosticInfo{a}, 1, nil, 2, []*ua.DiagnosticInfo{a, empty, empty}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verify.Values(t, "", appendDiagnosticInfos(tt.infos, tt.prev, tt.chunk, tt.n), tt.want)
+		})
+	}
+}
+
+// chunkedClient returns a client with the given operation limits which
+// records the number of nodes of each request.
+func chunkedClient(lim OperationLimits, send fakeChannel) *Client {
+	c := newFakeClient(send)
+	c.caps.Store(&ServerCapabilities{OperationLimits: lim})
+	return c
+}
+
+// diags returns diagnostic infos for the chunk starting at index off
+// if the chunk is the second one. The server omits them otherwise.
+func diags(off, n int) []*ua.DiagnosticInfo {
+	if off != 2 {
+		return nil
+	}
+	d := make([]*ua.DiagnosticInfo, n)
+	for i := range d {
+		d[i] = &ua.DiagnosticInfo{EncodingMask: ua.DiagnosticInfoSymbolicID, SymbolicID: int32(off + i)}
+	}
+	return d
+}
+
+func wantDiags(n int) []*ua.DiagnosticInfo {
+	want := make([]*ua.DiagnosticInfo, n)
+	for i := range want {
+		want[i] = &ua.DiagnosticInfo{}
+	}
+	want[2] = &ua.DiagnosticInfo{EncodingMask: ua.DiagnosticInfoSymbolicID, SymbolicID: 2}
+	want[3] = &ua.DiagnosticInfo{EncodingMask: ua.DiagnosticInfoSymbolicID, SymbolicID: 3}
+	return want
+}
+
+func TestSplitRead(t *testing.T) {
+	var sizes []int
+	c := chunkedClient(OperationLimits{MaxNodesPerRead: 2}, func(req ua.Request, h func(interface{}) error) error {
+		r := req.(*ua.ReadRequest)
+		sizes = append(sizes, len(r.NodesToRead))
+		res := &ua.ReadResponse{}
+		for _, rv := range r.NodesToRead {
+			res.Results = append(res.Results, &ua.DataValue{Value: ua.MustVariant(rv.NodeID.IntID())})
+		}
+		res.DiagnosticInfos = diags(int(r.NodesToRead[0].NodeID.IntID()), len(r.NodesToRead))
+		return h(res)
+	})
+
+	req := &ua.ReadRequest{MaxAge: 100}
+	for i := 0; i < 5; i++ {
+		req.NodesToRead = append(req.NodesToRead, &ua.ReadValueID{NodeID: ua.NewNumericNodeID(0, uint32(i))})
+	}
+	res, err := c.Read(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "sizes", sizes, []int{2, 2, 1})
+	for i, dv := range res.Results {
+		if got, want := dv.Value.Value(), uint32(i); got != want {
+			t.Fatalf("result %d: got %v want %v", i, got, want)
+		}
+	}
+	verify.Values(t, "diagnostics", res.DiagnosticInfos, wantDiags(5))
+}
+
+func TestSplitWrite(t *testing.T) {
+	var sizes []int
+	c := chunkedClient(OperationLimits{MaxNodesPerWrite: 2}, func(req ua.Request, h func(interface{}) error) error {
+		r := req.(*ua.WriteRequest)
+		sizes = append(sizes, len(r.NodesToWrite))
+		res := &ua.WriteResponse{}
+		for _, wv := range r.NodesToWrite {
+			res.Results = append(res.Results, ua.StatusCode(wv.NodeID.IntID()))
+		}
+		res.DiagnosticInfos = diags(int(r.NodesToWrite[0].NodeID.IntID()), len(r.NodesToWrite))
+		return h(res)
+	})
+
+	req := &ua.WriteRequest{}
+	for i := 0; i < 5; i++ {
+		req.NodesToWrite = append(req.NodesToWrite, &ua.WriteValue{NodeID: ua.NewNumericNodeID(0, uint32(i))})
+	}
+	res, err := c.Write(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "sizes", sizes, []int{2, 2, 1})
+	verify.Values(t, "results", res.Results, []ua.StatusCode{0, 1, 2, 3, 4})
+	verify.Values(t, "diagnostics", res.DiagnosticInfos, wantDiags(5))
+}
+
+func TestSplitBrowse(t *testing.T) {
+	var sizes []int
+	c := chunkedClient(OperationLimits{MaxNodesPerBrowse: 3}, func(req ua.Request, h func(interface{}) error) error {
+		r := req.(*ua.BrowseRequest)
+		sizes = append(sizes, len(r.NodesToBrowse))
+		res := &ua.BrowseResponse{}
+		for _, bd := range r.NodesToBrowse {
+			res.Results = append(res.Results, &ua.BrowseResult{StatusCode: ua.StatusCode(bd.NodeID.IntID())})
+		}
+		return h(res)
+	})
+
+	req := &ua.BrowseRequest{}
+	for i := 0; i < 5; i++ {
+		req.NodesToBrowse = append(req.NodesToBrowse, &ua.BrowseDescription{NodeID: ua.NewNumericNodeID(0, uint32(i))})
+	}
+	res, err := c.Browse(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "sizes", sizes, []int{3, 2})
+	for i, r := range res.Results {
+		if got, want := r.StatusCode, ua.StatusCode(i); got != want {
+			t.Fatalf("result %d: got %v want %v", i, got, want)
+		}
+	}
+	if len(res.DiagnosticInfos) != 0 {
+		t.Fatalf("got %d diagnostic infos want 0", len(res.DiagnosticInfos))
+	}
+}
+
+func TestTuneFromServerCapabilities(t *testing.T) {
+	max := ua.MaxVariantArrayLength
+	c := NewClient("opc.tcp://example.com:4840")
+	c.TuneFromServerCapabilities(&ServerCapabilities{MaxArrayLength: uint32(max) + 1, MinSupportedSampleRate: time.Second})
+	if ua.MaxVariantArrayLength != max {
+		t.Fatalf("got ua.MaxVariantArrayLength %d want %d", ua.MaxVariantArrayLength, max)
+	}
+
+	// the publishing interval is not limited by the sample rate
+	setFakeChannel(c, func(req ua.Request, h func(interface{}) error) error {
+		if got, want := req.(*ua.CreateSubscriptionRequest).RequestedPublishingInterval, 100.0; got != want {
+			t.Fatalf("got publishing interval %v want %v", got, want)
+		}
+		return h(&ua.CreateSubscriptionResponse{ResponseHeader: &ua.ResponseHeader{}, SubscriptionID: 1})
+	})
+	sub, err := c.Subscribe(&SubscriptionParameters{Interval: 100 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sampling interval is raised to the sample rate
+	var got []float64
+	setFakeChannel(c, func(req ua.Request, h func(interface{}) error) error {
+		switch r := req.(type) {
+		case *ua.CreateMonitoredItemsRequest:
+			res := &ua.CreateMonitoredItemsResponse{ResponseHeader: &ua.ResponseHeader{}}
+			for _, item := range r.ItemsToCreate {
+				got = append(got, item.RequestedParameters.SamplingInterval)
+				res.Results = append(res.Results, &ua.MonitoredItemCreateResult{})
+			}
+			return h(res)
+		case *ua.ModifyMonitoredItemsRequest:
+			res := &ua.ModifyMonitoredItemsResponse{ResponseHeader: &ua.ResponseHeader{}}
+			for _, item := range r.ItemsToModify {
+				got = append(got, item.RequestedParameters.SamplingInterval)
+				res.Results = append(res.Results, &ua.MonitoredItemModifyResult{})
+			}
+			return h(res)
+		}
+		return ua.StatusBadServiceUnsupported
+	})
+	items := []*ua.MonitoredItemCreateRequest{
+		NewMonitoredItemCreateRequestWithDefaults(ua.NewNumericNodeID(0, 1), ua.AttributeIDValue, 1),
+		NewMonitoredItemCreateRequestWithDefaults(ua.NewNumericNodeID(0, 2), ua.AttributeIDValue, 2),
+		NewMonitoredItemCreateRequestWithDefaults(ua.NewNumericNodeID(0, 3), ua.AttributeIDValue, 3),
+	}
+	items[1].RequestedParameters.SamplingInterval = 2000
+	items[2].RequestedParameters.SamplingInterval = -1
+	if _, err := sub.Monitor(ua.TimestampsToReturnBoth, items...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.ModifyMonitoredItems(ua.TimestampsToReturnBoth, &ua.MonitoredItemModifyRequest{
+		RequestedParameters: &ua.MonitoringParameters{SamplingInterval: 500},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "sampling intervals", got, []float64{1000, 2000, -1, 1000})
+	verify.Values(t, "caller item", items[0].RequestedParameters.SamplingInterval, 0.0)
+	verify.Values(t, "stored item", sub.items[0].MonitoringParameters.SamplingInterval, 1000.0)
+}
@@ -39,6 +39,106 @@ func GetEndpoints(ctx context.Context, endpoint string) ([]*ua.EndpointDescripti
 	return res.Endpoints, nil
 }
 
+// FindServers returns the servers known to the server or discovery server.
+// serverURIs limits the result to the servers with the given application
+// uris. localeIDs selects the preferred locales for the server names.
+// Both filters can be empty.
+//
+// See Part 4, Section 5.4.2
+func FindServers(ctx context.Context, endpoint string, serverURIs, localeIDs []string) ([]*ua.ApplicationDescription, error) {
+	c := NewClient(endpoint, AutoReconnect(false))
+	if err := c.Dial(ctx); err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	res, err := c.FindServers(serverURIs, localeIDs)
+	if err != nil {
+		return nil, err
+	}
+	return res.Servers, nil
+}
+
+// FindServersOnNetwork returns the servers a discovery server has found on
+// the network. capabilities limits the result to the servers which support
+// all of the given capabilities, e.g. "DA" or "HD", and can be empty.
+// The servers are fetched in pages and the query is restarted if the
+// discovery server resets its record ids in the meantime.
+//
+// See Part 4, Section 5.4.3
+func FindServersOnNetwork(ctx context.Context, endpoint string, capabilities []string) ([]*ua.ServerOnNetwork, error) {
+	c := NewClient(endpoint, AutoReconnect(false))
+	if err := c.Dial(ctx); err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.allServersOnNetwork(capabilities, findServersOnNetworkPageSize)
+}
+
+// findServersOnNetworkPageSize is the number of records
+// FindServersOnNetwork requests at once.
+const findServersOnNetworkPageSize = 100
+
+// allServersOnNetwork fetches the servers on the network in pages of
+// pageSize records.
+func (c *Client) allServersOnNetwork(capabilities []string, pageSize uint32) ([]*ua.ServerOnNetwork, error) {
+	var (
+		servers []*ua.ServerOnNetwork
+		next    uint32
+		reset   time.Time
+	)
+	for {
+		res, err := c.FindServersOnNetwork(next, pageSize, capabilities)
+		if err != nil {
+			return nil, err
+		}
+		if next > 0 && !res.LastCounterResetTime.Equal(reset) {
+			// the record ids have changed. start over.
+			servers, next = nil, 0
+			continue
+		}
+		reset = res.LastCounterResetTime
+		servers = append(servers, res.Servers...)
+		if uint32(len(res.Servers)) < pageSize {
+			return servers, nil
+		}
+		next = res.Servers[len(res.Servers)-1].RecordID + 1
+	}
+}
+
+// RegisterServer announces the server to a discovery server. It must be
+// called periodically to keep the registration alive. Discovery servers
+// usually require a secure channel with encryption which can be
+// configured with the options.
+//
+// See Part 4, Section 5.4.5
+func RegisterServer(ctx context.Context, endpoint string, srv *ua.RegisteredServer, opts ...Option) error {
+	c := NewClient(endpoint, append([]Option{AutoReconnect(false)}, opts...)...)
+	if err := c.Dial(ctx); err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.RegisterServer(srv)
+}
+
+// RegisterServer2 announces the server to a discovery server like
+// RegisterServer and additionally passes the mDNS configuration. It returns
+// the results for the discovery configuration. If the discovery server does
+// not support RegisterServer2 the caller should fall back to RegisterServer.
+//
+// See Part 4, Section 5.4.6
+func RegisterServer2(ctx context.Context, endpoint string, srv *ua.RegisteredServer, mdns *ua.MdnsDiscoveryConfiguration, opts ...Option) ([]ua.StatusCode, error) {
+	c := NewClient(endpoint, append([]Option{AutoReconnect(false)}, opts...)...)
+	if err := c.Dial(ctx); err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	res, err := c.RegisterServer2(srv, mdns)
+	if err != nil {
+		return nil, err
+	}
+	return res.ConfigurationResults, nil
+}
+
 // SelectEndpoint returns the endpoint with the highest security level which matches
 // security policy and security mode. policy and mode can be omitted so that
 // only one of them has to match.
@@ -97,6 +197,99 @@ const (
 	Reconnecting
 )
 
+func (s ConnState) String() string {
+	switch s {
+	case Closed:
+		return "Closed"
+	case Connected:
+		return "Connected"
+	case Connecting:
+		return "Connecting"
+	case Disconnected:
+		return "Disconnected"
+	case Reconnecting:
+		return "Reconnecting"
+	default:
+		return fmt.Sprintf("ConnState(%d)", uint8(s))
+	}
+}
+
+// ConnAction is the outcome of an action of the client reconnection logic.
+type ConnAction uint8
+
+const (
+	// NoAction, the notification reports a state change
+	NoAction ConnAction = iota
+	// SecureChannelRecreated, a new secure channel has been established
+	SecureChannelRecreated
+	// SessionRestored, the previous session has been activated on the new secure channel
+	SessionRestored
+	// SessionRecreated, a new session has been created since the previous one could not be restored
+	SessionRecreated
+	// SubscriptionsTransferred, the subscriptions have been transferred to the new session
+	SubscriptionsTransferred
+	// SubscriptionsRecreated, the subscriptions which could not be transferred have been recreated
+	SubscriptionsRecreated
+	// ServerStateChanged, the keep-alive has observed a change of the server state
+	ServerStateChanged
+)
+
+func (a ConnAction) String() string {
+	switch a {
+	case NoAction:
+		return "NoAction"
+	case SecureChannelRecreated:
+		return "SecureChannelRecreated"
+	case SessionRestored:
+		return "SessionRestored"
+	case SessionRecreated:
+		return "SessionRecreated"
+	case SubscriptionsTransferred:
+		return "SubscriptionsTransferred"
+	case SubscriptionsRecreated:
+		return "SubscriptionsRecreated"
+	case ServerStateChanged:
+		return "ServerStateChanged"
+	default:
+		return fmt.Sprintf("ConnAction(%d)", uint8(a))
+	}
+}
+
+// ConnStateChange notifies about a change of the connection state
+// or the outcome of a reconnect action.
+type ConnStateChange struct {
+	// State is the connection state after the change.
+	State ConnState
+
+	// Action is the reconnect action which has completed or
+	// NoAction if the notification reports a state change.
+	Action ConnAction
+
+	// ServerState is the state of the server as reported by the
+	// last keep-alive. It is only set for ServerStateChanged.
+	ServerState ua.ServerState
+
+	// Err is the error which caused the state change or the
+	// action, if any.
+	Err error
+}
+
+// secureChannel is the secure channel of the client.
+// It is implemented by *uasc.SecureChannel.
+type secureChannel interface {
+	Open(ctx context.Context) error
+	Close() error
+	SendRequest(req ua.Request, authToken *ua.NodeID, h func(interface{}) error) error
+	SendRequestWithTimeout(req ua.Request, authToken *ua.NodeID, timeout time.Duration, h func(interface{}) error) error
+	SetInflightLimits(max int, perService map[uint16]int, failFast bool)
+	NewSessionSignature(cert, nonce []byte) ([]byte, string, error)
+	VerifySessionSignature(cert, nonce, signature []byte) error
+	EncryptUserPassword(policyURI, password string, cert, nonce []byte) ([]byte, string, error)
+	NewUserTokenSignature(policyURI string, cert, nonce []byte) ([]byte, string, error)
+}
+
+var _ secureChannel = (*uasc.SecureChannel)(nil)
+
 // Client is a high-level client for an OPC/UA server.
 // It establishes a secure channel and a session.
 type Client struct {
@@ -112,10 +305,18 @@ type Client struct {
 	// conn is the open connection
 	conn *uacp.Conn
 
+	// reverse is the listener for reverse connections. If it is set
+	// the client waits for the server to connect instead of dialing.
+	reverse *uacp.ReverseListener
+
 	// sechan is the open secure channel.
-	sechan    *uasc.SecureChannel
+	sechan    secureChannel
 	sechanErr chan error
 
+	// inflightMu guards the in-flight limits in cfg which can be
+	// changed while a secure channel is created.
+	inflightMu sync.Mutex
+
 	// session is the active session.
 	session atomic.Value // *Session
 
@@ -126,6 +327,11 @@ type Client struct {
 	pendingAcks    []*ua.SubscriptionAcknowledgement
 	pendingAcksMux sync.RWMutex
 
+	// regs is the set of registered node handles which need to be
+	// registered again when the session is recreated.
+	regs  map[*RegisteredNodes]struct{}
+	regMu sync.Mutex
+
 	pausech  chan struct{} // pauses subscription publish loop
 	resumech chan struct{} // resumes subscription publish loop
 	mcancel  func()        // stops subscription publish loop
@@ -133,12 +339,34 @@ type Client struct {
 	// timeout for sending PublishRequests
 	publishTimeout atomic.Value
 
+	// publishLimit is the maximum number of publish requests in flight.
+	// It is lowered when the server has too many publish requests.
+	publishLimit int32
+
 	// state of the client
 	state atomic.Value // ConnState
 
+	// serverState is the server state observed by the keep-alive
+	serverState atomic.Value // ua.ServerState
+
+	// caps are the server capabilities the client is tuned with
+	caps atomic.Value // *ServerCapabilities
+
+	// stateChs are the channels which receive connection state changes.
+	stateChs   []chan<- *ConnStateChange
+	stateChsMu sync.Mutex
+
+	// err is the error after which the client has stopped reconnecting.
+	err   error
+	errMu sync.Mutex
+
 	// monitorOnce ensures only one connection monitor is running
 	monitorOnce sync.Once
 
+	// keepAliveWG waits for the keep-alive monitor which
+	// reports errors on the sechanErr channel.
+	keepAliveWG sync.WaitGroup
+
 	// sessionOnce initializes the session
 	sessionOnce sync.Once
 }
@@ -162,16 +390,35 @@ func NewClient(endpoint string, opts ...Option) *Client {
 		sessionCfg:  sessionCfg,
 		sechanErr:   make(chan error, 1),
 		subs:        make(map[uint32]*Subscription),
+		regs:        make(map[*RegisteredNodes]struct{}),
 		pausech:     make(chan struct{}, 2),
 		resumech:    make(chan struct{}, 2),
 		pendingAcks: []*ua.SubscriptionAcknowledgement{},
 	}
 	c.publishTimeout.Store(uasc.MaxTimeout)
+	c.publishLimit = int32(c.maxPublishRequests())
 	c.pauseSubscriptions()
 	c.state.Store(Closed)
+	c.serverState.Store(ua.ServerStateUnknown)
 	return &c
 }
 
+// NewReverseClient creates a new Client which does not dial the server but
+// waits for the server to open a connection with a ReverseHello message on
+// the listener. This is also true for reconnects. The endpoint is matched
+// against the EndpointURL of the ReverseHello and connections for other
+// endpoints are rejected. If endpoint is empty the client accepts the first
+// server which connects and uses the EndpointURL from its ReverseHello.
+//
+// The listener should not be shared between clients.
+//
+// See Part 6, 7.1.3
+func NewReverseClient(l *uacp.ReverseListener, endpoint string, opts ...Option) *Client {
+	c := NewClient(endpoint, opts...)
+	c.reverse = l
+	return c
+}
+
 // reconnectAction is a list of actions for the client reconnection logic.
 type reconnectAction uint8
 
@@ -192,29 +439,43 @@ func (c *Client) Connect(ctx context.Context) (err error) {
 		return errors.Errorf("already connected")
 	}
 
-	c.state.Store(Connecting)
+	c.setState(Connecting, nil)
 	if err := c.Dial(ctx); err != nil {
+		c.setState(Closed, err)
 		return err
 	}
 	s, err := c.CreateSession(c.sessionCfg)
 	if err != nil {
+		c.setState(Closed, err)
 		_ = c.Close()
 		return err
 	}
 	if err := c.ActivateSession(s); err != nil {
+		c.setState(Closed, err)
 		_ = c.Close()
 		return err
 	}
-	c.state.Store(Connected)
+	c.autoTune()
+	c.setState(Connected, nil)
+	c.setErr(nil)
+	c.startMonitor()
+	return nil
+}
 
+// startMonitor starts the connection monitor, the publish loop and
+// the keep-alive once the client has an active session.
+func (c *Client) startMonitor() {
 	mctx, mcancel := context.WithCancel(context.Background())
 	c.mcancel = mcancel
 	c.monitorOnce.Do(func() {
 		go c.monitor(mctx)
 		go c.monitorSubscriptions(mctx)
+		c.keepAliveWG.Add(1)
+		go func() {
+			defer c.keepAliveWG.Done()
+			c.monitorKeepAlive(mctx)
+		}()
 	})
-
-	return nil
 }
 
 // monitor manages connection alteration
@@ -224,8 +485,41 @@ func (c *Client) monitor(ctx context.Context) {
 	dlog.Printf("start")
 	defer dlog.Printf("done")
 
+	// cause is the error which triggered the reconnection
+	// and reason the error which triggered the current action.
+	var cause, reason error
+
+	// start is the time the connection was lost and attempts
+	// the number of failed attempts to reconnect since then.
+	var (
+		start    time.Time
+		attempts int
+	)
+	policy := defaultReconnectPolicy(c.cfg)
+
+	// backoff counts the failed attempt and waits before the next one.
+	// It returns false if the reconnect policy gives up or the context
+	// is done.
+	backoff := func(err error) bool {
+		attempts++
+		reason = err
+		delay, ok := policy.Retry(attempts, time.Since(start), err)
+		if !ok {
+			dlog.Printf("giving up after %d attempts: %v", attempts, err)
+			cause = &ReconnectError{Attempts: attempts, Elapsed: time.Since(start), Err: err}
+			c.setErr(cause)
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+			return true
+		}
+	}
+
 	defer c.mcancel()
-	defer c.state.Store(Closed)
+	defer func() { c.setState(Closed, cause) }()
 
 	action := none
 	for {
@@ -239,15 +533,26 @@ func (c *Client) monitor(ctx context.Context) {
 				dlog.Print("closed")
 				return
 			}
+			cause, reason = err, err
+			start, attempts = time.Now(), 0
 
 			// tell the handler the connection is disconnected
-			c.state.Store(Disconnected)
+			c.setState(Disconnected, cause)
 			dlog.Print("disconnected")
 
 			if !c.cfg.AutoReconnect {
 				// the connection is closed and should not be restored
 				action = abortReconnect
 				dlog.Print("auto-reconnect disabled")
+				c.setErr(cause)
+				return
+			}
+
+			if _, ok := policy.Retry(0, 0, err); !ok {
+				// the error cannot be fixed by reconnecting
+				dlog.Printf("reconnect policy: not retrying: %v", err)
+				cause = &ReconnectError{Err: err}
+				c.setErr(cause)
 				return
 			}
 
@@ -259,8 +564,10 @@ func (c *Client) monitor(ctx context.Context) {
 				action = createSecureChannel
 
 			case syscall.ECONNREFUSED:
-				// the connection has been refused by the server
-				action = abortReconnect
+				// the connection has been refused by the server,
+				// e.g. since it is restarting. The reconnect policy
+				// has already decided that we should retry.
+				action = createSecureChannel
 
 			default:
 				switch x := err.(type) {
@@ -293,8 +600,6 @@ func (c *Client) monitor(ctx context.Context) {
 				}
 			}
 
-			c.state.Store(Disconnected)
-
 			c.pauseSubscriptions()
 
 			var (
@@ -319,26 +624,29 @@ func (c *Client) monitor(ctx context.Context) {
 						// a reconnection to the server
 
 						// close previous secure channel
-						_ = c.conn.Close()
-						c.sechan.Close()
-						c.sechan = nil
+						if c.conn != nil {
+							_ = c.conn.Close()
+						}
+						if c.sechan != nil {
+							c.sechan.Close()
+							c.sechan = nil
+						}
 
-						c.state.Store(Reconnecting)
+						c.setState(Reconnecting, reason)
 
 						dlog.Printf("trying to recreate secure channel")
 						for {
-							if err := c.Dial(ctx); err != nil {
-								select {
-								case <-ctx.Done():
-									return
-								case <-time.After(c.cfg.ReconnectInterval):
-									dlog.Printf("trying to recreate secure channel")
-									continue
-								}
+							err := c.Dial(ctx)
+							if err == nil {
+								break
+							}
+							if !backoff(err) {
+								return
 							}
-							break
+							dlog.Printf("trying to recreate secure channel")
 						}
 						dlog.Printf("secure channel recreated")
+						c.notifyConnState(SecureChannelRecreated, reason)
 						action = restoreSession
 
 					case restoreSession:
@@ -351,15 +659,20 @@ func (c *Client) monitor(ctx context.Context) {
 						dlog.Printf("trying to restore session")
 						s, err := c.DetachSession()
 						if err != nil {
+							if !backoff(err) {
+								return
+							}
 							action = createSecureChannel
 							continue
 						}
 						if err := c.ActivateSession(s); err != nil {
 							dlog.Printf("restore session failed")
+							reason = err
 							action = recreateSession
 							continue
 						}
 						dlog.Printf("session restored")
+						c.notifyConnState(SessionRestored, reason)
 						action = restoreSubscriptions
 
 					case recreateSession:
@@ -371,14 +684,26 @@ func (c *Client) monitor(ctx context.Context) {
 						s, err := c.CreateSession(c.sessionCfg)
 						if err != nil {
 							dlog.Printf("recreate session failed: %v", err)
+							if !backoff(err) {
+								return
+							}
 							action = createSecureChannel
 							continue
 						}
 						if err := c.ActivateSession(s); err != nil {
 							dlog.Printf("reactivate session failed: %v", err)
+							if !backoff(err) {
+								return
+							}
 							action = createSecureChannel
 							continue
 						}
+						c.notifyConnState(SessionRecreated, reason)
+
+						// registered node ids are only valid within a session
+						if err := c.reregisterNodes(); err != nil {
+							dlog.Printf("reregister nodes failed: %v", err)
+						}
 						action = transferSubscriptions
 
 					case transferSubscriptions:
@@ -400,6 +725,7 @@ func (c *Client) monitor(ctx context.Context) {
 						switch {
 						case err != nil:
 							dlog.Printf("transfer subscriptions failed. Recreating all subscriptions: %v", err)
+							reason = err
 							subsToRepublish = nil
 							subsToRecreate = subIDs
 
@@ -411,6 +737,7 @@ func (c *Client) monitor(ctx context.Context) {
 								switch transferResult.StatusCode {
 								case ua.StatusBadSubscriptionIDInvalid:
 									dlog.Printf("sub %d: transfer subscription failed", subIDs[i])
+									reason = transferResult.StatusCode
 									subsToRecreate = append(subsToRecreate, subIDs[i])
 
 								default:
@@ -419,6 +746,9 @@ func (c *Client) monitor(ctx context.Context) {
 								}
 							}
 						}
+						if len(subsToRepublish) > 0 {
+							c.notifyConnState(SubscriptionsTransferred, cause)
+						}
 
 						action = restoreSubscriptions
 
@@ -431,8 +761,9 @@ func (c *Client) monitor(ctx context.Context) {
 						// populated in the previous step.
 
 						for _, id := range subsToRepublish {
-							if err := c.republishSubscription(id, availableSeqs[id]); err != nil {
+							if err := c.republishSubscription(ctx, id, availableSeqs[id]); err != nil {
 								dlog.Printf("republish of subscription %d failed", id)
+								reason = err
 								subsToRecreate = append(subsToRecreate, id)
 							}
 						}
@@ -444,8 +775,11 @@ func (c *Client) monitor(ctx context.Context) {
 								continue
 							}
 						}
+						if len(subsToRecreate) > 0 {
+							c.notifyConnState(SubscriptionsRecreated, reason)
+						}
 
-						c.state.Store(Connected)
+						c.setState(Connected, nil)
 						action = none
 
 					case abortReconnect:
@@ -454,8 +788,8 @@ func (c *Client) monitor(ctx context.Context) {
 						// non recoverable disconnection
 						// stop the client
 
-						// todo(unknownet): should we store the error?
 						dlog.Printf("reconnection not recoverable")
+						c.setErr(cause)
 						return
 					}
 				}
@@ -466,6 +800,10 @@ func (c *Client) monitor(ctx context.Context) {
 				<-c.sechanErr
 			}
 
+			// the server may accept more publish requests with
+			// the new connection than before
+			atomic.StoreInt32(&c.publishLimit, int32(c.maxPublishRequests()))
+
 			dlog.Printf("resuming subscriptions")
 			c.resumeSubscriptions()
 			dlog.Printf("resumed subscriptions")
@@ -484,18 +822,57 @@ func (c *Client) Dial(ctx context.Context) error {
 	}
 
 	var err error
-	c.conn, err = uacp.Dial(ctx, c.endpointURL)
+	if c.reverse != nil {
+		c.conn, err = c.acceptReverse(ctx)
+	} else {
+		c.conn, err = uacp.Dial(ctx, c.endpointURL)
+	}
 	if err != nil {
 		return err
 	}
 
-	c.sechan, err = uasc.NewSecureChannel(c.endpointURL, c.conn, c.cfg, c.sechanErr)
+	c.inflightMu.Lock()
+	sechan, err := uasc.NewSecureChannel(c.endpointURL, c.conn, c.cfg, c.sechanErr)
+	if err == nil {
+		c.sechan = sechan
+	}
+	c.inflightMu.Unlock()
 	if err != nil {
 		_ = c.conn.Close()
 		return err
 	}
 
-	return c.sechan.Open(ctx)
+	if err := c.sechan.Open(ctx); err != nil {
+		_ = c.conn.Close()
+		c.sechan = nil
+		return err
+	}
+	return nil
+}
+
+// acceptReverse waits for a reverse connection for the endpoint of the
+// client and performs the HEL/ACK handshake on it.
+func (c *Client) acceptReverse(ctx context.Context) (*uacp.Conn, error) {
+	for {
+		conn, rhe, err := c.reverse.Accept(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if c.endpointURL != "" && rhe.EndpointURL != c.endpointURL {
+			debug.Printf("client: reverse connect: rejecting endpoint %s from %s", rhe.EndpointURL, rhe.ServerURI)
+			conn.SendError(ua.StatusBadTCPEndpointURLInvalid)
+			_ = conn.Close()
+			continue
+		}
+		if c.endpointURL == "" {
+			c.endpointURL = rhe.EndpointURL
+		}
+		if err := conn.Handshake(c.endpointURL); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
 }
 
 // Close closes the session and the secure channel.
@@ -505,7 +882,7 @@ func (c *Client) Close() error {
 	// try to close the session but ignore any error
 	// so that we close the underlying channel and connection.
 	c.CloseSession()
-	c.state.Store(Closed)
+	c.setState(Closed, nil)
 	defer close(c.sechanErr)
 	if c.mcancel != nil {
 		c.mcancel()
@@ -514,6 +891,8 @@ func (c *Client) Close() error {
 		c.sechan.Close()
 	}
 
+	// the keep-alive monitor must not send on the closed channel
+	c.keepAliveWG.Wait()
 	return nil
 }
 
@@ -521,6 +900,76 @@ func (c *Client) State() ConnState {
 	return c.state.Load().(ConnState)
 }
 
+// Err returns the error which caused the client to stop reconnecting
+// or nil if the client is connected or has been closed by the caller.
+// If the client has given up because of its reconnect policy the error
+// is a *ReconnectError.
+func (c *Client) Err() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.err
+}
+
+func (c *Client) setErr(err error) {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	c.err = err
+}
+
+// NotifyConnState causes the client to send connection state changes and
+// the outcome of reconnect actions to ch. The client does not block when
+// sending to ch and drops the notification if ch is full. Callers should
+// use a buffered channel.
+func (c *Client) NotifyConnState(ch chan<- *ConnStateChange) {
+	c.stateChsMu.Lock()
+	defer c.stateChsMu.Unlock()
+	c.stateChs = append(c.stateChs, ch)
+}
+
+// StopNotifyConnState stops sending notifications to ch.
+func (c *Client) StopNotifyConnState(ch chan<- *ConnStateChange) {
+	c.stateChsMu.Lock()
+	defer c.stateChsMu.Unlock()
+	for i, x := range c.stateChs {
+		if x == ch {
+			c.stateChs = append(c.stateChs[:i], c.stateChs[i+1:]...)
+			return
+		}
+	}
+}
+
+// setState updates the connection state and notifies the
+// registered channels if the state has changed.
+func (c *Client) setState(s ConnState, err error) {
+	c.stateChsMu.Lock()
+	defer c.stateChsMu.Unlock()
+	if c.state.Load().(ConnState) == s {
+		return
+	}
+	c.state.Store(s)
+	c.sendConnState(&ConnStateChange{State: s, Err: err})
+}
+
+// notifyConnState notifies the registered channels
+// about the outcome of a reconnect action.
+func (c *Client) notifyConnState(a ConnAction, err error) {
+	c.stateChsMu.Lock()
+	defer c.stateChsMu.Unlock()
+	c.sendConnState(&ConnStateChange{State: c.State(), Action: a, Err: err})
+}
+
+// sendConnState sends the notification to the registered
+// channels. The caller must hold the stateChsMu lock.
+func (c *Client) sendConnState(n *ConnStateChange) {
+	for _, ch := range c.stateChs {
+		select {
+		case ch <- n:
+		default:
+			debug.Printf("client: dropping state change %s/%s", n.State, n.Action)
+		}
+	}
+}
+
 // Session returns the active session.
 func (c *Client) Session() *Session {
 	return c.session.Load().(*Session)
@@ -764,6 +1213,34 @@ func (c *Client) sendWithTimeout(req ua.Request, timeout time.Duration, h func(i
 	return c.sechan.SendRequestWithTimeout(req, authToken, timeout, h)
 }
 
+// NamespaceArray returns the namespace URIs of the server.
+// The index of a URI is the namespace index.
+func (c *Client) NamespaceArray() ([]string, error) {
+	v, err := c.Node(ua.NewNumericNodeID(0, id.Server_NamespaceArray)).Value()
+	if err != nil {
+		return nil, err
+	}
+	ns, ok := v.Value().([]string)
+	if !ok {
+		return nil, errors.Errorf("error fetching namespace array. id=%d, type=%T", v.Type(), v.Value())
+	}
+	return ns, nil
+}
+
+// FindNamespace returns the index of the namespace with the given URI.
+func (c *Client) FindNamespace(uri string) (uint16, error) {
+	nsa, err := c.NamespaceArray()
+	if err != nil {
+		return 0, err
+	}
+	for i, ns := range nsa {
+		if ns == uri {
+			return uint16(i), nil
+		}
+	}
+	return 0, errors.Errorf("namespace not found. uri=%s", uri)
+}
+
 // Node returns a node object which accesses its attributes
 // through this client connection.
 func (c *Client) Node(id *ua.NodeID) *Node {
@@ -781,6 +1258,59 @@ func (c *Client) GetEndpoints() (*ua.GetEndpointsResponse, error) {
 	return res, err
 }
 
+// FindServers executes a synchronous FindServers request.
+func (c *Client) FindServers(serverURIs, localeIDs []string) (*ua.FindServersResponse, error) {
+	req := &ua.FindServersRequest{
+		EndpointURL: c.endpointURL,
+		ServerURIs:  serverURIs,
+		LocaleIDs:   localeIDs,
+	}
+	var res *ua.FindServersResponse
+	err := c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// FindServersOnNetwork executes a synchronous FindServersOnNetwork request
+// which returns at most maxRecords servers starting with the record id.
+// A maxRecords of 0 returns all servers.
+func (c *Client) FindServersOnNetwork(startingRecordID, maxRecords uint32, capabilities []string) (*ua.FindServersOnNetworkResponse, error) {
+	req := &ua.FindServersOnNetworkRequest{
+		StartingRecordID:       startingRecordID,
+		MaxRecordsToReturn:     maxRecords,
+		ServerCapabilityFilter: capabilities,
+	}
+	var res *ua.FindServersOnNetworkResponse
+	err := c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// RegisterServer executes a synchronous RegisterServer request.
+func (c *Client) RegisterServer(srv *ua.RegisteredServer) error {
+	req := &ua.RegisterServerRequest{Server: srv}
+	var res *ua.RegisterServerResponse
+	return c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+}
+
+// RegisterServer2 executes a synchronous RegisterServer2 request.
+// mdns can be nil.
+func (c *Client) RegisterServer2(srv *ua.RegisteredServer, mdns *ua.MdnsDiscoveryConfiguration) (*ua.RegisterServer2Response, error) {
+	req := &ua.RegisterServer2Request{Server: srv}
+	if mdns != nil {
+		req.DiscoveryConfiguration = []*ua.ExtensionObject{ua.NewExtensionObject(mdns)}
+	}
+	var res *ua.RegisterServer2Response
+	err := c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
 // Read executes a synchronous read request.
 //
 // By default, the function requests the value of the nodes
@@ -800,12 +1330,31 @@ func (c *Client) Read(req *ua.ReadRequest) (*ua.ReadResponse, error) {
 		}
 		rvs[i] = rc
 	}
-	req = &ua.ReadRequest{
-		MaxAge:             req.MaxAge,
-		TimestampsToReturn: req.TimestampsToReturn,
-		NodesToRead:        rvs,
+
+	// split the request if the server limits the number of nodes
+	var res *ua.ReadResponse
+	max := c.operationLimit(func(l *OperationLimits) uint32 { return l.MaxNodesPerRead })
+	for _, ch := range chunks(len(rvs), max) {
+		r, err := c.read(&ua.ReadRequest{
+			MaxAge:             req.MaxAge,
+			TimestampsToReturn: req.TimestampsToReturn,
+			NodesToRead:        rvs[ch[0]:ch[1]],
+		})
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			res = r
+			continue
+		}
+		res.DiagnosticInfos = appendDiagnosticInfos(res.DiagnosticInfos, len(res.Results), r.DiagnosticInfos, len(r.Results))
+		res.Results = append(res.Results, r.Results...)
 	}
+	return res, nil
+}
 
+// read sends a single read request.
+func (c *Client) read(req *ua.ReadRequest) (*ua.ReadResponse, error) {
 	var res *ua.ReadResponse
 	err := c.Send(req, func(v interface{}) error {
 		return safeAssign(v, &res)
@@ -815,20 +1364,51 @@ func (c *Client) Read(req *ua.ReadRequest) (*ua.ReadResponse, error) {
 
 // Write executes a synchronous write request.
 func (c *Client) Write(req *ua.WriteRequest) (*ua.WriteResponse, error) {
+	max := c.operationLimit(func(l *OperationLimits) uint32 { return l.MaxNodesPerWrite })
 	var res *ua.WriteResponse
-	err := c.Send(req, func(v interface{}) error {
-		return safeAssign(v, &res)
-	})
-	return res, err
+	for _, ch := range chunks(len(req.NodesToWrite), max) {
+		var r *ua.WriteResponse
+		err := c.Send(&ua.WriteRequest{NodesToWrite: req.NodesToWrite[ch[0]:ch[1]]}, func(v interface{}) error {
+			return safeAssign(v, &r)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			res = r
+			continue
+		}
+		res.DiagnosticInfos = appendDiagnosticInfos(res.DiagnosticInfos, len(res.Results), r.DiagnosticInfos, len(r.Results))
+		res.Results = append(res.Results, r.Results...)
+	}
+	return res, nil
 }
 
 // Browse executes a synchronous browse request.
 func (c *Client) Browse(req *ua.BrowseRequest) (*ua.BrowseResponse, error) {
+	max := c.operationLimit(func(l *OperationLimits) uint32 { return l.MaxNodesPerBrowse })
 	var res *ua.BrowseResponse
-	err := c.Send(req, func(v interface{}) error {
-		return safeAssign(v, &res)
-	})
-	return res, err
+	for _, ch := range chunks(len(req.NodesToBrowse), max) {
+		var r *ua.BrowseResponse
+		breq := &ua.BrowseRequest{
+			View:                          req.View,
+			RequestedMaxReferencesPerNode: req.RequestedMaxReferencesPerNode,
+			NodesToBrowse:                 req.NodesToBrowse[ch[0]:ch[1]],
+		}
+		err := c.Send(breq, func(v interface{}) error {
+			return safeAssign(v, &r)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			res = r
+			continue
+		}
+		res.DiagnosticInfos = appendDiagnosticInfos(res.DiagnosticInfos, len(res.Results), r.DiagnosticInfos, len(r.Results))
+		res.Results = append(res.Results, r.Results...)
+	}
+	return res, nil
 }
 
 // Call executes a synchronous call request for a single method.
@@ -858,6 +1438,87 @@ func (c *Client) BrowseNext(req *ua.BrowseNextRequest) (*ua.BrowseNextResponse,
 	return res, err
 }
 
+// QueryFirst executes a synchronous QueryFirst request.
+// Part 4, Section 5.9.3
+func (c *Client) QueryFirst(req *ua.QueryFirstRequest) (*ua.QueryFirstResponse, error) {
+	var res *ua.QueryFirstResponse
+	err := c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// QueryNext executes a synchronous QueryNext request.
+// Part 4, Section 5.9.4
+func (c *Client) QueryNext(req *ua.QueryNextRequest) (*ua.QueryNextResponse, error) {
+	var res *ua.QueryNextResponse
+	err := c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// Query returns all data sets which match the node types and the filter.
+// It follows the continuation points with QueryNext until the server has
+// returned all results. A nil view queries the entire address space.
+//
+// If the server rejects one of the node types or the filter the function
+// returns the status code of the first failed parsing or filter result.
+func (c *Client) Query(view *ua.ViewDescription, nodeTypes []*ua.NodeTypeDescription, filter *ua.ContentFilter) ([]*ua.QueryDataSet, error) {
+	if view == nil {
+		view = &ua.ViewDescription{ViewID: ua.NewTwoByteNodeID(0)}
+	}
+	if filter == nil {
+		filter = &ua.ContentFilter{}
+	}
+	req := &ua.QueryFirstRequest{
+		View:      view,
+		NodeTypes: nodeTypes,
+		Filter:    filter,
+	}
+	res, err := c.QueryFirst(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range res.ParsingResults {
+		if r.StatusCode != ua.StatusOK {
+			c.releaseQuery(res.ContinuationPoint)
+			return nil, r.StatusCode
+		}
+	}
+	if res.FilterResult != nil {
+		for _, r := range res.FilterResult.ElementResults {
+			if r.StatusCode != ua.StatusOK {
+				c.releaseQuery(res.ContinuationPoint)
+				return nil, r.StatusCode
+			}
+		}
+	}
+
+	sets := res.QueryDataSets
+	cp := res.ContinuationPoint
+	for len(cp) > 0 {
+		res, err := c.QueryNext(&ua.QueryNextRequest{ContinuationPoint: cp})
+		if err != nil {
+			c.releaseQuery(cp)
+			return nil, err
+		}
+		sets = append(sets, res.QueryDataSets...)
+		cp = res.RevisedContinuationPoint
+	}
+	return sets, nil
+}
+
+// releaseQuery releases the continuation point of a query which is not
+// continued so that the server can free its resources. Errors are ignored
+// since the server releases it with the session in any case.
+func (c *Client) releaseQuery(cp []byte) {
+	if len(cp) == 0 {
+		return
+	}
+	_, _ = c.QueryNext(&ua.QueryNextRequest{ReleaseContinuationPoint: true, ContinuationPoint: cp})
+}
+
 // RegisterNodes registers node ids for more efficient reads.
 // Part 4, Section 5.8.5
 func (c *Client) RegisterNodes(req *ua.RegisterNodesRequest) (*ua.RegisterNodesResponse, error) {
@@ -909,15 +1570,6 @@ func safeAssign(t, ptrT interface{}) error {
 	return nil
 }
 
-func uint32SliceContains(n uint32, a []uint32) bool {
-	for _, v := range a {
-		if n == v {
-			return true
-		}
-	}
-	return false
-}
-
 type InvalidResponseTypeError struct {
 	got, want interface{}
 }
@@ -3,7 +3,7 @@ package opcua
 import (
 	"context"
 	"io"
-	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/gopcua/opcua/debug"
@@ -52,6 +52,7 @@ func (c *Client) Subscribe(params *SubscriptionParameters, notifyCh chan *Publis
 		Notifs:                    notifyCh,
 		params:                    params,
 		nextSeq:                   1,
+		lastMessage:               time.Now(),
 		c:                         c,
 	}
 
@@ -91,6 +92,17 @@ func (c *Client) recreateSubscription(id uint32) error {
 	return sub.recreate()
 }
 
+// deleteSubscriptions deletes the subscriptions on the server.
+func (c *Client) deleteSubscriptions(ids ...uint32) error {
+	req := &ua.DeleteSubscriptionsRequest{
+		SubscriptionIDs: ids,
+	}
+	var res *ua.DeleteSubscriptionsResponse
+	return c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+}
+
 // transferSubscriptions ask the server to transfer the given subscriptions
 // of the previous session to the current one.
 func (c *Client) transferSubscriptions(ids []uint32) (*ua.TransferSubscriptionsResponse, error) {
@@ -106,10 +118,83 @@ func (c *Client) transferSubscriptions(ids []uint32) (*ua.TransferSubscriptionsR
 	return res, err
 }
 
+// moveSubscriptions moves all subscriptions of the other client to c. If
+// transfer is true the client first asks the server to take over the
+// subscriptions with TransferSubscriptions and recreates only the ones
+// the server did not accept. Otherwise, all subscriptions are recreated.
+func (c *Client) moveSubscriptions(from *Client, transfer bool) error {
+	dlog := debug.NewPrefixLogger("client: move subscriptions: ")
+
+	from.subMux.Lock()
+	subs := from.subs
+	from.subs = make(map[uint32]*Subscription)
+	from.updatePublishTimeout()
+	from.subMux.Unlock()
+
+	if len(subs) == 0 {
+		return nil
+	}
+
+	var ids []uint32
+	for id, sub := range subs {
+		ids = append(ids, id)
+		sub.c = c
+	}
+
+	subsToRecreate := ids
+	if transfer {
+		res, err := c.transferSubscriptions(ids)
+		switch {
+		case err != nil:
+			dlog.Printf("transfer subscriptions failed. Recreating all subscriptions: %v", err)
+
+		default:
+			subsToRecreate = nil
+			for i, r := range res.Results {
+				if r.StatusCode != ua.StatusOK {
+					dlog.Printf("sub %d: transfer subscription failed: %v", ids[i], r.StatusCode)
+					subsToRecreate = append(subsToRecreate, ids[i])
+					continue
+				}
+				subs[ids[i]].lastMessage = time.Now()
+				if err := c.registerSubscription(subs[ids[i]]); err != nil {
+					return err
+				}
+				dlog.Printf("sub %d: transferred", ids[i])
+			}
+		}
+	}
+
+	// the subscriptions of a server which is still running, e.g. with warm
+	// redundancy, must not publish any longer. The subscription ids are
+	// only valid on the previous server.
+	if len(subsToRecreate) > 0 && from.State() == Connected {
+		if err := from.deleteSubscriptions(subsToRecreate...); err != nil {
+			dlog.Printf("delete subscriptions on previous server failed: %v", err)
+		}
+	}
+
+	var firstErr error
+	for _, id := range subsToRecreate {
+		if err := subs[id].create(); err != nil {
+			dlog.Printf("sub %d: recreate failed: %v", id, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	c.subMux.Lock()
+	c.updatePublishTimeout()
+	c.subMux.Unlock()
+	c.resumeSubscriptions()
+	return firstErr
+}
+
 // republishSubscriptions sends republish requests for the given subscription id.
-func (c *Client) republishSubscription(id uint32, availableSeq []uint32) error {
-	c.subMux.RLock()
-	defer c.subMux.RUnlock()
+func (c *Client) republishSubscription(ctx context.Context, id uint32, availableSeq []uint32) error {
+	c.subMux.Lock()
+	defer c.subMux.Unlock()
 
 	sub, ok := c.subs[id]
 	if !ok {
@@ -117,7 +202,7 @@ func (c *Client) republishSubscription(id uint32, availableSeq []uint32) error {
 	}
 
 	debug.Printf("republishing subscription %d", sub.SubscriptionID)
-	if err := c.sendRepublishRequests(sub, availableSeq); err != nil {
+	if err := c.sendRepublishRequests(ctx, sub, availableSeq); err != nil {
 		status, ok := err.(ua.StatusCode)
 		if !ok {
 			return err
@@ -135,70 +220,161 @@ func (c *Client) republishSubscription(id uint32, availableSeq []uint32) error {
 	return nil
 }
 
-// sendRepublishRequests sends republish requests for the given subscription
-// until it gets a BadMessageNotAvailable which implies that there are no
-// more messages to restore.
-func (c *Client) sendRepublishRequests(sub *Subscription, availableSeq []uint32) error {
-	// todo(fs): check if sub.nextSeq is in the available sequence numbers
-	// todo(fs): if not then we need to decide whether we fail b/c of data loss
-	// todo(fs): or whether we log it and continue.
-	if len(availableSeq) > 0 && !uint32SliceContains(sub.nextSeq, availableSeq) {
-		log.Printf("sub %d: next sequence number %d not in retransmission buffer %v", sub.SubscriptionID, sub.nextSeq, availableSeq)
+// sendRepublishRequests republishes the notifications from the
+// retransmission queue of the server which the client has not received.
+// The server reports the available sequence numbers when the subscription
+// is transferred. Missing notifications before them are reported as lost.
+func (c *Client) sendRepublishRequests(ctx context.Context, sub *Subscription, availableSeq []uint32) error {
+	if len(availableSeq) == 0 {
+		return nil
+	}
+	if c.sessionClosed() {
+		debug.Printf("Republishing subscription %d aborted", sub.SubscriptionID)
+		return ua.StatusBadSessionClosed
 	}
 
-	for {
-		req := &ua.RepublishRequest{
-			SubscriptionID:           sub.SubscriptionID,
-			RetransmitSequenceNumber: sub.nextSeq,
+	// the notification after the newest one in the queue
+	end := availableSeq[0]
+	for _, seq := range availableSeq[1:] {
+		if _, after := sequenceGap(end, seq); after {
+			end = seq
 		}
+	}
+	return c.republishGap(ctx, sub, nextSequenceNumber(end))
+}
 
-		debug.Printf("Republishing subscription %d and sequence number %d",
-			req.SubscriptionID,
-			req.RetransmitSequenceNumber,
-		)
+// republishGap recovers the notifications of the subscription from the
+// next expected sequence number up to but not including seq with
+// Republish requests and delivers them in order. Notifications which
+// cannot be recovered are reported as a *DataLossError. It returns the
+// first error other than StatusBadMessageNotAvailable.
+//
+// The caller must hold the subMux lock. The lock is released while the
+// Republish requests are sent. If the subscription has been changed
+// in the meantime the notifications are dropped and
+// errSubscriptionChanged is returned.
+func (c *Client) republishGap(ctx context.Context, sub *Subscription, seq uint32) error {
+	dlog := debug.NewPrefixLogger("sub %d: republish: ", sub.SubscriptionID)
+
+	n, after := sequenceGap(sub.nextSeq, seq)
+	if !after || n == 0 {
+		return nil
+	}
+	dlog.Printf("missing notifs %d to %d", sub.nextSeq, seq-1)
+
+	var (
+		firstErr error
+		lost     *DataLossError
+	)
+	reportLost := func() {
+		if lost == nil {
+			return
+		}
+		dlog.Printf("error: %s", lost)
+		sub.addStats(PublishStats{Lost: uint64(lost.Count)})
+		sub.deliver(ctx, c, nil, &PublishNotificationData{SubscriptionID: sub.SubscriptionID, Error: lost})
+		lost = nil
+	}
 
-		if c.sessionClosed() {
-			debug.Printf("Republishing subscription %d aborted", req.SubscriptionID)
-			return ua.StatusBadSessionClosed
+	// the server does not keep more notifications than that and we
+	// do not want to send an endless number of requests.
+	if n > maxRepublishGap {
+		lost = &DataLossError{
+			SubscriptionID:      sub.SubscriptionID,
+			FirstSequenceNumber: sub.nextSeq,
+			Count:               n - maxRepublishGap,
+			Err:                 ua.StatusBadMessageNotAvailable,
 		}
+		sub.nextSeq = addSequenceNumber(sub.nextSeq, lost.Count)
+		n = maxRepublishGap
+	}
 
-		debug.Printf("RepublishRequest: req=%s", debug.ToJSON(req))
-		var res *ua.RepublishResponse
-		err := c.sechan.SendRequest(req, c.Session().resp.AuthenticationToken, func(v interface{}) error {
-			return safeAssign(v, &res)
-		})
-		debug.Printf("RepublishResponse: res=%s err=%v", debug.ToJSON(res), err)
+	// request the notifications without holding the lock
+	id, first := sub.SubscriptionID, sub.nextSeq
+	msgs := make([]*ua.NotificationMessage, n)
+	errs := make([]error, n)
+	c.subMux.Unlock()
+	for i := uint32(0); i < n && ctx.Err() == nil; i++ {
+		msgs[i], errs[i] = c.republish(id, addSequenceNumber(first, i))
+	}
+	c.subMux.Lock()
 
-		switch {
-		case err == ua.StatusBadMessageNotAvailable:
-			// No more message to restore
-			debug.Printf("Republishing subscription %d OK", req.SubscriptionID)
-			return nil
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.subs[id] != sub || sub.SubscriptionID != id || sub.nextSeq != first {
+		dlog.Printf("subscription changed during republish")
+		return errSubscriptionChanged
+	}
 
-		case err != nil:
-			debug.Printf("Republishing subscription %d failed: %v", req.SubscriptionID, err)
-			return err
+	for i := uint32(0); i < n; i++ {
+		cur := sub.nextSeq
+		sub.nextSeq = nextSequenceNumber(cur)
 
-		default:
-			status := ua.StatusBad
-			if res != nil {
-				status = res.ResponseHeader.ServiceResult
+		msg, err := msgs[i], errs[i]
+		if err != nil {
+			if err != ua.StatusBadMessageNotAvailable && firstErr == nil {
+				firstErr = err
 			}
-
-			if status != ua.StatusOK {
-				debug.Printf("Republishing subscription %d failed: %v", req.SubscriptionID, status)
-				return status
+			if lost != nil && lost.Err != err {
+				reportLost()
 			}
+			if lost == nil {
+				lost = &DataLossError{SubscriptionID: sub.SubscriptionID, FirstSequenceNumber: cur, Err: err}
+			}
+			lost.Count++
+			continue
 		}
-		time.Sleep(time.Second)
+		reportLost()
+
+		dlog.Printf("notif %d recovered", cur)
+		sub.lastSeq = cur
+		sub.addStats(PublishStats{Republished: 1})
+		c.notifySubscription(ctx, sub.SubscriptionID, msg, &ua.SubscriptionAcknowledgement{SubscriptionID: sub.SubscriptionID, SequenceNumber: cur})
+	}
+	reportLost()
+	return firstErr
+}
+
+// republishContinues returns true if the notification after a gap can
+// be delivered although republishGap returned the error.
+func republishContinues(err error) bool {
+	return err != errSubscriptionChanged && err != context.Canceled && err != context.DeadlineExceeded
+}
+
+// republish requests a single notification from the
+// retransmission queue of the server.
+func (c *Client) republish(subID, seq uint32) (*ua.NotificationMessage, error) {
+	req := &ua.RepublishRequest{
+		SubscriptionID:           subID,
+		RetransmitSequenceNumber: seq,
+	}
+
+	debug.Printf("RepublishRequest: req=%s", debug.ToJSON(req))
+	var res *ua.RepublishResponse
+	err := c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	debug.Printf("RepublishResponse: res=%s err=%v", debug.ToJSON(res), err)
+	if err != nil {
+		return nil, err
 	}
+	if status := res.ResponseHeader.ServiceResult; status != ua.StatusOK {
+		return nil, status
+	}
+	return res.NotificationMessage, nil
 }
 
 // registerSubscription register a subscription
 func (c *Client) registerSubscription(sub *Subscription) error {
 	c.subMux.Lock()
 	defer c.subMux.Unlock()
+	return c.addSubscription(sub)
+}
 
+// addSubscription registers a subscription.
+// The caller must hold the subMux lock.
+func (c *Client) addSubscription(sub *Subscription) error {
 	if sub.SubscriptionID == 0 {
 		return ua.StatusBadSubscriptionIDInvalid
 	}
@@ -241,29 +417,26 @@ func (c *Client) notifySubscriptionsOfError(ctx context.Context, subID uint32, e
 		}
 	}
 	for _, sub := range subsToNotify {
-		go func(s *Subscription) {
-			s.notify(ctx, &PublishNotificationData{Error: err})
-		}(sub)
+		if sub != nil {
+			sub.deliver(ctx, c, nil, &PublishNotificationData{Error: err})
+		}
 	}
 }
 
-func (c *Client) notifySubscription(ctx context.Context, subID uint32, notif *ua.NotificationMessage) {
-	// we need to hold the subMux lock already
+// notifySubscription queues the notification data of the message for the
+// subscriber. ack is queued once the message has been stored and the data
+// has been delivered, if it is not nil. See Subscription.deliverMessage.
+//
+// The caller must hold the subMux lock.
+func (c *Client) notifySubscription(ctx context.Context, subID uint32, notif *ua.NotificationMessage, ack *ua.SubscriptionAcknowledgement) {
 	sub, ok := c.subs[subID]
 	if !ok {
 		debug.Printf("Unknown subscription: %v", subID)
 		return
 	}
 
-	// todo(fs): response.Results contains the status codes of which messages were
-	// todo(fs): were successfully removed from the transmission queue on the server.
-	// todo(fs): The client sent the list of ids in the *previous* PublishRequest.
-	// todo(fs): If we want to handle them then we probably need to keep track
-	// todo(fs): of the message ids we have ack'ed.
-	// todo(fs): see discussion in https://github.com/gopcua/opcua/issues/337
-
 	if notif == nil {
-		sub.notify(ctx, &PublishNotificationData{
+		sub.deliver(ctx, c, ack, &PublishNotificationData{
 			SubscriptionID: subID,
 			Error:          errors.Errorf("empty NotificationMessage"),
 		})
@@ -271,36 +444,196 @@ func (c *Client) notifySubscription(ctx context.Context, subID uint32, notif *ua
 	}
 
 	// Part 4, 7.21 NotificationMessage
-	for _, data := range notif.NotificationData {
+	var data []*PublishNotificationData
+	for _, n := range notif.NotificationData {
 		// Part 4, 7.20 NotificationData parameters
-		if data == nil || data.Value == nil {
-			sub.notify(ctx, &PublishNotificationData{
+		if n == nil || n.Value == nil {
+			data = append(data, &PublishNotificationData{
 				SubscriptionID: subID,
 				Error:          errors.Errorf("missing NotificationData parameter"),
 			})
 			continue
 		}
 
-		switch data.Value.(type) {
+		switch v := n.Value.(type) {
 		// Part 4, 7.20.2 DataChangeNotification parameter
 		// Part 4, 7.20.3 EventNotificationList parameter
-		// Part 4, 7.20.4 StatusChangeNotification parameter
 		case *ua.DataChangeNotification,
-			*ua.EventNotificationList,
-			*ua.StatusChangeNotification:
-			sub.notify(ctx, &PublishNotificationData{
+			*ua.EventNotificationList:
+			data = append(data, &PublishNotificationData{
 				SubscriptionID: subID,
-				Value:          data.Value,
+				Value:          n.Value,
 			})
 
+		// Part 4, 7.20.4 StatusChangeNotification parameter
+		case *ua.StatusChangeNotification:
+			data = append(data, c.handleStatusChange(ctx, sub, v))
+
 		// Error
 		default:
-			sub.notify(ctx, &PublishNotificationData{
+			data = append(data, &PublishNotificationData{
 				SubscriptionID: subID,
-				Error:          errors.Errorf("unknown NotificationData parameter: %T", data.Value),
+				Error:          errors.Errorf("unknown NotificationData parameter: %T", n.Value),
 			})
 		}
 	}
+	sub.deliverMessage(ctx, c, notif, ack, data...)
+}
+
+// handleStatusChange returns the status change of the subscription as a
+// *StatusChangeEvent for the subscriber. Subscriptions which the server
+// has closed are recreated and transferred subscriptions are removed from
+// the client.
+//
+// The caller must hold the subMux lock.
+func (c *Client) handleStatusChange(ctx context.Context, sub *Subscription, n *ua.StatusChangeNotification) *PublishNotificationData {
+	dlog := debug.NewPrefixLogger("sub %d: status change: ", sub.SubscriptionID)
+	dlog.Printf("%s", n.Status)
+
+	ev := &PublishNotificationData{
+		SubscriptionID: sub.SubscriptionID,
+		Value: &StatusChangeEvent{
+			SubscriptionID: sub.SubscriptionID,
+			Status:         n.Status,
+			DiagnosticInfo: n.DiagnosticInfo,
+		},
+	}
+
+	switch n.Status {
+	case ua.StatusBadTimeout, ua.StatusBadSubscriptionIDInvalid:
+		if sub.Status() == SubscriptionDead {
+			return ev
+		}
+		sub.setStatus(SubscriptionDead)
+		// recreate needs the subMux lock
+		go c.recoverSubscription(ctx, sub, sub.SubscriptionID)
+
+	case ua.StatusGoodSubscriptionTransferred:
+		dlog.Printf("transferred to another session")
+		sub.setStatus(SubscriptionTransferred)
+		delete(c.subs, sub.SubscriptionID)
+		c.updatePublishTimeout()
+		if len(c.subs) == 0 {
+			c.pauseSubscriptions()
+		}
+	}
+	return ev
+}
+
+// recoverSubscription recreates a subscription which the
+// server has closed and reports the result as a *RecreatedEvent.
+//
+// The subscription stays registered with its previous id until the new
+// subscription has been created so that the publish loop keeps running.
+// Failed attempts are retried with the reconnect policy of the client.
+// Nothing is reported if the subscription has been cancelled or
+// recreated by a reconnect in the meantime.
+func (c *Client) recoverSubscription(ctx context.Context, sub *Subscription, prevID uint32) {
+	dlog := debug.NewPrefixLogger("sub %d: recover: ", prevID)
+
+	policy := defaultReconnectPolicy(c.cfg)
+	start := time.Now()
+	id := prevID
+
+	var err error
+	for attempts := 1; ; attempts++ {
+		err = c.replaceSubscription(sub, id)
+		if err == nil || err == errSubscriptionChanged {
+			break
+		}
+		dlog.Printf("error: %s", err)
+
+		delay, ok := policy.Retry(attempts, time.Since(start), err)
+		if !ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		// the subscription may have been created without its items
+		c.subMux.RLock()
+		id = sub.SubscriptionID
+		c.subMux.RUnlock()
+	}
+	if err == errSubscriptionChanged {
+		dlog.Printf("subscription changed")
+		return
+	}
+	if err == nil {
+		sub.setStatus(SubscriptionActive)
+	}
+
+	c.subMux.RLock()
+	id = sub.SubscriptionID
+	c.subMux.RUnlock()
+
+	sub.deliver(ctx, c, nil, &PublishNotificationData{
+		SubscriptionID: sub.SubscriptionID,
+		Value: &RecreatedEvent{
+			PreviousSubscriptionID: prevID,
+			SubscriptionID:         id,
+			Err:                    err,
+		},
+	})
+}
+
+// replaceSubscription deletes the subscription with the id prevID and
+// creates a new one on the server. The subscription is registered with
+// the new id if it is still registered with prevID. Otherwise, the new
+// subscription is deleted and errSubscriptionChanged is returned.
+func (c *Client) replaceSubscription(sub *Subscription, prevID uint32) error {
+	_ = c.deleteSubscriptions(prevID)
+
+	res, err := sub.createSubscription()
+	if err != nil {
+		return err
+	}
+
+	c.subMux.Lock()
+	if c.subs[prevID] != sub || sub.SubscriptionID != prevID {
+		c.subMux.Unlock()
+		_ = c.deleteSubscriptions(res.SubscriptionID)
+		return errSubscriptionChanged
+	}
+	if _, ok := c.subs[res.SubscriptionID]; ok || res.SubscriptionID == 0 {
+		c.subMux.Unlock()
+		return ua.StatusBadSubscriptionIDInvalid
+	}
+	delete(c.subs, prevID)
+	sub.setCreated(res)
+	c.subs[sub.SubscriptionID] = sub
+	c.updatePublishTimeout()
+	c.subMux.Unlock()
+
+	return sub.createMonitoredItems()
+}
+
+// checkKeepAlives reports a *KeepAliveTimeoutEvent for the subscriptions
+// which have not received a message within their keep-alive timeout.
+// Messages before since, e.g. before the publish loop was resumed, are
+// not considered.
+func (c *Client) checkKeepAlives(ctx context.Context, now, since time.Time) {
+	c.subMux.Lock()
+	defer c.subMux.Unlock()
+
+	for _, sub := range c.subs {
+		last := sub.lastMessage
+		if last.Before(since) {
+			last = since
+		}
+		timeout := sub.keepAliveTimeout()
+		if sub.keepAliveMissed || last.IsZero() || timeout == 0 || now.Sub(last) <= timeout {
+			continue
+		}
+		sub.keepAliveMissed = true
+
+		ev := &KeepAliveTimeoutEvent{SubscriptionID: sub.SubscriptionID, Timeout: timeout, LastMessage: last}
+		debug.Printf("sub %d: no keep-alive since %s (timeout %s)", sub.SubscriptionID, last.Format(time.RFC3339), timeout)
+		sub.deliver(ctx, c, nil, &PublishNotificationData{SubscriptionID: sub.SubscriptionID, Value: ev})
+	}
 }
 
 // pauseSubscriptions suspends the publish loop by signalling the pausech.
@@ -315,62 +648,153 @@ func (c *Client) resumeSubscriptions() {
 	c.resumech <- struct{}{}
 }
 
+// keepAliveCheckInterval is the interval in which the publish
+// loop checks for subscriptions without keep-alive messages.
+const keepAliveCheckInterval = time.Second
+
 // monitorSubscriptions sends publish requests and handles publish responses
-// for all active subscriptions.
+// for all active subscriptions. It keeps up to publishDepth requests in
+// flight.
 func (c *Client) monitorSubscriptions(ctx context.Context) {
 	dlog := debug.NewPrefixLogger("sub: ")
 	defer dlog.Print("done")
 
-publish:
+	var (
+		// done receives the results of the publish requests in flight.
+		// It is large enough that they never block.
+		done     = make(chan error, c.maxPublishRequests())
+		inflight int
+
+		// ready is closed and enables sending when the pipeline is not full.
+		ready = make(chan struct{})
+
+		// the client starts with a paused publish loop
+		paused = true
+
+		// resumed is the time when the publish loop was last resumed
+		resumed time.Time
+
+		// turn is closed when the responses of all publish requests
+		// which have been sent before have been handled.
+		turn = make(chan struct{})
+	)
+	close(ready)
+	close(turn)
+
+	keepAlives := time.NewTicker(keepAliveCheckInterval)
+	defer keepAlives.Stop()
+
 	for {
+		var send <-chan struct{}
+		if !paused && inflight < c.publishDepth() {
+			send = ready
+		}
+
 		select {
 		case <-ctx.Done():
 			dlog.Println("ctx.Done()")
 			return
 
 		case <-c.resumech:
-			dlog.Print("resume")
-			// ignore since not paused
+			if paused {
+				dlog.Print("resume")
+				resumed = time.Now()
+			}
+			paused = false
+
+		case now := <-keepAlives.C:
+			if !paused {
+				c.checkKeepAlives(ctx, now, resumed)
+			}
 
 		case <-c.pausech:
-			dlog.Print("pause")
-			for {
-				select {
-				case <-ctx.Done():
-					dlog.Print("pause: ctx.Done()")
-					return
-
-				case <-c.resumech:
-					dlog.Print("pause: resume")
-					continue publish
-
-				case <-c.pausech:
-					dlog.Print("pause: pause")
-					// ignore since already paused
-				}
+			if !paused {
+				dlog.Print("pause")
 			}
+			paused = true
 
-		default:
-			// send publish request and handle response
-			if err := c.publish(ctx); err != nil {
+		case err := <-done:
+			inflight--
+			if err != nil {
 				dlog.Print("error: ", err.Error())
-				c.pauseSubscriptions()
+				paused = true
 			}
+
+		case <-send:
+			// send publish request and handle response
+			inflight++
+			prev, next := turn, make(chan struct{})
+			turn = next
+			go func() {
+				done <- c.publish(ctx, prev, next)
+			}()
 		}
 	}
 }
 
-// publish sends a publish request and handles the response.
-func (c *Client) publish(ctx context.Context) error {
-	dlog := debug.NewPrefixLogger("publish: ")
+// maxPublishRequests returns the configured maximum
+// number of publish requests in flight.
+func (c *Client) maxPublishRequests() int {
+	if c.cfg.MaxPublishRequests < 1 {
+		return 1
+	}
+	return c.cfg.MaxPublishRequests
+}
 
+// publishDepth returns the number of publish requests to keep in flight.
+// It is one more than the number of subscriptions so that the server
+// always has a request for a subscription which is ready to publish.
+func (c *Client) publishDepth() int {
 	c.subMux.RLock()
-	dlog.Printf("pendingAcks=%s", debug.ToJSON(c.pendingAcks))
+	n := len(c.subs) + 1
 	c.subMux.RUnlock()
 
+	if limit := int(atomic.LoadInt32(&c.publishLimit)); n > limit {
+		n = limit
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// lowerPublishLimit lowers the number of publish requests in flight by one
+// after the server has rejected a request with BadTooManyPublishRequests.
+// It returns false if the limit cannot be lowered any further.
+func (c *Client) lowerPublishLimit() (int, bool) {
+	for {
+		limit := atomic.LoadInt32(&c.publishLimit)
+		depth := int32(c.publishDepth())
+		if depth <= 1 {
+			return 1, false
+		}
+		if atomic.CompareAndSwapInt32(&c.publishLimit, limit, depth-1) {
+			return int(depth - 1), true
+		}
+	}
+}
+
+// publish sends a publish request and handles the response.
+//
+// The server answers the publish requests in the order in which they
+// were received. To deliver the notifications in order, the response is
+// handled after prev is closed which happens when the responses of the
+// previous requests have been handled. next is closed when publish
+// returns.
+func (c *Client) publish(ctx context.Context, prev <-chan struct{}, next chan<- struct{}) error {
+	dlog := debug.NewPrefixLogger("publish: ")
+
 	// send the next publish request
 	// note that res contains data even if an error was returned
-	res, err := c.sendPublishRequest()
+	res, acks, err := c.sendPublishRequest()
+
+	<-prev
+	defer close(next)
+
+	if err != nil {
+		// the acknowledgements may not have reached the server
+		c.queueAcks(acks...)
+	}
 	switch {
 	case err == io.EOF:
 		dlog.Printf("eof: pausing publish loop")
@@ -390,8 +814,11 @@ func (c *Client) publish(ctx context.Context) error {
 		dlog.Printf("error: this should only happen when ACK'ing results: %s", err)
 
 	case err == ua.StatusBadTooManyPublishRequests:
-		// todo(fs): we have sent too many publish requests
-		// todo(fs): we need to slow down
+		// the server does not accept that many publish requests
+		if n, ok := c.lowerPublishLimit(); ok {
+			dlog.Printf("error: lowering publish requests to %d: %s", n, err)
+			break
+		}
 		dlog.Printf("error: sleeping for one second: %s", err)
 		select {
 		case <-ctx.Done():
@@ -412,7 +839,9 @@ func (c *Client) publish(ctx context.Context) error {
 	case err != nil && res != nil:
 		// irrecoverable error
 		// todo(fs): do we need to stop and forget the subscription?
+		c.subMux.RLock()
 		c.notifySubscriptionsOfError(ctx, res.SubscriptionID, err)
+		c.subMux.RUnlock()
 		dlog.Printf("error: %s", err)
 		return err
 
@@ -422,7 +851,7 @@ func (c *Client) publish(ctx context.Context) error {
 
 	default:
 		c.subMux.Lock()
-		c.handleAcks(res.Results)
+		c.handleAcks(acks, res.Results)
 		c.handleNotification(ctx, res)
 		c.subMux.Unlock()
 	}
@@ -430,39 +859,51 @@ func (c *Client) publish(ctx context.Context) error {
 	return nil
 }
 
-func (c *Client) handleAcks(res []ua.StatusCode) {
+// handleAcks handles the results of the acknowledgements which were
+// sent with the publish request. Acknowledgements which failed for
+// other reasons than an unknown subscription or sequence number are
+// sent again with the next publish request.
+//
+// The caller must hold the subMux lock.
+func (c *Client) handleAcks(acks []*ua.SubscriptionAcknowledgement, res []ua.StatusCode) {
 	dlog := debug.NewPrefixLogger("publish: ")
 
-	// we assume that the number of results in the response match
-	// the number of pending acks from the previous PublishRequest.
-	if len(c.pendingAcks) != len(res) {
-		dlog.Printf("error: got %d results for pending ACKs but want %d", len(res), len(c.pendingAcks))
-		c.pendingAcks = []*ua.SubscriptionAcknowledgement{}
+	if len(acks) != len(res) {
+		dlog.Printf("error: got %d results for pending ACKs but want %d", len(res), len(acks))
+		return
 	}
 
 	// find the messages which we have received but which we have not acked.
 	var notAcked []*ua.SubscriptionAcknowledgement
-	for i, ack := range c.pendingAcks {
-		err := res[i]
-		switch err {
+	for i, ack := range acks {
+		status := res[i]
+		sub := c.subs[ack.SubscriptionID]
+		switch status {
 		case ua.StatusOK:
 			// message ack'ed
+			sub.addStats(PublishStats{Acked: 1})
 		case ua.StatusBadSubscriptionIDInvalid:
 			// old subscription id -> skip
-			dlog.Printf("error: subscription id invalid. skipping: %s", err)
+			dlog.Printf("error: subscription id invalid. skipping: %s", status)
 		case ua.StatusBadSequenceNumberUnknown:
 			// server does not have the message in its retransmission queue anymore
-			dlog.Printf("error: notif %d/%d not on server anymore: %s", ack.SubscriptionID, ack.SequenceNumber, err)
+			dlog.Printf("error: notif %d/%d not on server anymore: %s", ack.SubscriptionID, ack.SequenceNumber, status)
+			sub.addStats(PublishStats{Unknown: 1, LastAckError: status})
 		default:
 			// otherwise, we try to ack again
 			notAcked = append(notAcked, ack)
-			dlog.Printf("retrying to ACK notif %d/%d: %s", ack.SubscriptionID, ack.SequenceNumber, err)
+			dlog.Printf("retrying to ACK notif %d/%d: %s", ack.SubscriptionID, ack.SequenceNumber, status)
+			sub.addStats(PublishStats{Retried: 1, LastAckError: status})
 		}
 	}
-	c.pendingAcks = notAcked
+	c.queueAcks(notAcked...)
 	dlog.Printf("notAcked=%v", notAcked)
 }
 
+// handleNotification delivers the notification of the publish response.
+// Missing notifications are recovered with Republish requests before.
+//
+// The caller must hold the subMux lock.
 func (c *Client) handleNotification(ctx context.Context, res *ua.PublishResponse) {
 	dlog := debug.NewPrefixLogger("publish: sub %d: ", res.SubscriptionID)
 
@@ -473,39 +914,79 @@ func (c *Client) handleNotification(ctx context.Context, res *ua.PublishResponse
 		return
 	}
 
-	// keep-alive message
-	if len(res.NotificationMessage.NotificationData) == 0 {
-		// todo(fs): do we care about the next sequence number?
-		s.nextSeq = res.NotificationMessage.SequenceNumber
+	s.lastMessage = time.Now()
+	s.keepAliveMissed = false
+
+	msg := res.NotificationMessage
+	if msg == nil {
+		c.notifySubscription(ctx, res.SubscriptionID, nil, nil)
+		return
+	}
+	seq := msg.SequenceNumber
+
+	// A keep-alive message contains the sequence number of the next
+	// notification. Hence, the notifications before were missed.
+	if len(msg.NotificationData) == 0 {
+		if err := c.republishGap(ctx, s, seq); err != nil {
+			dlog.Printf("error: republish failed: %s", err)
+			if !republishContinues(err) {
+				return
+			}
+		}
+		s.nextSeq = seq
 		return
 	}
 
-	if res.NotificationMessage.SequenceNumber != s.nextSeq {
-		dlog.Printf("error: got notif %d but was expecting notif %d. Data loss?", res.NotificationMessage.SequenceNumber, s.nextSeq)
+	if _, after := sequenceGap(s.nextSeq, seq); !after {
+		// the notification has been republished and acknowledged already
+		dlog.Printf("error: got notif %d but was expecting notif %d. Duplicate?", seq, s.nextSeq)
+		return
+	}
+	if err := c.republishGap(ctx, s, seq); err != nil {
+		dlog.Printf("error: republish failed: %s", err)
+		if !republishContinues(err) {
+			return
+		}
 	}
+	s.lastSeq = seq
+	s.nextSeq = nextSequenceNumber(seq)
 
-	s.lastSeq = res.NotificationMessage.SequenceNumber
-	s.nextSeq = s.lastSeq + 1
-	c.pendingAcks = append(c.pendingAcks, &ua.SubscriptionAcknowledgement{
+	// store and acknowledge the notification when it is delivered to the
+	// subscriber so that a subscriber which blocks also delays the
+	// acknowledgement and the server keeps the notification.
+	c.notifySubscription(ctx, res.SubscriptionID, msg, &ua.SubscriptionAcknowledgement{
 		SubscriptionID: res.SubscriptionID,
-		SequenceNumber: res.NotificationMessage.SequenceNumber,
+		SequenceNumber: seq,
 	})
+	dlog.Printf("notif: %d", seq)
+}
 
-	s.c.notifySubscription(ctx, res.SubscriptionID, res.NotificationMessage)
-	dlog.Printf("notif: %d", res.NotificationMessage.SequenceNumber)
+// queueAcks adds acknowledgements for the next publish request.
+func (c *Client) queueAcks(acks ...*ua.SubscriptionAcknowledgement) {
+	if len(acks) == 0 {
+		return
+	}
+	c.pendingAcksMux.Lock()
+	c.pendingAcks = append(c.pendingAcks, acks...)
+	c.pendingAcksMux.Unlock()
 }
 
-func (c *Client) sendPublishRequest() (*ua.PublishResponse, error) {
+// sendPublishRequest sends a publish request with the pending
+// acknowledgements and returns them for handling the results.
+func (c *Client) sendPublishRequest() (*ua.PublishResponse, []*ua.SubscriptionAcknowledgement, error) {
 	dlog := debug.NewPrefixLogger("publish: ")
 
-	c.subMux.RLock()
+	c.pendingAcksMux.Lock()
+	acks := c.pendingAcks
+	c.pendingAcks = []*ua.SubscriptionAcknowledgement{}
+	c.pendingAcksMux.Unlock()
+
 	req := &ua.PublishRequest{
-		SubscriptionAcknowledgements: c.pendingAcks,
+		SubscriptionAcknowledgements: acks,
 	}
 	if req.SubscriptionAcknowledgements == nil {
 		req.SubscriptionAcknowledgements = []*ua.SubscriptionAcknowledgement{}
 	}
-	c.subMux.RUnlock()
 
 	dlog.Printf("PublishRequest: %s", debug.ToJSON(req))
 	var res *ua.PublishResponse
@@ -513,5 +994,5 @@ func (c *Client) sendPublishRequest() (*ua.PublishResponse, error) {
 		return safeAssign(v, &res)
 	})
 	dlog.Printf("PublishResponse: %s", debug.ToJSON(res))
-	return res, err
+	return res, acks, err
 }
@@ -1,11 +1,62 @@
 package opcua
 
 import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
 	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacp"
 	"github.com/pascaldekloe/goe/verify"
-	"testing"
 )
 
+// fakeChannel is a secure channel which passes the requests to the
+// function instead of sending them to a server.
+type fakeChannel func(req ua.Request, h func(interface{}) error) error
+
+func (f fakeChannel) Open(context.Context) error { return nil }
+func (f fakeChannel) Close() error               { return nil }
+
+func (f fakeChannel) SendRequest(req ua.Request, _ *ua.NodeID, h func(interface{}) error) error {
+	return f(req, h)
+}
+
+func (f fakeChannel) SendRequestWithTimeout(req ua.Request, _ *ua.NodeID, _ time.Duration, h func(interface{}) error) error {
+	return f(req, h)
+}
+
+func (f fakeChannel) SetInflightLimits(int, map[uint16]int, bool) {}
+
+func (f fakeChannel) NewSessionSignature(cert, nonce []byte) ([]byte, string, error) {
+	return nil, "", nil
+}
+
+func (f fakeChannel) VerifySessionSignature(cert, nonce, signature []byte) error { return nil }
+
+func (f fakeChannel) EncryptUserPassword(policyURI, password string, cert, nonce []byte) ([]byte, string, error) {
+	return []byte(password), "", nil
+}
+
+func (f fakeChannel) NewUserTokenSignature(policyURI string, cert, nonce []byte) ([]byte, string, error) {
+	return nil, "", nil
+}
+
+// newFakeClient returns a client whose requests are answered by send
+// instead of a server.
+func newFakeClient(send fakeChannel, opts ...Option) *Client {
+	c := NewClient("opc.tcp://example.com:4840", opts...)
+	setFakeChannel(c, send)
+	return c
+}
+
+// setFakeChannel replaces the secure channel of the client with send.
+// The client has no session.
+func setFakeChannel(c *Client, send fakeChannel) {
+	c.session.Store((*Session)(nil))
+	c.sechan = send
+}
+
 func TestClient_Send_DoesNotPanicWhenDisconnected(t *testing.T) {
 	c := NewClient("opc.tcp://example.com:4840")
 	err := c.Send(&ua.ReadRequest{}, func(i interface{}) error {
@@ -13,3 +64,307 @@ func TestClient_Send_DoesNotPanicWhenDisconnected(t *testing.T) {
 	})
 	verify.Values(t, "", err, ua.StatusBadServerNotConnected)
 }
+
+func TestClient_NotifyConnState(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	ch := make(chan *ConnStateChange, 4)
+	c.NotifyConnState(ch)
+
+	c.setState(Connecting, nil)
+	c.setState(Connecting, nil)
+	c.notifyConnState(SessionRestored, ua.StatusBadTimeout)
+	c.StopNotifyConnState(ch)
+	c.setState(Connected, nil)
+	close(ch)
+
+	var got []*ConnStateChange
+	for n := range ch {
+		got = append(got, n)
+	}
+	want := []*ConnStateChange{
+		{State: Connecting},
+		{State: Connecting, Action: SessionRestored, Err: ua.StatusBadTimeout},
+	}
+	verify.Values(t, "", got, want)
+}
+
+// queryClient returns a client whose server returns one page of data sets
+// per QueryFirst or QueryNext request. The QueryNext request for the page
+// with index failAt fails. The QueryNext requests are recorded.
+func queryClient(t *testing.T, pages int, failAt int, first *ua.QueryFirstResponse, next *[]*ua.QueryNextRequest) *Client {
+	page := func(i int) ([]*ua.QueryDataSet, []byte) {
+		sets := []*ua.QueryDataSet{{NodeID: &ua.ExpandedNodeID{NodeID: ua.NewNumericNodeID(0, uint32(i))}}}
+		if i == pages-1 {
+			return sets, nil
+		}
+		return sets, []byte{byte(i + 1)}
+	}
+
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		switch r := req.(type) {
+		case *ua.QueryFirstRequest:
+			res := first
+			res.QueryDataSets, res.ContinuationPoint = page(0)
+			return h(res)
+		case *ua.QueryNextRequest:
+			*next = append(*next, r)
+			if r.ReleaseContinuationPoint {
+				return h(&ua.QueryNextResponse{})
+			}
+			i := int(r.ContinuationPoint[0])
+			if i == failAt {
+				return ua.StatusBadTimeout
+			}
+			res := &ua.QueryNextResponse{}
+			res.QueryDataSets, res.RevisedContinuationPoint = page(i)
+			return h(res)
+		default:
+			t.Fatalf("unexpected request %T", req)
+			return nil
+		}
+	})
+	return c
+}
+
+func TestQuery(t *testing.T) {
+	var next []*ua.QueryNextRequest
+	c := queryClient(t, 3, -1, &ua.QueryFirstResponse{}, &next)
+
+	sets, err := c.Query(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint32
+	for _, s := range sets {
+		ids = append(ids, s.NodeID.NodeID.IntID())
+	}
+	verify.Values(t, "data sets", ids, []uint32{0, 1, 2})
+	verify.Values(t, "query next", next, []*ua.QueryNextRequest{
+		{ContinuationPoint: []byte{1}},
+		{ContinuationPoint: []byte{2}},
+	})
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  *ua.QueryFirstResponse
+		failAt int
+		err    error
+		next   []*ua.QueryNextRequest
+	}{
+		{
+			name:   "query next fails",
+			first:  &ua.QueryFirstResponse{},
+			failAt: 2,
+			err:    ua.StatusBadTimeout,
+			next: []*ua.QueryNextRequest{
+				{ContinuationPoint: []byte{1}},
+				{ContinuationPoint: []byte{2}},
+				{ReleaseContinuationPoint: true, ContinuationPoint: []byte{2}},
+			},
+		},
+		{
+			name: "parsing result",
+			first: &ua.QueryFirstResponse{
+				ParsingResults: []*ua.ParsingResult{{StatusCode: ua.StatusOK}, {StatusCode: ua.StatusBadNodeIDUnknown}},
+			},
+			failAt: -1,
+			err:    ua.StatusBadNodeIDUnknown,
+			next:   []*ua.QueryNextRequest{{ReleaseContinuationPoint: true, ContinuationPoint: []byte{1}}},
+		},
+		{
+			name: "filter result",
+			first: &ua.QueryFirstResponse{
+				FilterResult: &ua.ContentFilterResult{
+					ElementResults: []*ua.ContentFilterElementResult{{StatusCode: ua.StatusBadFilterOperandInvalid}},
+				},
+			},
+			failAt: -1,
+			err:    ua.StatusBadFilterOperandInvalid,
+			next:   []*ua.QueryNextRequest{{ReleaseContinuationPoint: true, ContinuationPoint: []byte{1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var next []*ua.QueryNextRequest
+			c := queryClient(t, 3, tt.failAt, tt.first, &next)
+			sets, err := c.Query(nil, nil, nil)
+			verify.Values(t, "err", err, tt.err)
+			verify.Values(t, "data sets", len(sets), 0)
+			verify.Values(t, "query next", next, tt.next)
+		})
+	}
+}
+
+func TestQueryFirstNext(t *testing.T) {
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		// the server answers with the wrong response type
+		return h(&ua.ReadResponse{})
+	})
+	if _, err := c.QueryFirst(&ua.QueryFirstRequest{}); err == nil {
+		t.Fatal("QueryFirst: got nil want error")
+	}
+	if _, err := c.QueryNext(&ua.QueryNextRequest{}); err == nil {
+		t.Fatal("QueryNext: got nil want error")
+	}
+
+	setFakeChannel(c, func(req ua.Request, h func(interface{}) error) error {
+		return ua.StatusBadServiceUnsupported
+	})
+	if _, err := c.QueryFirst(&ua.QueryFirstRequest{}); err != ua.StatusBadServiceUnsupported {
+		t.Fatalf("QueryFirst: got %v want %v", err, ua.StatusBadServiceUnsupported)
+	}
+	if _, err := c.Query(nil, nil, nil); err != ua.StatusBadServiceUnsupported {
+		t.Fatalf("Query: got %v want %v", err, ua.StatusBadServiceUnsupported)
+	}
+}
+
+func TestAllServersOnNetwork(t *testing.T) {
+	reset := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// records returns the servers with the record ids
+	records := func(ids ...uint32) []*ua.ServerOnNetwork {
+		var s []*ua.ServerOnNetwork
+		for _, id := range ids {
+			s = append(s, &ua.ServerOnNetwork{RecordID: id, ServerName: fmt.Sprintf("server%d", id)})
+		}
+		return s
+	}
+
+	tests := []struct {
+		name  string
+		pages []*ua.FindServersOnNetworkResponse
+		start []uint32
+		want  []*ua.ServerOnNetwork
+	}{
+		{
+			name: "single page",
+			pages: []*ua.FindServersOnNetworkResponse{
+				{LastCounterResetTime: reset, Servers: records(1)},
+			},
+			start: []uint32{0},
+			want:  records(1),
+		},
+		{
+			name: "pages",
+			pages: []*ua.FindServersOnNetworkResponse{
+				{LastCounterResetTime: reset, Servers: records(1, 4)},
+				{LastCounterResetTime: reset, Servers: records(5, 7)},
+				{LastCounterResetTime: reset},
+			},
+			start: []uint32{0, 5, 8},
+			want:  records(1, 4, 5, 7),
+		},
+		{
+			name: "counter reset",
+			pages: []*ua.FindServersOnNetworkResponse{
+				{LastCounterResetTime: reset, Servers: records(1, 2)},
+				{LastCounterResetTime: reset.Add(time.Minute), Servers: records(3)},
+				{LastCounterResetTime: reset.Add(time.Minute), Servers: records(1, 2)},
+				{LastCounterResetTime: reset.Add(time.Minute), Servers: records(3)},
+			},
+			start: []uint32{0, 3, 0, 3},
+			want:  records(1, 2, 3),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var start []uint32
+			c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+				r := req.(*ua.FindServersOnNetworkRequest)
+				verify.Values(t, "max records", r.MaxRecordsToReturn, uint32(2))
+				verify.Values(t, "capabilities", r.ServerCapabilityFilter, []string{"DA"})
+				start = append(start, r.StartingRecordID)
+				res := tt.pages[0]
+				tt.pages = tt.pages[1:]
+				return h(res)
+			})
+
+			servers, err := c.allServersOnNetwork([]string{"DA"}, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "servers", servers, tt.want)
+			verify.Values(t, "starting record ids", start, tt.start)
+		})
+	}
+}
+
+func TestAllServersOnNetworkError(t *testing.T) {
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		if req.(*ua.FindServersOnNetworkRequest).StartingRecordID > 0 {
+			return ua.StatusBadServiceUnsupported
+		}
+		return h(&ua.FindServersOnNetworkResponse{Servers: []*ua.ServerOnNetwork{{RecordID: 1}, {RecordID: 2}}})
+	})
+	if _, err := c.allServersOnNetwork(nil, 2); err != ua.StatusBadServiceUnsupported {
+		t.Fatalf("got %v want %v", err, ua.StatusBadServiceUnsupported)
+	}
+}
+
+func TestAcceptReverse(t *testing.T) {
+	ln, err := uacp.ListenReverse("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	const ep = "opc.tcp://example.com:4840"
+	c := NewReverseClient(ln, ep)
+
+	// dial connects a server with the endpoint and returns the result
+	// of the handshake.
+	dial := func(endpoint string) chan error {
+		errc := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			conn, err := uacp.DialReverse(ctx, ln.Addr().String(), "urn:server", endpoint, nil)
+			if err == nil {
+				conn.Close()
+			}
+			errc <- err
+		}()
+		return errc
+	}
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := c.acceptReverse(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("got error %v want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	// the listener accepts connections again after a cancelled call and
+	// rejects servers with another endpoint
+	t.Run("endpoint", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		rejected := dial("opc.tcp://other.example.com:4840")
+		type result struct {
+			conn *uacp.Conn
+			err  error
+		}
+		resc := make(chan result, 1)
+		go func() {
+			conn, err := c.acceptReverse(ctx)
+			resc <- result{conn, err}
+		}()
+		if err := <-rejected; err == nil {
+			t.Fatal("got nil want error for rejected endpoint")
+		}
+		accepted := dial(ep)
+
+		res := <-resc
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		defer res.conn.Close()
+		if err := <-accepted; err != nil {
+			t.Fatal(err)
+		}
+	})
+}
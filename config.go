@@ -91,6 +91,25 @@ func ReconnectInterval(d time.Duration) Option {
 	}
 }
 
+// ReconnectPolicy sets the policy which decides whether and when
+// the client retries to reconnect. See ExponentialBackoff.
+func ReconnectPolicy(p uasc.ReconnectPolicy) Option {
+	return func(c *uasc.Config, sc *uasc.SessionConfig) {
+		c.ReconnectPolicy = p
+	}
+}
+
+// KeepAlive enables the keep-alive of an idle connection. The client reads
+// the server state every interval and reconnects after the given number of
+// consecutive failed reads. A misses value of 0 defaults to
+// DefaultKeepAliveMaxMisses.
+func KeepAlive(interval time.Duration, misses int) Option {
+	return func(c *uasc.Config, sc *uasc.SessionConfig) {
+		c.KeepAliveInterval = interval
+		c.KeepAliveMaxMisses = misses
+	}
+}
+
 // Lifetime sets the lifetime of the secure channel in milliseconds.
 func Lifetime(d time.Duration) Option {
 	return func(c *uasc.Config, sc *uasc.SessionConfig) {
@@ -426,6 +445,46 @@ func AuthIssuedToken(tokenData []byte) Option {
 	}
 }
 
+// AutoTune makes the client read the server capabilities after it
+// has connected and adjust itself to the limits of the server.
+// See Client.TuneFromServerCapabilities for details.
+func AutoTune(b bool) Option {
+	return func(c *uasc.Config, sc *uasc.SessionConfig) {
+		c.AutoTune = b
+	}
+}
+
+// PublishRequests sets the maximum number of publish requests in flight.
+// More publish requests allow the server to send the notifications of
+// many subscriptions or over a link with a high latency without delay.
+func PublishRequests(max int) Option {
+	return func(c *uasc.Config, sc *uasc.SessionConfig) {
+		c.MaxPublishRequests = max
+	}
+}
+
+// InflightLimit limits the number of requests in flight on the secure channel.
+// If failFast is true requests which exceed a limit fail immediately with
+// StatusBadTooManyOperations. Otherwise, they wait for a free slot.
+func InflightLimit(max int, failFast bool) Option {
+	return func(c *uasc.Config, sc *uasc.SessionConfig) {
+		c.MaxInflightRequests = max
+		c.InflightFailFast = failFast
+	}
+}
+
+// ServiceInflightLimit limits the number of requests in flight for a single
+// service. typeID is the type id of the request, e.g.
+// id.BrowseRequest_Encoding_DefaultBinary.
+func ServiceInflightLimit(typeID uint16, max int) Option {
+	return func(c *uasc.Config, sc *uasc.SessionConfig) {
+		if c.MaxInflightRequestsPerService == nil {
+			c.MaxInflightRequestsPerService = make(map[uint16]int)
+		}
+		c.MaxInflightRequestsPerService[typeID] = max
+	}
+}
+
 // RequestTimeout sets the timeout for all requests over SecureChannel
 func RequestTimeout(t time.Duration) Option {
 	return func(c *uasc.Config, sc *uasc.SessionConfig) {
@@ -0,0 +1,122 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// NewDataChangeFilter returns a DataChangeFilter which can be used as
+// the filter of the monitoring parameters.
+//
+// See Part 4, 7.17.2 DataChangeFilter
+func NewDataChangeFilter(trigger ua.DataChangeTrigger, deadbandType ua.DeadbandType, deadbandValue float64) *ua.ExtensionObject {
+	return ua.NewExtensionObject(&ua.DataChangeFilter{
+		Trigger:       trigger,
+		DeadbandType:  uint32(deadbandType),
+		DeadbandValue: deadbandValue,
+	})
+}
+
+// NewMonitoredItemCreateRequestWithAbsoluteDeadband returns a request for
+// the value of the node which reports a change only if the value changes
+// by more than the deadband.
+func NewMonitoredItemCreateRequestWithAbsoluteDeadband(nodeID *ua.NodeID, clientHandle uint32, trigger ua.DataChangeTrigger, deadband float64) (*ua.MonitoredItemCreateRequest, error) {
+	if deadband < 0 || math.IsNaN(deadband) || math.IsInf(deadband, 0) {
+		return nil, errors.Errorf("invalid absolute deadband %v", deadband)
+	}
+	req := NewMonitoredItemCreateRequestWithDefaults(nodeID, ua.AttributeIDValue, clientHandle)
+	req.RequestedParameters.Filter = NewDataChangeFilter(trigger, ua.DeadbandTypeAbsolute, deadband)
+	return req, nil
+}
+
+// NewMonitoredItemCreateRequestWithPercentDeadband returns a request for
+// the value of the node which reports a change only if the value changes
+// by more than the percentage of the EURange of the node. The node must
+// be an AnalogItemType with a valid EURange property which is read to
+// validate the request.
+func (c *Client) NewMonitoredItemCreateRequestWithPercentDeadband(nodeID *ua.NodeID, clientHandle uint32, trigger ua.DataChangeTrigger, percent float64) (*ua.MonitoredItemCreateRequest, error) {
+	if percent < 0 || percent > 100 || math.IsNaN(percent) {
+		return nil, errors.Errorf("invalid percent deadband %v: must be between 0 and 100", percent)
+	}
+	if _, err := c.EURange(nodeID); err != nil {
+		return nil, err
+	}
+	req := NewMonitoredItemCreateRequestWithDefaults(nodeID, ua.AttributeIDValue, clientHandle)
+	req.RequestedParameters.Filter = NewDataChangeFilter(trigger, ua.DeadbandTypePercent, percent)
+	return req, nil
+}
+
+// EURange returns the EURange property of an AnalogItemType node. It
+// returns an error if the node has no EURange or the range is invalid.
+//
+// See Part 8, 5.3.2 AnalogItemType
+func (c *Client) EURange(nodeID *ua.NodeID) (*ua.Range, error) {
+	ids, err := c.browseChildren(nodeID, "EURange")
+	if err != nil {
+		return nil, err
+	}
+	if ids[0] == nil {
+		return nil, errors.Errorf("node %s has no EURange property", nodeID)
+	}
+	v, err := c.Node(ids[0]).Value()
+	if err != nil {
+		return nil, errors.Errorf("read EURange of node %s: %s", nodeID, err)
+	}
+
+	var r *ua.Range
+	if eo, ok := v.Value().(*ua.ExtensionObject); ok {
+		r, _ = eo.Value.(*ua.Range)
+	}
+	switch {
+	case r == nil:
+		return nil, errors.Errorf("EURange of node %s is not a Range: %T", nodeID, v.Value())
+	case math.IsNaN(r.Low) || math.IsNaN(r.High) || math.IsInf(r.Low, 0) || math.IsInf(r.High, 0):
+		return nil, errors.Errorf("EURange of node %s is not finite: [%v, %v]", nodeID, r.Low, r.High)
+	case r.High <= r.Low:
+		return nil, errors.Errorf("EURange of node %s is empty: [%v, %v]", nodeID, r.Low, r.High)
+	}
+	return r, nil
+}
+
+// FilterError is returned by Subscription.Monitor when the server
+// rejects the filter of a monitored item.
+type FilterError struct {
+	NodeID *ua.NodeID
+	Status ua.StatusCode
+
+	// FilterResult is the filter result of the server, if any.
+	FilterResult *ua.ExtensionObject
+}
+
+func (e *FilterError) Error() string {
+	msg := fmt.Sprintf("opcua: filter for node %s rejected: %s", e.NodeID, e.Status)
+	if e.Status == ua.StatusBadDeadbandFilterInvalid {
+		msg += ". A percent deadband requires an AnalogItemType with an EURange"
+	}
+	return msg
+}
+
+// Unwrap returns the status code.
+func (e *FilterError) Unwrap() error {
+	return e.Status
+}
+
+// isFilterError returns true if the status of a monitored item
+// refers to its filter.
+func isFilterError(status ua.StatusCode) bool {
+	switch status {
+	case ua.StatusBadDeadbandFilterInvalid,
+		ua.StatusBadFilterNotAllowed,
+		ua.StatusBadMonitoredItemFilterInvalid,
+		ua.StatusBadMonitoredItemFilterUnsupported:
+		return true
+	}
+	return false
+}
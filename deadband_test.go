@@ -0,0 +1,171 @@
+package opcua
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestNewMonitoredItemCreateRequestWithAbsoluteDeadband(t *testing.T) {
+	id := ua.NewStringNodeID(2, "Temperature")
+	req, err := NewMonitoredItemCreateRequestWithAbsoluteDeadband(id, 5, ua.DataChangeTriggerStatusValue, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", req.RequestedParameters.Filter.Value, &ua.DataChangeFilter{
+		Trigger:       ua.DataChangeTriggerStatusValue,
+		DeadbandType:  uint32(ua.DeadbandTypeAbsolute),
+		DeadbandValue: 0.5,
+	})
+	if got, want := req.RequestedParameters.ClientHandle, uint32(5); got != want {
+		t.Fatalf("got client handle %d want %d", got, want)
+	}
+
+	if _, err := NewMonitoredItemCreateRequestWithAbsoluteDeadband(id, 5, ua.DataChangeTriggerStatusValue, -1); err == nil {
+		t.Fatal("got nil want error for negative deadband")
+	}
+}
+
+func TestFilterError(t *testing.T) {
+	err := &FilterError{NodeID: ua.NewStringNodeID(2, "Temperature"), Status: ua.StatusBadDeadbandFilterInvalid}
+	want := "opcua: filter for node ns=2;s=Temperature rejected: " + ua.StatusBadDeadbandFilterInvalid.Error() +
+		". A percent deadband requires an AnalogItemType with an EURange"
+	if got := err.Error(); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+// euRangeClient returns a client for a node whose EURange property has
+// the value v. A nil value means that the node has no EURange property.
+func euRangeClient(t *testing.T, v interface{}) *Client {
+	eurange := ua.NewStringNodeID(2, "Temperature.EURange")
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		switch r := req.(type) {
+		case *ua.TranslateBrowsePathsToNodeIDsRequest:
+			verify.Values(t, "browse name", r.BrowsePaths[0].RelativePath.Elements[0].TargetName.Name, "EURange")
+			if v == nil {
+				return h(&ua.TranslateBrowsePathsToNodeIDsResponse{
+					Results: []*ua.BrowsePathResult{{StatusCode: ua.StatusBadNoMatch}},
+				})
+			}
+			return h(&ua.TranslateBrowsePathsToNodeIDsResponse{
+				Results: []*ua.BrowsePathResult{{Targets: []*ua.BrowsePathTarget{{TargetID: &ua.ExpandedNodeID{NodeID: eurange}}}}},
+			})
+		case *ua.ReadRequest:
+			verify.Values(t, "node id", r.NodesToRead[0].NodeID, eurange)
+			if status, ok := v.(ua.StatusCode); ok {
+				return h(&ua.ReadResponse{Results: []*ua.DataValue{{Status: status}}})
+			}
+			return h(&ua.ReadResponse{Results: []*ua.DataValue{{Value: ua.MustVariant(v)}}})
+		default:
+			t.Fatalf("unexpected request %T", req)
+			return nil
+		}
+	})
+	return c
+}
+
+func TestEURange(t *testing.T) {
+	id := ua.NewStringNodeID(2, "Temperature")
+	eo := func(r *ua.Range) *ua.ExtensionObject { return ua.NewExtensionObject(r) }
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want *ua.Range
+		err  string
+	}{
+		{
+			name: "valid",
+			v:    eo(&ua.Range{Low: -10, High: 50}),
+			want: &ua.Range{Low: -10, High: 50},
+		},
+		{
+			name: "missing property",
+			err:  "opcua: node ns=2;s=Temperature has no EURange property",
+		},
+		{
+			name: "read error",
+			v:    ua.StatusBadNotReadable,
+			err:  "opcua: read EURange of node ns=2;s=Temperature: The access level does not allow reading or subscribing to the Node. StatusBadNotReadable (0x803A0000)",
+		},
+		{
+			name: "not a range",
+			v:    42.0,
+			err:  "opcua: EURange of node ns=2;s=Temperature is not a Range: float64",
+		},
+		{
+			name: "other extension object",
+			v:    ua.NewExtensionObject(&ua.EUInformation{}),
+			err:  "opcua: EURange of node ns=2;s=Temperature is not a Range: *ua.ExtensionObject",
+		},
+		{
+			name: "nan",
+			v:    eo(&ua.Range{Low: math.NaN(), High: 50}),
+			err:  "opcua: EURange of node ns=2;s=Temperature is not finite: [NaN, 50]",
+		},
+		{
+			name: "infinite",
+			v:    eo(&ua.Range{Low: 0, High: math.Inf(1)}),
+			err:  "opcua: EURange of node ns=2;s=Temperature is not finite: [0, +Inf]",
+		},
+		{
+			name: "empty",
+			v:    eo(&ua.Range{Low: 50, High: 50}),
+			err:  "opcua: EURange of node ns=2;s=Temperature is empty: [50, 50]",
+		},
+		{
+			name: "inverted",
+			v:    eo(&ua.Range{Low: 50, High: -10}),
+			err:  "opcua: EURange of node ns=2;s=Temperature is empty: [50, -10]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := euRangeClient(t, tt.v).EURange(id)
+			if tt.err != "" {
+				if err == nil {
+					t.Fatalf("got %v want error %q", r, tt.err)
+				}
+				verify.Values(t, "error", err.Error(), tt.err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "range", r, tt.want)
+		})
+	}
+}
+
+func TestNewMonitoredItemCreateRequestWithPercentDeadband(t *testing.T) {
+	id := ua.NewStringNodeID(2, "Temperature")
+	c := euRangeClient(t, ua.NewExtensionObject(&ua.Range{Low: 0, High: 100}))
+
+	req, err := c.NewMonitoredItemCreateRequestWithPercentDeadband(id, 5, ua.DataChangeTriggerStatusValue, 2.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", req.RequestedParameters.Filter.Value, &ua.DataChangeFilter{
+		Trigger:       ua.DataChangeTriggerStatusValue,
+		DeadbandType:  uint32(ua.DeadbandTypePercent),
+		DeadbandValue: 2.5,
+	})
+	if got, want := req.RequestedParameters.ClientHandle, uint32(5); got != want {
+		t.Fatalf("got client handle %d want %d", got, want)
+	}
+
+	for _, percent := range []float64{-1, 100.5, math.NaN()} {
+		if _, err := c.NewMonitoredItemCreateRequestWithPercentDeadband(id, 5, ua.DataChangeTriggerStatusValue, percent); err == nil {
+			t.Fatalf("got nil want error for percent %v", percent)
+		}
+	}
+
+	// the node must have a valid EURange
+	c = euRangeClient(t, nil)
+	if _, err := c.NewMonitoredItemCreateRequestWithPercentDeadband(id, 5, ua.DataChangeTriggerStatusValue, 2.5); err == nil {
+		t.Fatal("got nil want error for missing EURange")
+	}
+}
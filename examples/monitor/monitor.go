@@ -24,6 +24,7 @@ func main() {
 		keyFile  = flag.String("key", "", "Path to private key.pem. Required for security mode/policy != None")
 		nodeID   = flag.String("node", "", "node id to subscribe to")
 		interval = flag.String("interval", opcua.DefaultSubscriptionInterval.String(), "subscription interval")
+		bp       = flag.String("backpressure", "drop-newest", "strategy for slow consumers: drop-newest, drop-oldest, block, coalesce")
 	)
 	flag.BoolVar(&debug.Enable, "debug", false, "enable debug logging")
 	flag.Parse()
@@ -80,9 +81,25 @@ func main() {
 		log.Fatal(err)
 	}
 
+	switch *bp {
+	case "drop-newest":
+		m.SetBackpressure(monitor.BackpressureDropNewest)
+	case "drop-oldest":
+		m.SetBackpressure(monitor.BackpressureDropOldest)
+	case "block":
+		m.SetBackpressure(monitor.BackpressureBlock)
+	case "coalesce":
+		m.SetBackpressure(monitor.BackpressureCoalesce)
+	default:
+		log.Fatalf("invalid backpressure strategy %q", *bp)
+	}
+
 	m.SetErrorHandler(func(_ *opcua.Client, sub *monitor.Subscription, err error) {
 		log.Printf("error: sub=%d err=%s", sub.SubscriptionID(), err.Error())
 	})
+	m.SetStatusHandler(func(_ *opcua.Client, sub *monitor.Subscription, ev interface{}) {
+		log.Printf("status: sub=%d event=%#v", sub.SubscriptionID(), ev)
+	})
 	wg := &sync.WaitGroup{}
 
 	// start callback-based subscription
@@ -148,7 +165,7 @@ func startChanSub(ctx context.Context, m *monitor.NodeMonitor, interval, lag tim
 }
 
 func cleanup(sub *monitor.Subscription, wg *sync.WaitGroup) {
-	log.Printf("stats: sub=%d delivered=%d dropped=%d", sub.SubscriptionID(), sub.Delivered(), sub.Dropped())
+	log.Printf("stats: sub=%d delivered=%d dropped=%d blocked=%d coalesced=%d", sub.SubscriptionID(), sub.Delivered(), sub.Dropped(), sub.Blocked(), sub.Coalesced())
 	sub.Unsubscribe()
 	wg.Done()
 }
@@ -0,0 +1,61 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/ua"
+)
+
+func main() {
+	var (
+		endpoint = flag.String("endpoint", "opc.tcp://localhost:4840", "OPC UA Endpoint URL")
+		typeID   = flag.String("type", "i=58", "NodeID of the type definition to query")
+	)
+	flag.BoolVar(&debug.Enable, "debug", false, "enable debug logging")
+	flag.Parse()
+	log.SetFlags(0)
+
+	ctx := context.Background()
+
+	c := opcua.NewClient(*endpoint, opcua.SecurityMode(ua.MessageSecurityModeNone))
+	if err := c.Connect(ctx); err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	id, err := ua.ParseNodeID(*typeID)
+	if err != nil {
+		log.Fatalf("invalid node id: %v", err)
+	}
+
+	nodeTypes := []*ua.NodeTypeDescription{
+		{
+			TypeDefinitionNode: ua.NewExpandedNodeID(false, false, id, "", 0),
+			IncludeSubTypes:    true,
+			DataToReturn: []*ua.QueryDataDescription{
+				{
+					RelativePath: &ua.RelativePath{},
+					AttributeID:  ua.AttributeIDBrowseName,
+				},
+			},
+		},
+	}
+
+	sets, err := c.Query(nil, nodeTypes, nil)
+	if err != nil {
+		log.Fatalf("Query failed: %s", err)
+	}
+	for _, s := range sets {
+		for _, v := range s.Values {
+			log.Printf("%s: %v", s.NodeID.NodeID, v.Value())
+		}
+	}
+}
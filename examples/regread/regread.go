@@ -8,6 +8,7 @@ import (
 	"context"
 	"flag"
 	"log"
+	"time"
 
 	"github.com/gopcua/opcua"
 	"github.com/gopcua/opcua/debug"
@@ -18,11 +19,17 @@ func main() {
 	var (
 		endpoint = flag.String("endpoint", "opc.tcp://localhost:4840", "OPC UA Endpoint URL")
 		nodeID   = flag.String("node", "", "NodeID to read")
+		maxAge   = flag.String("max-age", "2s", "maximum age of the value in the cache of the server")
 	)
 	flag.BoolVar(&debug.Enable, "debug", false, "enable debug logging")
 	flag.Parse()
 	log.SetFlags(0)
 
+	age, err := time.ParseDuration(*maxAge)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	ctx := context.Background()
 
 	c := opcua.NewClient(*endpoint, opcua.SecurityMode(ua.MessageSecurityModeNone))
@@ -36,34 +43,20 @@ func main() {
 		log.Fatalf("invalid node id: %v", err)
 	}
 
-	regResp, err := c.RegisterNodes(&ua.RegisterNodesRequest{
-		NodesToRegister: []*ua.NodeID{id},
-	})
+	nodes, err := c.RegisterNodeIDs(id)
 	if err != nil {
-		log.Fatalf("RegisterNodes failed: %v", err)
+		log.Fatalf("RegisterNodeIDs failed: %v", err)
 	}
+	defer nodes.Close()
 
-	req := &ua.ReadRequest{
-		MaxAge: 2000,
-		NodesToRead: []*ua.ReadValueID{
-			&ua.ReadValueID{NodeID: regResp.RegisteredNodeIDs[0]},
-		},
-		TimestampsToReturn: ua.TimestampsToReturnBoth,
-	}
+	log.Printf("registered %s as %s", id, nodes.NodeIDs()[0])
 
-	resp, err := c.Read(req)
+	values, err := nodes.ReadWithMaxAge(age)
 	if err != nil {
 		log.Fatalf("Read failed: %s", err)
 	}
-	if resp.Results[0].Status != ua.StatusOK {
-		log.Fatalf("Status not OK: %v", resp.Results[0].Status)
-	}
-	log.Print(resp.Results[0].Value.Value())
-
-	_, err = c.UnregisterNodes(&ua.UnregisterNodesRequest{
-		NodesToUnregister: []*ua.NodeID{id},
-	})
-	if err != nil {
-		log.Fatalf("UnregisterNodes failed: %v", err)
+	if values[0].Status != ua.StatusOK {
+		log.Fatalf("Status not OK: %v", values[0].Status)
 	}
+	log.Print(values[0].Value.Value())
 }
@@ -0,0 +1,97 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/ua"
+)
+
+func main() {
+	var (
+		endpoint = flag.String("endpoint", "opc.tcp://localhost:4840", "OPC UA Endpoint URL")
+		nodeID   = flag.String("node", "", "node id to subscribe to")
+		state    = flag.String("state", "session.json", "file for the session state")
+	)
+	flag.BoolVar(&debug.Enable, "debug", false, "enable debug logging")
+	flag.Parse()
+	log.SetFlags(0)
+
+	ctx := context.Background()
+	notifyCh := make(chan *opcua.PublishNotificationData)
+
+	c := opcua.NewClient(*endpoint, opcua.SecurityMode(ua.MessageSecurityModeNone))
+
+	st, err := opcua.LoadSessionState(*state)
+	switch {
+	case err == nil && !st.Expired():
+		subs, err := c.ResumeSession(ctx, st, notifyCh)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Resumed session %s with %d subscriptions", st.SessionID, len(subs))
+
+	default:
+		if err := c.Connect(ctx); err != nil {
+			log.Fatal(err)
+		}
+		id, err := ua.ParseNodeID(*nodeID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sub, err := c.Subscribe(&opcua.SubscriptionParameters{Interval: time.Second}, notifyCh)
+		if err != nil {
+			log.Fatal(err)
+		}
+		miCreateRequest := opcua.NewMonitoredItemCreateRequestWithDefaults(id, ua.AttributeIDValue, 42)
+		if _, err := sub.Monitor(ua.TimestampsToReturnBoth, miCreateRequest); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Created subscription with id %v", sub.SubscriptionID)
+	}
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt)
+
+	for {
+		select {
+		case <-sigch:
+			st, err := c.SessionState()
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := opcua.SaveSessionState(*state, st); err != nil {
+				log.Fatal(err)
+			}
+
+			// keep the session and its subscriptions on the server
+			if _, err := c.DetachSession(); err != nil {
+				log.Fatal(err)
+			}
+			c.Close()
+			log.Printf("Saved session %s", st.SessionID)
+			return
+
+		case res := <-notifyCh:
+			if res.Error != nil {
+				log.Print(res.Error)
+				continue
+			}
+			if x, ok := res.Value.(*ua.DataChangeNotification); ok {
+				for _, item := range x.MonitoredItems {
+					log.Printf("MonitoredItem with client handle %v = %v", item.ClientHandle, item.Value.Value.Value())
+				}
+			}
+		}
+	}
+}
@@ -13,7 +13,6 @@ import (
 
 	"github.com/gopcua/opcua"
 	"github.com/gopcua/opcua/debug"
-	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/ua"
 )
 
@@ -138,57 +137,12 @@ func valueRequest(nodeID *ua.NodeID) *ua.MonitoredItemCreateRequest {
 
 func eventRequest(nodeID *ua.NodeID) (*ua.MonitoredItemCreateRequest, []string) {
 	fieldNames := []string{"EventId", "EventType", "Severity", "Time", "Message"}
-	selects := make([]*ua.SimpleAttributeOperand, len(fieldNames))
-
-	for i, name := range fieldNames {
-		selects[i] = &ua.SimpleAttributeOperand{
-			TypeDefinitionID: ua.NewNumericNodeID(0, id.BaseEventType),
-			BrowsePath:       []*ua.QualifiedName{{NamespaceIndex: 0, Name: name}},
-			AttributeID:      ua.AttributeIDValue,
-		}
-	}
-
-	wheres := &ua.ContentFilter{
-		Elements: []*ua.ContentFilterElement{
-			{
-				FilterOperator: ua.FilterOperatorGreaterThanOrEqual,
-				FilterOperands: []*ua.ExtensionObject{
-					{
-						EncodingMask: 1,
-						TypeID: &ua.ExpandedNodeID{
-							NodeID: ua.NewNumericNodeID(0, id.SimpleAttributeOperand_Encoding_DefaultBinary),
-						},
-						Value: ua.SimpleAttributeOperand{
-							TypeDefinitionID: ua.NewNumericNodeID(0, id.BaseEventType),
-							BrowsePath:       []*ua.QualifiedName{{NamespaceIndex: 0, Name: "Severity"}},
-							AttributeID:      ua.AttributeIDValue,
-						},
-					},
-					{
-						EncodingMask: 1,
-						TypeID: &ua.ExpandedNodeID{
-							NodeID: ua.NewNumericNodeID(0, id.LiteralOperand_Encoding_DefaultBinary),
-						},
-						Value: ua.LiteralOperand{
-							Value: ua.MustVariant(uint16(0)),
-						},
-					},
-				},
-			},
-		},
-	}
-
-	filter := ua.EventFilter{
-		SelectClauses: selects,
-		WhereClause:   wheres,
-	}
-
-	filterExtObj := ua.ExtensionObject{
-		EncodingMask: ua.ExtensionObjectBinary,
-		TypeID: &ua.ExpandedNodeID{
-			NodeID: ua.NewNumericNodeID(0, id.EventFilter_Encoding_DefaultBinary),
-		},
-		Value: filter,
+	filter, err := ua.NewEventFilterBuilder().
+		Select(fieldNames...).
+		Where(ua.GreaterThanOrEqual(ua.EventField("Severity"), uint16(0))).
+		Build()
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	handle := uint32(42)
@@ -202,7 +156,7 @@ func eventRequest(nodeID *ua.NodeID) (*ua.MonitoredItemCreateRequest, []string)
 		RequestedParameters: &ua.MonitoringParameters{
 			ClientHandle:     handle,
 			DiscardOldest:    true,
-			Filter:           &filterExtObj,
+			Filter:           ua.NewExtensionObject(filter),
 			QueueSize:        10,
 			SamplingInterval: 1.0,
 		},
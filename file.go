@@ -0,0 +1,409 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"io"
+
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// DefaultFileChunkSize is the number of bytes which are read or written
+// with a single call if the server does not report a lower limit.
+const DefaultFileChunkSize = 64 * 1024
+
+// File provides access to the content of a FileType object. It implements
+// io.ReadWriteSeeker and io.Closer. Reads and writes are split into
+// chunks which do not exceed the byte string limits of the server.
+//
+// See Part 5, C.2 FileType
+type File struct {
+	c  *Client
+	id *ua.NodeID
+
+	// ChunkSize is the maximum number of bytes per Read or Write call.
+	ChunkSize int
+
+	handle  *ua.Variant
+	methods map[string]*ua.NodeID
+	props   map[string]*ua.NodeID
+
+	// buf holds the bytes the server returned beyond the requested
+	// length. The position on the server is ahead of the position of
+	// the caller by len(buf).
+	buf []byte
+}
+
+var _ io.ReadWriteSeeker = (*File)(nil)
+
+// fileMethods and fileProps are the children of a FileType object
+// which are looked up when the file is opened.
+var (
+	fileMethods = []string{"Open", "Close", "Read", "Write", "GetPosition", "SetPosition"}
+	fileProps   = []string{"Size", "Writable", "MaxByteStringLength"}
+)
+
+// OpenFile opens the FileType object with the given mode, e.g.
+// ua.OpenFileModeRead or ua.OpenFileModeWrite|ua.OpenFileModeEraseExisting.
+// The caller must close the file to release the file handle on the server.
+func (c *Client) OpenFile(fileID *ua.NodeID, mode ua.OpenFileMode) (*File, error) {
+	names := append(append([]string{}, fileMethods...), fileProps...)
+	ids, err := c.browseChildren(fileID, names...)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{
+		c:         c,
+		id:        fileID,
+		ChunkSize: DefaultFileChunkSize,
+		methods:   make(map[string]*ua.NodeID),
+		props:     make(map[string]*ua.NodeID),
+	}
+	for i, name := range fileMethods {
+		if ids[i] == nil {
+			return nil, errors.Errorf("file method %s not found", name)
+		}
+		f.methods[name] = ids[i]
+	}
+	for i, name := range fileProps {
+		f.props[name] = ids[len(fileMethods)+i]
+	}
+
+	// limit the chunks to the byte string length of the file
+	// or the server.
+	limit := 0
+	if caps := c.ServerCapabilities(); caps != nil {
+		limit = int(caps.MaxByteStringLength)
+	}
+	if id := f.props["MaxByteStringLength"]; id != nil {
+		if v, err := c.Node(id).Value(); err == nil && v.Uint() > 0 {
+			limit = int(v.Uint())
+		}
+	}
+	if limit > 0 && limit < f.ChunkSize {
+		f.ChunkSize = limit
+	}
+
+	out, err := f.call("Open", ua.MustVariant(byte(mode)))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != 1 {
+		return nil, errors.Errorf("open: invalid file handle")
+	}
+	f.handle = out[0]
+	return f, nil
+}
+
+// Read reads up to len(p) bytes from the current position.
+// It returns io.EOF at the end of the file.
+func (f *File) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(f.buf) > 0 {
+		n := copy(p, f.buf)
+		f.buf = f.buf[n:]
+		return n, nil
+	}
+	n := len(p)
+	if n > f.ChunkSize {
+		n = f.ChunkSize
+	}
+	out, err := f.call("Read", f.handle, ua.MustVariant(int32(n)))
+	if err != nil {
+		return 0, err
+	}
+	if len(out) != 1 {
+		return 0, errors.Errorf("read: invalid result")
+	}
+	data := out[0].ByteString()
+	if len(data) == 0 {
+		return 0, io.EOF
+	}
+	// keep what the server sent beyond the requested length
+	n = copy(p, data)
+	if n < len(data) {
+		f.buf = append([]byte(nil), data[n:]...)
+	}
+	return n, nil
+}
+
+// Write writes p at the current position.
+func (f *File) Write(p []byte) (int, error) {
+	// move the server back to the position of the caller
+	if len(f.buf) > 0 {
+		if _, err := f.Seek(0, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	for n < len(p) {
+		end := n + f.ChunkSize
+		if end > len(p) {
+			end = len(p)
+		}
+		if _, err := f.call("Write", f.handle, ua.MustVariant(p[n:end])); err != nil {
+			return n, err
+		}
+		n = end
+	}
+	return n, nil
+}
+
+// Seek sets the position for the next Read or Write. io.SeekEnd
+// reads the Size property of the file.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	var base int64
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		pos, err := f.position()
+		if err != nil {
+			return 0, err
+		}
+		base = pos - int64(len(f.buf))
+	case io.SeekEnd:
+		size, err := f.Size()
+		if err != nil {
+			return 0, err
+		}
+		base = size
+	default:
+		return 0, errors.Errorf("seek: invalid whence %d", whence)
+	}
+
+	pos := base + offset
+	if pos < 0 {
+		return 0, errors.Errorf("seek: negative position %d", pos)
+	}
+	if _, err := f.call("SetPosition", f.handle, ua.MustVariant(uint64(pos))); err != nil {
+		return 0, err
+	}
+	f.buf = nil
+	return pos, nil
+}
+
+// Size returns the size of the file in bytes.
+func (f *File) Size() (int64, error) {
+	v, err := f.prop("Size")
+	if err != nil {
+		return 0, err
+	}
+	return int64(v.Uint()), nil
+}
+
+// Writable returns true if the file can be modified.
+func (f *File) Writable() (bool, error) {
+	v, err := f.prop("Writable")
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.Value().(bool)
+	if !ok {
+		return false, errors.Errorf("writable: invalid value %T", v.Value())
+	}
+	return b, nil
+}
+
+// Close releases the file handle on the server.
+func (f *File) Close() error {
+	if f.handle == nil {
+		return nil
+	}
+	_, err := f.call("Close", f.handle)
+	f.handle = nil
+	f.buf = nil
+	return err
+}
+
+func (f *File) position() (int64, error) {
+	out, err := f.call("GetPosition", f.handle)
+	if err != nil {
+		return 0, err
+	}
+	if len(out) != 1 {
+		return 0, errors.Errorf("get position: invalid result")
+	}
+	return int64(out[0].Uint()), nil
+}
+
+func (f *File) prop(name string) (*ua.Variant, error) {
+	id := f.props[name]
+	if id == nil {
+		return nil, errors.Errorf("file property %s not found", name)
+	}
+	return f.c.Node(id).Value()
+}
+
+func (f *File) call(method string, args ...*ua.Variant) ([]*ua.Variant, error) {
+	res, err := f.c.Call(&ua.CallMethodRequest{
+		ObjectID:       f.id,
+		MethodID:       f.methods[method],
+		InputArguments: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != ua.StatusOK {
+		return nil, res.StatusCode
+	}
+	return res.OutputArguments, nil
+}
+
+// FileDirectory provides access to a FileDirectoryType object.
+//
+// See Part 5, C.3 FileDirectoryType
+type FileDirectory struct {
+	c  *Client
+	ID *ua.NodeID
+}
+
+// FileDirectory returns a handle for the FileDirectoryType object.
+func (c *Client) FileDirectory(id *ua.NodeID) *FileDirectory {
+	return &FileDirectory{c: c, ID: id}
+}
+
+// FileEntry is an entry of a FileDirectory.
+type FileEntry struct {
+	Name   string
+	NodeID *ua.NodeID
+	IsDir  bool
+}
+
+// List returns the files and directories in the directory.
+func (d *FileDirectory) List() ([]*FileEntry, error) {
+	refs, err := d.c.Node(d.ID).References(id.HierarchicalReferences, ua.BrowseDirectionForward, ua.NodeClassObject, true)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*FileEntry
+	for _, r := range refs {
+		e := &FileEntry{
+			Name:   r.BrowseName.Name,
+			NodeID: r.NodeID.NodeID,
+		}
+		if td := r.TypeDefinition; td != nil && td.NodeID != nil {
+			e.IsDir = td.NodeID.Namespace() == 0 && td.NodeID.IntID() == id.FileDirectoryType
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// CreateDirectory creates a sub directory.
+func (d *FileDirectory) CreateDirectory(name string) (*FileDirectory, error) {
+	out, err := d.call(id.FileDirectoryType_CreateDirectory, "CreateDirectory", ua.MustVariant(name))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != 1 {
+		return nil, errors.Errorf("create directory: invalid result")
+	}
+	nid, ok := out[0].Value().(*ua.NodeID)
+	if !ok {
+		return nil, errors.Errorf("create directory: invalid node id %T", out[0].Value())
+	}
+	return d.c.FileDirectory(nid), nil
+}
+
+// CreateFile creates an empty file and returns its node id.
+// Use OpenFile to write its content.
+func (d *FileDirectory) CreateFile(name string) (*ua.NodeID, error) {
+	out, err := d.call(id.FileDirectoryType_CreateFile, "CreateFile", ua.MustVariant(name), ua.MustVariant(false))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) < 1 {
+		return nil, errors.Errorf("create file: invalid result")
+	}
+	nid, ok := out[0].Value().(*ua.NodeID)
+	if !ok {
+		return nil, errors.Errorf("create file: invalid node id %T", out[0].Value())
+	}
+	return nid, nil
+}
+
+// Delete deletes a file or a directory including its content.
+func (d *FileDirectory) Delete(nodeID *ua.NodeID) error {
+	_, err := d.call(id.FileDirectoryType_DeleteFileSystemObject, "DeleteFileSystemObject", ua.MustVariant(nodeID))
+	return err
+}
+
+// call calls the method of the directory. It tries the method of the
+// type definition first and looks up the method of the instance if the
+// server does not accept it.
+func (d *FileDirectory) call(typeMethod uint32, name string, args ...*ua.Variant) ([]*ua.Variant, error) {
+	call := func(method *ua.NodeID) (*ua.CallMethodResult, error) {
+		return d.c.Call(&ua.CallMethodRequest{
+			ObjectID:       d.ID,
+			MethodID:       method,
+			InputArguments: args,
+		})
+	}
+
+	res, err := call(ua.NewNumericNodeID(0, typeMethod))
+	if err == nil && res.StatusCode == ua.StatusBadMethodInvalid {
+		ids, berr := d.c.browseChildren(d.ID, name)
+		if berr != nil {
+			return nil, berr
+		}
+		if ids[0] == nil {
+			return nil, ua.StatusBadMethodInvalid
+		}
+		res, err = call(ids[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != ua.StatusOK {
+		return nil, res.StatusCode
+	}
+	return res.OutputArguments, nil
+}
+
+// browseChildren looks up the children of the node with the given
+// browse names in namespace 0 in a single request. The node id of a
+// child which does not exist is nil.
+func (c *Client) browseChildren(nodeID *ua.NodeID, names ...string) ([]*ua.NodeID, error) {
+	req := &ua.TranslateBrowsePathsToNodeIDsRequest{}
+	for _, name := range names {
+		req.BrowsePaths = append(req.BrowsePaths, &ua.BrowsePath{
+			StartingNode: nodeID,
+			RelativePath: &ua.RelativePath{
+				Elements: []*ua.RelativePathElement{
+					{
+						ReferenceTypeID: ua.NewTwoByteNodeID(id.HierarchicalReferences),
+						IncludeSubtypes: true,
+						TargetName:      &ua.QualifiedName{NamespaceIndex: 0, Name: name},
+					},
+				},
+			},
+		})
+	}
+
+	var res *ua.TranslateBrowsePathsToNodeIDsResponse
+	err := c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Results) != len(names) {
+		return nil, ua.StatusBadUnexpectedError
+	}
+
+	ids := make([]*ua.NodeID, len(names))
+	for i, r := range res.Results {
+		if r.StatusCode != ua.StatusOK || len(r.Targets) == 0 {
+			continue
+		}
+		ids[i] = r.Targets[0].TargetID.NodeID
+	}
+	return ids, nil
+}
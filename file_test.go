@@ -0,0 +1,363 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/pascaldekloe/goe/verify"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// fileServer implements the methods of a single FileType object and of
+// a FileDirectoryType object for the tests.
+type fileServer struct {
+	t *testing.T
+
+	data     []byte
+	pos      int
+	handles  map[uint32]bool
+	next     uint32
+	maxBytes uint32
+
+	// extra is the number of bytes Read returns beyond the requested length.
+	extra int
+
+	// instanceMethods makes the directory reject the methods
+	// of the type definition.
+	instanceMethods bool
+
+	// calls are the names of the called methods.
+	calls []string
+}
+
+var (
+	testFileID = ua.NewStringNodeID(1, "file")
+	testDirID  = ua.NewStringNodeID(1, "dir")
+)
+
+func newFileServer(t *testing.T, data string) *fileServer {
+	return &fileServer{t: t, data: []byte(data), handles: make(map[uint32]bool)}
+}
+
+// client returns a client which sends its requests to the server.
+func (s *fileServer) client() *Client {
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		switch r := req.(type) {
+		case *ua.TranslateBrowsePathsToNodeIDsRequest:
+			return h(s.translate(r))
+		case *ua.ReadRequest:
+			return h(s.read(r))
+		case *ua.CallRequest:
+			return h(&ua.CallResponse{Results: []*ua.CallMethodResult{s.call(r.MethodsToCall[0])}})
+		case *ua.BrowseRequest:
+			return h(s.browse(r))
+		default:
+			s.t.Fatalf("unexpected request %T", req)
+			return nil
+		}
+	})
+	return c
+}
+
+// translate returns "<parent>.<name>" as node id of the children.
+func (s *fileServer) translate(r *ua.TranslateBrowsePathsToNodeIDsRequest) *ua.TranslateBrowsePathsToNodeIDsResponse {
+	res := &ua.TranslateBrowsePathsToNodeIDsResponse{}
+	for _, bp := range r.BrowsePaths {
+		name := bp.RelativePath.Elements[0].TargetName.Name
+		if name == "MaxByteStringLength" && s.maxBytes == 0 {
+			res.Results = append(res.Results, &ua.BrowsePathResult{StatusCode: ua.StatusBadNoMatch})
+			continue
+		}
+		nid := ua.NewStringNodeID(1, bp.StartingNode.StringID()+"."+name)
+		res.Results = append(res.Results, &ua.BrowsePathResult{
+			Targets: []*ua.BrowsePathTarget{{TargetID: &ua.ExpandedNodeID{NodeID: nid}}},
+		})
+	}
+	return res
+}
+
+func (s *fileServer) read(r *ua.ReadRequest) *ua.ReadResponse {
+	res := &ua.ReadResponse{}
+	for _, rv := range r.NodesToRead {
+		var v interface{}
+		switch rv.NodeID.StringID() {
+		case "file.Size":
+			v = uint64(len(s.data))
+		case "file.Writable":
+			v = true
+		case "file.MaxByteStringLength":
+			v = s.maxBytes
+		default:
+			res.Results = append(res.Results, &ua.DataValue{Status: ua.StatusBadNodeIDUnknown})
+			continue
+		}
+		res.Results = append(res.Results, &ua.DataValue{Value: ua.MustVariant(v)})
+	}
+	return res
+}
+
+func (s *fileServer) call(r *ua.CallMethodRequest) *ua.CallMethodResult {
+	var method string
+	if r.MethodID.Namespace() == 0 {
+		if s.instanceMethods {
+			return &ua.CallMethodResult{StatusCode: ua.StatusBadMethodInvalid}
+		}
+		method = map[uint32]string{
+			id.FileDirectoryType_CreateDirectory:        "CreateDirectory",
+			id.FileDirectoryType_CreateFile:             "CreateFile",
+			id.FileDirectoryType_DeleteFileSystemObject: "DeleteFileSystemObject",
+		}[r.MethodID.IntID()]
+	} else {
+		method = strings.TrimPrefix(r.MethodID.StringID(), r.ObjectID.StringID()+".")
+	}
+	s.calls = append(s.calls, method)
+
+	out := func(v ...interface{}) *ua.CallMethodResult {
+		res := &ua.CallMethodResult{}
+		for _, x := range v {
+			res.OutputArguments = append(res.OutputArguments, ua.MustVariant(x))
+		}
+		return res
+	}
+	arg := func(i int) interface{} { return r.InputArguments[i].Value() }
+
+	switch method {
+	case "Open":
+		s.next++
+		s.handles[s.next] = true
+		s.pos = 0
+		if arg(0).(byte)&byte(ua.OpenFileModeEraseExisting) != 0 {
+			s.data = nil
+		}
+		return out(s.next)
+	case "Close", "Read", "Write", "GetPosition", "SetPosition":
+		if !s.handles[arg(0).(uint32)] {
+			return &ua.CallMethodResult{StatusCode: ua.StatusBadInvalidArgument}
+		}
+	}
+
+	switch method {
+	case "Close":
+		delete(s.handles, arg(0).(uint32))
+		return out()
+	case "Read":
+		end := s.pos + int(arg(1).(int32)) + s.extra
+		if end > len(s.data) {
+			end = len(s.data)
+		}
+		b := append([]byte{}, s.data[s.pos:end]...)
+		s.pos = end
+		return out(b)
+	case "Write":
+		b := arg(1).([]byte)
+		s.data = append(s.data[:s.pos], b...)
+		s.pos += len(b)
+		return out()
+	case "GetPosition":
+		return out(uint64(s.pos))
+	case "SetPosition":
+		s.pos = int(arg(1).(uint64))
+		return out()
+	case "CreateDirectory":
+		return out(ua.NewStringNodeID(1, arg(0).(string)))
+	case "CreateFile":
+		return out(ua.NewStringNodeID(1, arg(0).(string)), uint32(0))
+	case "DeleteFileSystemObject":
+		return out()
+	default:
+		s.t.Fatalf("unexpected method %v", r.MethodID)
+		return nil
+	}
+}
+
+func (s *fileServer) browse(r *ua.BrowseRequest) *ua.BrowseResponse {
+	ref := func(name string, typeDef uint32) *ua.ReferenceDescription {
+		return &ua.ReferenceDescription{
+			NodeID:         &ua.ExpandedNodeID{NodeID: ua.NewStringNodeID(1, name)},
+			BrowseName:     &ua.QualifiedName{Name: name},
+			TypeDefinition: &ua.ExpandedNodeID{NodeID: ua.NewNumericNodeID(0, typeDef)},
+		}
+	}
+	return &ua.BrowseResponse{
+		Results: []*ua.BrowseResult{{
+			References: []*ua.ReferenceDescription{
+				ref("log.txt", id.FileType),
+				ref("sub", id.FileDirectoryType),
+			},
+		}},
+	}
+}
+
+func TestFileRead(t *testing.T) {
+	s := newFileServer(t, "hello, world")
+	f, err := s.client().OpenFile(testFileID, ua.OpenFileModeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ChunkSize = 5
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", string(b), "hello, world")
+	verify.Values(t, "reads", s.calls, []string{"Open", "Read", "Read", "Read", "Read"})
+}
+
+func TestFileReadMoreThanRequested(t *testing.T) {
+	s := newFileServer(t, "hello, world")
+	s.extra = 3
+	f, err := s.client().OpenFile(testFileID, ua.OpenFileModeRead|ua.OpenFileModeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 4)
+	n, err := f.Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "read", string(p[:n]), "hell")
+
+	// the buffered bytes are returned before the next call
+	n, err = f.Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "buffered", string(p[:n]), "o, ")
+	verify.Values(t, "calls", s.calls, []string{"Open", "Read"})
+
+	// read into the buffer again and check the position of the caller
+	if _, err := f.Read(p[:1]); err != nil {
+		t.Fatal(err)
+	}
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "position", pos, int64(8))
+
+	// a write continues at the position of the caller
+	if _, err := f.Read(p[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("W")); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", string(s.data), "hello, woW")
+}
+
+func TestFileWriteSeek(t *testing.T) {
+	s := newFileServer(t, "old content")
+	s.maxBytes = 4
+	f, err := s.client().OpenFile(testFileID, ua.OpenFileModeWrite|ua.OpenFileModeEraseExisting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "chunk size", f.ChunkSize, 4)
+
+	n, err := f.Write([]byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "written", n, 10)
+	verify.Values(t, "data", string(s.data), "0123456789")
+	verify.Values(t, "calls", s.calls, []string{"Open", "Write", "Write", "Write"})
+
+	tests := []struct {
+		name   string
+		offset int64
+		whence int
+		want   int64
+	}{
+		{"start", 2, io.SeekStart, 2},
+		{"current", 3, io.SeekCurrent, 5},
+		{"end", -1, io.SeekEnd, 9},
+	}
+	for _, tt := range tests {
+		pos, err := f.Seek(tt.offset, tt.whence)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		verify.Values(t, tt.name, pos, tt.want)
+	}
+	if _, err := f.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("got nil want error for negative position")
+	}
+	if _, err := f.Seek(0, 42); err == nil {
+		t.Fatal("got nil want error for invalid whence")
+	}
+
+	size, err := f.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "size", size, int64(10))
+	w, err := f.Writable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "writable", w, true)
+}
+
+func TestFileClose(t *testing.T) {
+	s := newFileServer(t, "data")
+	f, err := s.client().OpenFile(testFileID, ua.OpenFileModeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "open handles", len(s.handles), 1)
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "open handles", len(s.handles), 0)
+
+	// closing again does not call the server
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "calls", s.calls, []string{"Open", "Close"})
+}
+
+func TestFileDirectory(t *testing.T) {
+	for _, instance := range []bool{false, true} {
+		s := newFileServer(t, "")
+		s.instanceMethods = instance
+		d := s.client().FileDirectory(testDirID)
+
+		entries, err := d.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, "list", entries, []*FileEntry{
+			{Name: "log.txt", NodeID: ua.NewStringNodeID(1, "log.txt")},
+			{Name: "sub", NodeID: ua.NewStringNodeID(1, "sub"), IsDir: true},
+		})
+
+		sub, err := d.CreateDirectory("new")
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, "directory", sub.ID, ua.NewStringNodeID(1, "new"))
+
+		fid, err := d.CreateFile("new.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, "file", fid, ua.NewStringNodeID(1, "new.txt"))
+
+		if err := d.Delete(fid); err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, "calls", s.calls, []string{"CreateDirectory", "CreateFile", "DeleteFileSystemObject"})
+	}
+}
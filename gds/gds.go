@@ -0,0 +1,262 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+// Package gds implements a client for the Directory and the pull
+// certificate management of a Global Discovery Server.
+//
+// See Part 12, 6 and 7.6
+package gds
+
+import (
+	"io/ioutil"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Client calls the methods of the Directory object of a GDS. The
+// connection must be authorized for the methods, e.g. as the
+// application itself or as an administrator.
+type Client struct {
+	c  *opcua.Client
+	ns uint16
+}
+
+// NewClient returns a GDS client for a connected client. It looks up
+// the namespace index of the GDS namespace.
+func NewClient(c *opcua.Client) (*Client, error) {
+	ns, err := c.FindNamespace(NamespaceURI)
+	if err != nil {
+		return nil, err
+	}
+	registerTypes(ns)
+	return &Client{c: c, ns: ns}, nil
+}
+
+// RegisterApplication registers the application and returns the
+// application id assigned by the GDS.
+//
+// See Part 12, 6.6.3
+func (g *Client) RegisterApplication(app *ApplicationRecordDataType) (*ua.NodeID, error) {
+	out, err := g.call(Directory_RegisterApplication, 1, g.extensionObject(app))
+	if err != nil {
+		return nil, err
+	}
+	id, ok := out[0].Value().(*ua.NodeID)
+	if !ok {
+		return nil, errors.Errorf("invalid application id: %T", out[0].Value())
+	}
+	return id, nil
+}
+
+// FindApplications returns the applications registered
+// with the given application uri.
+//
+// See Part 12, 6.6.7
+func (g *Client) FindApplications(applicationURI string) ([]*ApplicationRecordDataType, error) {
+	out, err := g.call(Directory_FindApplications, 1, applicationURI)
+	if err != nil {
+		return nil, err
+	}
+	eos, _ := out[0].Value().([]*ua.ExtensionObject)
+	apps := make([]*ApplicationRecordDataType, 0, len(eos))
+	for _, eo := range eos {
+		app, ok := eo.Value.(*ApplicationRecordDataType)
+		if !ok {
+			return nil, errors.Errorf("invalid application record: %T", eo.Value)
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// StartSigningRequest asks the GDS to sign the DER encoded certificate
+// signing request and returns the id of the request. A nil group or
+// certificate type selects the defaults of the GDS.
+//
+// See Part 12, 7.6.3
+func (g *Client) StartSigningRequest(applicationID, certificateGroupID, certificateTypeID *ua.NodeID, csr []byte) (*ua.NodeID, error) {
+	out, err := g.call(Directory_StartSigningRequest, 1,
+		applicationID,
+		nodeID(certificateGroupID),
+		nodeID(certificateTypeID),
+		csr,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return requestID(out[0])
+}
+
+// StartNewKeyPairRequest asks the GDS to create a new key pair and a
+// certificate for it and returns the id of the request. privateKeyFormat
+// is either "PEM" or "PFX".
+//
+// See Part 12, 7.6.4
+func (g *Client) StartNewKeyPairRequest(applicationID, certificateGroupID, certificateTypeID *ua.NodeID, subjectName string, domainNames []string, privateKeyFormat, privateKeyPassword string) (*ua.NodeID, error) {
+	if domainNames == nil {
+		domainNames = []string{}
+	}
+	out, err := g.call(Directory_StartNewKeyPairRequest, 1,
+		applicationID,
+		nodeID(certificateGroupID),
+		nodeID(certificateTypeID),
+		subjectName,
+		domainNames,
+		privateKeyFormat,
+		privateKeyPassword,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return requestID(out[0])
+}
+
+// FinishResult is the result of a completed certificate request.
+type FinishResult struct {
+	// Certificate is the DER encoded certificate.
+	Certificate []byte
+
+	// PrivateKey is the private key in the requested format. It is
+	// only set for a StartNewKeyPairRequest.
+	PrivateKey []byte
+
+	// IssuerCertificates are the DER encoded certificates of the CAs
+	// which issued the certificate.
+	IssuerCertificates [][]byte
+}
+
+// FinishRequest returns the result of a certificate request. It returns
+// ua.StatusBadNothingToDo if the request has not been completed yet.
+//
+// See Part 12, 7.6.5
+func (g *Client) FinishRequest(applicationID, requestID *ua.NodeID) (*FinishResult, error) {
+	out, err := g.call(Directory_FinishRequest, 3, applicationID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	r := &FinishResult{
+		Certificate: out[0].ByteString(),
+		PrivateKey:  out[1].ByteString(),
+	}
+	r.IssuerCertificates, _ = out[2].Value().([][]byte)
+	return r, nil
+}
+
+// GetTrustList returns the id of the trust list object for the
+// certificate group of the application.
+//
+// See Part 12, 7.6.6
+func (g *Client) GetTrustList(applicationID, certificateGroupID *ua.NodeID) (*ua.NodeID, error) {
+	out, err := g.call(Directory_GetTrustList, 1, applicationID, nodeID(certificateGroupID))
+	if err != nil {
+		return nil, err
+	}
+	id, ok := out[0].Value().(*ua.NodeID)
+	if !ok {
+		return nil, errors.Errorf("invalid trust list id: %T", out[0].Value())
+	}
+	return id, nil
+}
+
+// ReadTrustList reads the content of the trust list object returned by
+// GetTrustList.
+//
+// See Part 12, 7.5.2
+func (g *Client) ReadTrustList(trustListID *ua.NodeID) (*ua.TrustListDataType, error) {
+	f, err := g.c.OpenFile(trustListID, ua.OpenFileModeRead)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	tl := new(ua.TrustListDataType)
+	if _, err := ua.Decode(b, tl); err != nil {
+		return nil, err
+	}
+	return tl, nil
+}
+
+// GetCertificateStatus returns true if the certificate of the
+// application needs to be updated.
+//
+// See Part 12, 7.6.8
+func (g *Client) GetCertificateStatus(applicationID, certificateGroupID, certificateTypeID *ua.NodeID) (bool, error) {
+	out, err := g.call(Directory_GetCertificateStatus, 1,
+		applicationID,
+		nodeID(certificateGroupID),
+		nodeID(certificateTypeID),
+	)
+	if err != nil {
+		return false, err
+	}
+	updateRequired, ok := out[0].Value().(bool)
+	if !ok {
+		return false, errors.Errorf("invalid certificate status: %T", out[0].Value())
+	}
+	return updateRequired, nil
+}
+
+// call calls the method of the Directory object and
+// checks that it returns at least nout output arguments.
+func (g *Client) call(method uint32, nout int, args ...interface{}) ([]*ua.Variant, error) {
+	in := make([]*ua.Variant, len(args))
+	for i, arg := range args {
+		v, err := ua.NewVariant(arg)
+		if err != nil {
+			return nil, errors.Errorf("invalid argument %d: %s", i, err)
+		}
+		in[i] = v
+	}
+	req := &ua.CallMethodRequest{
+		ObjectID:       ua.NewNumericNodeID(g.ns, Directory),
+		MethodID:       ua.NewNumericNodeID(g.ns, method),
+		InputArguments: in,
+	}
+	res, err := g.c.Call(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != ua.StatusOK {
+		debug.Printf("gds: method %d failed: %v", method, res.StatusCode)
+		return nil, res.StatusCode
+	}
+	if len(res.OutputArguments) < nout {
+		return nil, errors.Errorf("got %d output arguments want %d", len(res.OutputArguments), nout)
+	}
+	return res.OutputArguments, nil
+}
+
+// extensionObject wraps the value with the type id in the GDS namespace.
+// ua.NewExtensionObject cannot be used since the namespace index depends
+// on the server.
+func (g *Client) extensionObject(app *ApplicationRecordDataType) *ua.ExtensionObject {
+	return &ua.ExtensionObject{
+		EncodingMask: ua.ExtensionObjectBinary,
+		TypeID:       &ua.ExpandedNodeID{NodeID: ua.NewNumericNodeID(g.ns, ApplicationRecordDataType_Encoding_DefaultBinary)},
+		Value:        app,
+	}
+}
+
+// nodeID returns the null node id for nil which selects the default.
+func nodeID(id *ua.NodeID) *ua.NodeID {
+	if id == nil {
+		return ua.NewTwoByteNodeID(0)
+	}
+	return id
+}
+
+func requestID(v *ua.Variant) (*ua.NodeID, error) {
+	id, ok := v.Value().(*ua.NodeID)
+	if !ok {
+		return nil, errors.Errorf("invalid request id: %T", v.Value())
+	}
+	return id, nil
+}
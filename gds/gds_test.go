@@ -0,0 +1,208 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package gds
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/goe/verify"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestApplicationRecordDataType(t *testing.T) {
+	registerTypes(2)
+
+	app := &ApplicationRecordDataType{
+		ApplicationID:      ua.NewFourByteNodeID(2, 42),
+		ApplicationURI:     "urn:example:app",
+		ApplicationType:    ua.ApplicationTypeClient,
+		ApplicationNames:   []*ua.LocalizedText{ua.NewLocalizedText("app")},
+		ProductURI:         "urn:example",
+		DiscoveryURLs:      []string{},
+		ServerCapabilities: []string{},
+	}
+	g := &Client{ns: 2}
+	b, err := ua.Encode(g.extensionObject(app))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eo := new(ua.ExtensionObject)
+	if _, err := ua.Decode(b, eo); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", eo.Value, app)
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := &Store{Dir: dir}
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WritePrivateKey(key); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.D.Cmp(key.D) != 0 || got.N.Cmp(key.N) != 0 {
+		t.Fatal("private key mismatch")
+	}
+
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteCertificate(cert, [][]byte{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.Certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiresWithin(c, time.Now(), 2*time.Hour) {
+		t.Fatal("certificate should expire within 2h")
+	}
+	if expiresWithin(c, time.Now(), time.Minute) {
+		t.Fatal("certificate should not expire within 1m")
+	}
+
+	// the issuer list replaces the issuer from the certificate
+	// and the trusted crls remain unchanged.
+	if err := s.WriteTrustList(&ua.TrustListDataType{
+		SpecifiedLists:      uint32(ua.TrustListMasksTrustedCertificates | ua.TrustListMasksIssuerCertificates),
+		TrustedCertificates: [][]byte{{2}, {3}},
+		IssuerCertificates:  [][]byte{{4}},
+		TrustedCrls:         [][]byte{{5}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	files := func(dir string) []string {
+		var names []string
+		fis, _ := ioutil.ReadDir(filepath.Join(s.Dir, dir))
+		for _, fi := range fis {
+			names = append(names, fi.Name())
+		}
+		sort.Strings(names)
+		return names
+	}
+	trusted := []string{thumbprint([]byte{2}) + ".der", thumbprint([]byte{3}) + ".der"}
+	sort.Strings(trusted)
+	verify.Values(t, "trusted", files("trusted/certs"), trusted)
+	verify.Values(t, "issuer", files("issuer/certs"), []string{thumbprint([]byte{4}) + ".der"})
+	verify.Values(t, "crl", files("trusted/crl"), []string(nil))
+}
+
+func TestStoreWriteKeyPair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := &Store{Dir: dir}
+
+	keyPair := func() ([]byte, *rsa.PrivateKey) {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+		cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	match := func() bool {
+		c, err := s.Certificate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := s.PrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.PublicKey.(*rsa.PublicKey).N.Cmp(k.N) == 0
+	}
+
+	cert, key := keyPair()
+	if err := s.WriteKeyPair(cert, [][]byte{{1}}, key); err != nil {
+		t.Fatal(err)
+	}
+	if !match() {
+		t.Fatal("certificate does not match the key")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "issuer", "certs", thumbprint([]byte{1})+".der")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificate cannot be staged. The key must not be replaced.
+	if err := os.Mkdir(s.CertFile()+".tmp", 0700); err != nil {
+		t.Fatal(err)
+	}
+	cert2, key2 := keyPair()
+	if err := s.WriteKeyPair(cert2, nil, key2); err == nil {
+		t.Fatal("got nil want error")
+	}
+	if !match() {
+		t.Fatal("certificate does not match the key")
+	}
+	if _, err := os.Stat(s.KeyFile() + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("staged key not removed: %v", err)
+	}
+	if err := os.Remove(s.CertFile() + ".tmp"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the key cannot be replaced. The previous certificate is restored.
+	if err := os.Remove(s.KeyFile()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(s.KeyFile(), "x"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteKeyPair(cert2, nil, key2); err == nil {
+		t.Fatal("got nil want error")
+	}
+	got, err := ioutil.ReadFile(s.CertFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, cert) {
+		t.Fatal("previous certificate not restored")
+	}
+
+	// without a previous certificate the new one is removed
+	if err := os.Remove(s.CertFile()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteKeyPair(cert2, nil, key2); err == nil {
+		t.Fatal("got nil want error")
+	}
+	if _, err := os.Stat(s.CertFile()); !os.IsNotExist(err) {
+		t.Fatalf("new certificate not removed: %v", err)
+	}
+}
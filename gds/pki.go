@@ -0,0 +1,395 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package gds
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Store is a directory based PKI store with the common layout
+// of OPC UA applications:
+//
+//	own/certs/cert.der     the application certificate
+//	own/private/key.pem    the private key of the application
+//	trusted/certs          trusted certificates
+//	trusted/crl            revocation lists of the trusted certificates
+//	issuer/certs           CA certificates to verify trusted certificates
+//	issuer/crl             revocation lists of the CA certificates
+//
+// The certificate and the key can be loaded with the CertificateFile
+// and PrivateKeyFile options.
+type Store struct {
+	Dir string
+}
+
+// CertFile returns the path of the application certificate.
+func (s *Store) CertFile() string {
+	return filepath.Join(s.Dir, "own", "certs", "cert.der")
+}
+
+// KeyFile returns the path of the private key.
+func (s *Store) KeyFile() string {
+	return filepath.Join(s.Dir, "own", "private", "key.pem")
+}
+
+// Certificate returns the application certificate.
+func (s *Store) Certificate() (*x509.Certificate, error) {
+	b, err := ioutil.ReadFile(s.CertFile())
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(b)
+}
+
+// PrivateKey returns the private key of the application.
+func (s *Store) PrivateKey() (*rsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(s.KeyFile())
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "RSA PRIVATE KEY" {
+		return nil, errors.Errorf("invalid private key in %s", s.KeyFile())
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// WriteCertificate writes the DER encoded application certificate
+// and the certificates of the CAs which issued it.
+func (s *Store) WriteCertificate(cert []byte, issuers [][]byte) error {
+	if err := writeFile(s.CertFile(), cert, 0644); err != nil {
+		return err
+	}
+	for _, b := range issuers {
+		if err := writeFile(filepath.Join(s.Dir, "issuer", "certs", thumbprint(b)+".der"), b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePrivateKey writes the private key in PEM format. The key
+// is only readable by the owner.
+func (s *Store) WritePrivateKey(key *rsa.PrivateKey) error {
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return writeFile(s.KeyFile(), b, 0600)
+}
+
+// WriteKeyPair replaces the application certificate and its private
+// key. Both files are staged before either is replaced. If the key
+// cannot be replaced the previous certificate is restored so that a
+// failure leaves either the old or the new pair in the store.
+func (s *Store) WriteKeyPair(cert []byte, issuers [][]byte, key *rsa.PrivateKey) error {
+	for _, b := range issuers {
+		if err := writeFile(filepath.Join(s.Dir, "issuer", "certs", thumbprint(b)+".der"), b, 0644); err != nil {
+			return err
+		}
+	}
+
+	// keep the previous certificate to roll back
+	old, err := ioutil.ReadFile(s.CertFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	keyTmp, err := stageFile(s.KeyFile(), b, 0600)
+	if err != nil {
+		return err
+	}
+	certTmp, err := stageFile(s.CertFile(), cert, 0644)
+	if err != nil {
+		os.Remove(keyTmp)
+		return err
+	}
+	if err := os.Rename(certTmp, s.CertFile()); err != nil {
+		os.Remove(keyTmp)
+		os.Remove(certTmp)
+		return err
+	}
+	if err := os.Rename(keyTmp, s.KeyFile()); err != nil {
+		os.Remove(keyTmp)
+		if rerr := s.restoreCertificate(old); rerr != nil {
+			return errors.Errorf("cannot replace private key: %s; cannot restore certificate: %s", err, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// restoreCertificate restores the previous certificate or removes the
+// certificate if there was none.
+func (s *Store) restoreCertificate(old []byte) error {
+	if old == nil {
+		return os.Remove(s.CertFile())
+	}
+	return writeFile(s.CertFile(), old, 0644)
+}
+
+// WriteTrustList replaces the lists which are specified in the trust list.
+func (s *Store) WriteTrustList(tl *ua.TrustListDataType) error {
+	lists := []struct {
+		mask  ua.TrustListMasks
+		dir   string
+		ext   string
+		items [][]byte
+	}{
+		{ua.TrustListMasksTrustedCertificates, filepath.Join("trusted", "certs"), ".der", tl.TrustedCertificates},
+		{ua.TrustListMasksTrustedCrls, filepath.Join("trusted", "crl"), ".crl", tl.TrustedCrls},
+		{ua.TrustListMasksIssuerCertificates, filepath.Join("issuer", "certs"), ".der", tl.IssuerCertificates},
+		{ua.TrustListMasksIssuerCrls, filepath.Join("issuer", "crl"), ".crl", tl.IssuerCrls},
+	}
+	for _, l := range lists {
+		if ua.TrustListMasks(tl.SpecifiedLists)&l.mask == 0 {
+			continue
+		}
+		dir := filepath.Join(s.Dir, l.dir)
+		if err := clearDir(dir, l.ext); err != nil {
+			return err
+		}
+		for _, b := range l.items {
+			if err := writeFile(filepath.Join(dir, thumbprint(b)+l.ext), b, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Default values for the CertificateManager.
+const (
+	DefaultRenewBefore   = 14 * 24 * time.Hour
+	DefaultCheckInterval = time.Hour
+	DefaultPollInterval  = 5 * time.Second
+	DefaultKeyBits       = 2048
+)
+
+// CertificateManager keeps the certificate and the trust list of an
+// application in a Store up to date with the GDS. It renews the
+// certificate with a signing request for a new key before it expires
+// or when the GDS reports that an update is required.
+type CertificateManager struct {
+	GDS   *Client
+	Store *Store
+
+	// ApplicationID is the id returned by RegisterApplication.
+	ApplicationID *ua.NodeID
+
+	// CertificateGroupID and CertificateTypeID select the certificate.
+	// nil selects the defaults of the GDS.
+	CertificateGroupID *ua.NodeID
+	CertificateTypeID  *ua.NodeID
+
+	// Subject and DNSNames are used for the certificate signing
+	// request. The GDS may replace them.
+	Subject  pkix.Name
+	DNSNames []string
+
+	// KeyBits is the size of the new RSA keys.
+	// Defaults to DefaultKeyBits.
+	KeyBits int
+
+	// RenewBefore is the time before the expiry of the certificate
+	// when it is renewed. Defaults to DefaultRenewBefore.
+	RenewBefore time.Duration
+
+	// CheckInterval is the interval in which Run checks the
+	// certificate. Defaults to DefaultCheckInterval.
+	CheckInterval time.Duration
+
+	// PollInterval is the interval in which the result of a signing
+	// request is polled. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+
+	// OnRenew is called after a new certificate has been written to
+	// the store, e.g. to reconnect with the new certificate.
+	OnRenew func(cert []byte, key *rsa.PrivateKey)
+}
+
+// NeedsRenewal returns true if the store has no valid certificate, the
+// certificate expires within RenewBefore or the GDS requires an update.
+func (m *CertificateManager) NeedsRenewal() (bool, error) {
+	cert, err := m.Store.Certificate()
+	if err != nil {
+		debug.Printf("gds: no certificate: %v", err)
+		return true, nil
+	}
+	if expiresWithin(cert, time.Now(), durationOrDefault(m.RenewBefore, DefaultRenewBefore)) {
+		return true, nil
+	}
+	return m.GDS.GetCertificateStatus(m.ApplicationID, m.CertificateGroupID, m.CertificateTypeID)
+}
+
+// Renew creates a new key, asks the GDS to sign a certificate for it and
+// writes both to the store. It waits until the GDS has completed the
+// request, which may require the approval of an administrator.
+func (m *CertificateManager) Renew(ctx context.Context) error {
+	bits := m.KeyBits
+	if bits <= 0 {
+		bits = DefaultKeyBits
+	}
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  m.Subject,
+		DNSNames: m.DNSNames,
+	}, key)
+	if err != nil {
+		return err
+	}
+
+	reqID, err := m.GDS.StartSigningRequest(m.ApplicationID, m.CertificateGroupID, m.CertificateTypeID, csr)
+	if err != nil {
+		return err
+	}
+
+	t := time.NewTicker(durationOrDefault(m.PollInterval, DefaultPollInterval))
+	defer t.Stop()
+
+	var res *FinishResult
+	for {
+		res, err = m.GDS.FinishRequest(m.ApplicationID, reqID)
+		if err == nil {
+			break
+		}
+		if err != ua.StatusBadNothingToDo {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	if err := m.Store.WriteKeyPair(res.Certificate, res.IssuerCertificates, key); err != nil {
+		return err
+	}
+	if m.OnRenew != nil {
+		m.OnRenew(res.Certificate, key)
+	}
+	return nil
+}
+
+// UpdateTrustList reads the trust list of the application
+// from the GDS and writes it to the store.
+func (m *CertificateManager) UpdateTrustList() error {
+	id, err := m.GDS.GetTrustList(m.ApplicationID, m.CertificateGroupID)
+	if err != nil {
+		return err
+	}
+	tl, err := m.GDS.ReadTrustList(id)
+	if err != nil {
+		return err
+	}
+	return m.Store.WriteTrustList(tl)
+}
+
+// Run updates the trust list and renews the certificate when necessary
+// every CheckInterval until the context is cancelled. Errors are logged
+// and retried with the next check.
+func (m *CertificateManager) Run(ctx context.Context) error {
+	dlog := debug.NewPrefixLogger("gds: certificate manager: ")
+
+	t := time.NewTicker(durationOrDefault(m.CheckInterval, DefaultCheckInterval))
+	defer t.Stop()
+
+	for {
+		if err := m.UpdateTrustList(); err != nil {
+			dlog.Printf("update trust list failed: %v", err)
+		}
+
+		renew, err := m.NeedsRenewal()
+		switch {
+		case err != nil:
+			dlog.Printf("certificate status failed: %v", err)
+		case renew:
+			dlog.Printf("renewing certificate")
+			if err := m.Renew(ctx); err != nil {
+				dlog.Printf("renew failed: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// expiresWithin returns true if the certificate is
+// not valid at now+d.
+func expiresWithin(cert *x509.Certificate, now time.Time, d time.Duration) bool {
+	return now.Add(d).After(cert.NotAfter)
+}
+
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// thumbprint returns the hex encoded SHA1 hash which is
+// used as file name for certificates and CRLs.
+func thumbprint(b []byte) string {
+	h := sha1.Sum(b)
+	return strings.ToUpper(hex.EncodeToString(h[:]))
+}
+
+// writeFile writes the file atomically and creates the directory.
+func writeFile(filename string, b []byte, perm os.FileMode) error {
+	tmp, err := stageFile(filename, b, perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// stageFile writes the content of the file to a temporary file in the
+// same directory and returns its name. The directory is created.
+func stageFile(filename string, b []byte, perm os.FileMode) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return "", err
+	}
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, perm); err != nil {
+		return "", err
+	}
+	return tmp, nil
+}
+
+// clearDir removes all files with the extension from the directory.
+func clearDir(dir, ext string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
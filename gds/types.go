@@ -0,0 +1,62 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package gds
+
+import (
+	"sync"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// NamespaceURI is the URI of the GDS namespace.
+const NamespaceURI = "http://opcfoundation.org/UA/GDS/"
+
+// Node ids in the GDS namespace.
+//
+// See Opc.Ua.Gds.NodeIds.csv
+const (
+	ApplicationRecordDataType_Encoding_DefaultBinary = 134
+	Directory                                        = 141
+	Directory_FindApplications                       = 143
+	Directory_RegisterApplication                    = 146
+	Directory_StartNewKeyPairRequest                 = 154
+	Directory_StartSigningRequest                    = 157
+	Directory_FinishRequest                          = 163
+	Directory_GetTrustList                           = 204
+	Directory_GetCertificateStatus                   = 222
+)
+
+// ApplicationRecordDataType describes an application
+// registered with the GDS.
+//
+// See Part 12, 6.6.5
+type ApplicationRecordDataType struct {
+	ApplicationID      *ua.NodeID
+	ApplicationURI     string
+	ApplicationType    ua.ApplicationType
+	ApplicationNames   []*ua.LocalizedText
+	ProductURI         string
+	DiscoveryURLs      []string
+	ServerCapabilities []string
+}
+
+var (
+	regMu sync.Mutex
+	reg   = map[uint16]bool{}
+)
+
+// registerTypes registers the GDS data types for the namespace index
+// the GDS uses so that they can be decoded. The index depends on the
+// server and a type can be registered for several indexes.
+func registerTypes(ns uint16) {
+	regMu.Lock()
+	defer regMu.Unlock()
+
+	if reg[ns] {
+		return
+	}
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(ns, ApplicationRecordDataType_Encoding_DefaultBinary), new(ApplicationRecordDataType))
+	reg[ns] = true
+}
@@ -0,0 +1,75 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"github.com/gopcua/opcua/id"
+)
+
+// SetInflightLimits changes the limits for the number of requests in
+// flight. The limits are kept for secure channels which are created
+// during a reconnect. See uasc.Config.MaxInflightRequests for details.
+func (c *Client) SetInflightLimits(max int, perService map[uint16]int, failFast bool) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	c.setInflightLimits(max, perService, failFast)
+}
+
+// setInflightLimits changes the limits. The caller must hold inflightMu.
+func (c *Client) setInflightLimits(max int, perService map[uint16]int, failFast bool) {
+	c.cfg.MaxInflightRequests = max
+	c.cfg.MaxInflightRequestsPerService = perService
+	c.cfg.InflightFailFast = failFast
+	if c.sechan != nil {
+		c.sechan.SetInflightLimits(max, perService, failFast)
+	}
+}
+
+// LimitInflightFromServer derives the per service in-flight limits from
+// the ServerCapabilities of the server and applies them. A browse, query
+// or history read request can use one continuation point per session and
+// node, so the limits for these services are set to the continuation
+// point limits to avoid BadNoContinuationPoints errors.
+//
+// The server does not report how many requests it can process at once
+// and the channel limit and the fail-fast mode remain unchanged. Limits
+// which the server does not report or reports as 0 (no limit) are not set.
+func (c *Client) LimitInflightFromServer() error {
+	caps, err := c.ReadServerCapabilities()
+	if err != nil {
+		return err
+	}
+	c.limitInflight(caps)
+	return nil
+}
+
+// limitInflight sets the per service in-flight limits from the server
+// capabilities.
+func (c *Client) limitInflight(caps *ServerCapabilities) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+
+	perService := make(map[uint16]int)
+	for k, v := range c.cfg.MaxInflightRequestsPerService {
+		perService[k] = v
+	}
+	limits := []struct {
+		n    uint16
+		svcs []uint16
+	}{
+		{caps.MaxBrowseContinuationPoints, []uint16{id.BrowseRequest_Encoding_DefaultBinary, id.BrowseNextRequest_Encoding_DefaultBinary}},
+		{caps.MaxQueryContinuationPoints, []uint16{id.QueryFirstRequest_Encoding_DefaultBinary, id.QueryNextRequest_Encoding_DefaultBinary}},
+		{caps.MaxHistoryContinuationPoints, []uint16{id.HistoryReadRequest_Encoding_DefaultBinary}},
+	}
+	for _, l := range limits {
+		if l.n == 0 {
+			continue
+		}
+		for _, svc := range l.svcs {
+			perService[svc] = int(l.n)
+		}
+	}
+	c.setInflightLimits(c.cfg.MaxInflightRequests, perService, c.cfg.InflightFailFast)
+}
@@ -0,0 +1,85 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"testing"
+
+	"github.com/pascaldekloe/goe/verify"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestLimitInflight(t *testing.T) {
+	const (
+		browse     = id.BrowseRequest_Encoding_DefaultBinary
+		browseNext = id.BrowseNextRequest_Encoding_DefaultBinary
+		read       = id.ReadRequest_Encoding_DefaultBinary
+	)
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		caps       *ServerCapabilities
+		max        int
+		perService map[uint16]int
+	}{
+		{
+			name:       "no limits",
+			caps:       &ServerCapabilities{},
+			perService: map[uint16]int{},
+		},
+		{
+			name:       "from server",
+			caps:       &ServerCapabilities{MaxBrowseContinuationPoints: 2},
+			perService: map[uint16]int{browse: 2, browseNext: 2},
+		},
+		{
+			name:       "configured",
+			opts:       []Option{InflightLimit(5, false), ServiceInflightLimit(read, 3)},
+			caps:       &ServerCapabilities{MaxBrowseContinuationPoints: 2},
+			max:        5,
+			perService: map[uint16]int{read: 3, browse: 2, browseNext: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("opc.tcp://example.com:4840", tt.opts...)
+			c.limitInflight(tt.caps)
+			verify.Values(t, "max", c.cfg.MaxInflightRequests, tt.max)
+			verify.Values(t, "per service", c.cfg.MaxInflightRequestsPerService, tt.perService)
+		})
+	}
+}
+
+func TestLimitInflightFromServer(t *testing.T) {
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		switch r := req.(type) {
+		case *ua.ReadRequest:
+			res := &ua.ReadResponse{}
+			for _, rv := range r.NodesToRead {
+				if rv.NodeID.IntID() == id.Server_ServerCapabilities_MaxBrowseContinuationPoints {
+					res.Results = append(res.Results, &ua.DataValue{Value: ua.MustVariant(uint16(2))})
+					continue
+				}
+				res.Results = append(res.Results, &ua.DataValue{Status: ua.StatusBadNodeIDUnknown})
+			}
+			return h(res)
+		default:
+			t.Fatalf("unexpected request %T", req)
+			return nil
+		}
+	})
+
+	if err := c.LimitInflightFromServer(); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "max", c.cfg.MaxInflightRequests, 0)
+	verify.Values(t, "per service", c.cfg.MaxInflightRequestsPerService, map[uint16]int{
+		id.BrowseRequest_Encoding_DefaultBinary:     2,
+		id.BrowseNextRequest_Encoding_DefaultBinary: 2,
+	})
+}
@@ -0,0 +1,113 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"context"
+	"time"
+
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// DefaultKeepAliveMaxMisses is the number of failed keep-alive reads
+// after which the connection is considered lost.
+const DefaultKeepAliveMaxMisses = 3
+
+// ServerState returns the state of the server as observed by the last
+// keep-alive. It returns ua.ServerStateUnknown if the keep-alive is
+// disabled or has not yet read the state.
+func (c *Client) ServerState() ua.ServerState {
+	return c.serverState.Load().(ua.ServerState)
+}
+
+// monitorKeepAlive reads the server state periodically so that a dead
+// server or a half-open connection is detected even if the client has
+// no outstanding requests. After KeepAliveMaxMisses consecutive failures
+// it reports the error to the connection monitor which then reconnects.
+func (c *Client) monitorKeepAlive(ctx context.Context) {
+	if c.cfg.KeepAliveInterval <= 0 {
+		return
+	}
+
+	dlog := debug.NewPrefixLogger("client: keep-alive: ")
+	defer dlog.Print("done")
+
+	maxMisses := c.cfg.KeepAliveMaxMisses
+	if maxMisses <= 0 {
+		maxMisses = DefaultKeepAliveMaxMisses
+	}
+
+	t := time.NewTicker(c.cfg.KeepAliveInterval)
+	defer t.Stop()
+
+	misses := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		// the connection monitor is already busy
+		if c.State() != Connected {
+			misses = 0
+			continue
+		}
+
+		state, err := c.readServerState()
+		var lost error
+		misses, lost = c.handleKeepAlive(state, err, misses, maxMisses)
+		if lost == nil {
+			continue
+		}
+		// Close waits for this goroutine before it closes the channel
+		select {
+		case c.sechanErr <- lost:
+		default:
+			// there is already an error pending
+		}
+	}
+}
+
+// handleKeepAlive handles the result of a keep-alive read and returns the
+// number of consecutive misses. It returns the error after maxMisses
+// misses to signal that the connection is lost. A change of the server
+// state is sent to the connection state channels.
+func (c *Client) handleKeepAlive(state ua.ServerState, err error, misses, maxMisses int) (int, error) {
+	dlog := debug.NewPrefixLogger("client: keep-alive: ")
+
+	if err != nil {
+		misses++
+		dlog.Printf("miss %d/%d: %v", misses, maxMisses, err)
+		if misses < maxMisses {
+			return misses, nil
+		}
+		return 0, err
+	}
+
+	if prev := c.ServerState(); prev != state {
+		dlog.Printf("server state %s -> %s", prev, state)
+		c.serverState.Store(state)
+		c.stateChsMu.Lock()
+		c.sendConnState(&ConnStateChange{
+			State:       c.State(),
+			Action:      ServerStateChanged,
+			ServerState: state,
+		})
+		c.stateChsMu.Unlock()
+	}
+	return 0, nil
+}
+
+// readServerState reads the Server_ServerStatus_State variable.
+func (c *Client) readServerState() (ua.ServerState, error) {
+	v, err := c.Node(ua.NewNumericNodeID(0, id.Server_ServerStatus_State)).Value()
+	if err != nil {
+		return ua.ServerStateUnknown, err
+	}
+	return ua.ServerState(v.Int()), nil
+}
@@ -0,0 +1,63 @@
+package opcua
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestHandleKeepAliveMisses(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+
+	misses := 0
+	for i := 1; i < 3; i++ {
+		var lost error
+		misses, lost = c.handleKeepAlive(ua.ServerStateUnknown, io.EOF, misses, 3)
+		if misses != i || lost != nil {
+			t.Fatalf("miss %d: got %d, %v want %d, nil", i, misses, lost, i)
+		}
+	}
+
+	// a successful read resets the misses
+	if n, lost := c.handleKeepAlive(ua.ServerStateRunning, nil, misses, 3); n != 0 || lost != nil {
+		t.Fatalf("got %d, %v want 0, nil", n, lost)
+	}
+
+	misses = 0
+	for i := 0; i < 2; i++ {
+		misses, _ = c.handleKeepAlive(ua.ServerStateUnknown, io.EOF, misses, 3)
+	}
+	misses, lost := c.handleKeepAlive(ua.ServerStateUnknown, io.EOF, misses, 3)
+	if misses != 0 || lost != io.EOF {
+		t.Fatalf("got %d, %v want 0, %v", misses, lost, io.EOF)
+	}
+}
+
+func TestHandleKeepAliveServerState(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	ch := make(chan *ConnStateChange, 4)
+	c.NotifyConnState(ch)
+
+	c.handleKeepAlive(ua.ServerStateRunning, nil, 0, 3)
+	if got, want := c.ServerState(), ua.ServerStateRunning; got != want {
+		t.Fatalf("got server state %v want %v", got, want)
+	}
+	verify.Values(t, "", <-ch, &ConnStateChange{State: Closed, Action: ServerStateChanged, ServerState: ua.ServerStateRunning})
+
+	// no notification without a change
+	c.handleKeepAlive(ua.ServerStateRunning, nil, 0, 3)
+	if len(ch) != 0 {
+		t.Fatalf("got %d notifications want 0", len(ch))
+	}
+
+	c.handleKeepAlive(ua.ServerStateShutdown, nil, 0, 3)
+	verify.Values(t, "", <-ch, &ConnStateChange{State: Closed, Action: ServerStateChanged, ServerState: ua.ServerStateShutdown})
+
+	// a failed read does not change the state
+	c.handleKeepAlive(ua.ServerStateUnknown, io.EOF, 0, 3)
+	if got, want := c.ServerState(), ua.ServerStateShutdown; got != want {
+		t.Fatalf("got server state %v want %v", got, want)
+	}
+}
@@ -0,0 +1,456 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// DefaultMaxConcurrentConnects is the default number of servers
+// the Manager connects to in parallel.
+const DefaultMaxConcurrentConnects = 8
+
+// ServerNodeID is a node id qualified with the name of the server
+// in a Manager.
+type ServerNodeID struct {
+	Server string
+	NodeID *ua.NodeID
+}
+
+// ParseServerNodeID parses a node id in the format "<server>/<node id>",
+// e.g. "plc1/ns=2;s=Temperature". The server name must not contain a
+// slash.
+func ParseServerNodeID(s string) (*ServerNodeID, error) {
+	p := strings.SplitN(s, "/", 2)
+	if len(p) != 2 || p[0] == "" {
+		return nil, errors.Errorf("invalid server node id: %s", s)
+	}
+	id, err := ua.ParseNodeID(p[1])
+	if err != nil {
+		return nil, err
+	}
+	return &ServerNodeID{Server: p[0], NodeID: id}, nil
+}
+
+// String returns the node id in the format accepted by ParseServerNodeID.
+func (n *ServerNodeID) String() string {
+	return n.Server + "/" + n.NodeID.String()
+}
+
+// ServerHealth is the health of a server in a Manager.
+type ServerHealth struct {
+	Name     string
+	Endpoint string
+
+	// State is the connection state of the client.
+	State ConnState
+
+	// ServerState is the state of the server as observed by the
+	// keep-alive of the client.
+	ServerState ua.ServerState
+
+	// Err is the error of the last connect attempt or the error
+	// after which the client has stopped reconnecting.
+	Err error
+}
+
+// Healthy returns true if the client is connected.
+func (h *ServerHealth) Healthy() bool {
+	return h.State == Connected
+}
+
+// ManagerError contains the errors of the servers
+// the Manager could not connect to.
+type ManagerError struct {
+	Errs map[string]error
+}
+
+func (e *ManagerError) Error() string {
+	names := make([]string, 0, len(e.Errs))
+	for name := range e.Errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var msgs []string
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", name, e.Errs[name]))
+	}
+	return "opcua: " + strings.Join(msgs, ", ")
+}
+
+// Manager owns the clients for a set of servers which are identified
+// by name. It connects the clients in parallel, reports their health
+// and routes reads to the right server.
+//
+// The clients reconnect on their own once they are connected. Calling
+// Connect again connects only the servers which are not connected, e.g.
+// because the first attempt failed or the client has given up.
+type Manager struct {
+	// MaxConcurrentConnects limits the number of servers which are
+	// connected in parallel. Defaults to DefaultMaxConcurrentConnects.
+	MaxConcurrentConnects int
+
+	opts []Option
+
+	mu      sync.RWMutex
+	servers map[string]*managedServer
+}
+
+// managedServer is a server of a Manager.
+type managedServer struct {
+	name     string
+	endpoint string
+	opts     []Option
+
+	mu   sync.Mutex
+	c    *Client
+	err  error
+	used bool // c has been connected and must be closed
+
+	// connecting is closed when the connect in progress has finished.
+	connecting chan struct{}
+}
+
+// NewManager creates a new Manager. The options are
+// applied to the clients of all servers.
+func NewManager(opts ...Option) *Manager {
+	return &Manager{
+		MaxConcurrentConnects: DefaultMaxConcurrentConnects,
+		opts:                  opts,
+		servers:               make(map[string]*managedServer),
+	}
+}
+
+// Add adds a server to the manager. The options are applied after the
+// options of the manager. The server is not connected until Connect is
+// called.
+func (m *Manager) Add(name, endpoint string, opts ...Option) error {
+	if name == "" || strings.Contains(name, "/") {
+		return errors.Errorf("invalid server name: %q", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.servers[name]; ok {
+		return errors.Errorf("server %s already exists", name)
+	}
+	s := &managedServer{
+		name:     name,
+		endpoint: endpoint,
+		opts:     append(append([]Option{}, m.opts...), opts...),
+	}
+	s.c = NewClient(endpoint, s.opts...)
+	m.servers[name] = s
+	return nil
+}
+
+// Remove closes the client of the server and removes it from the manager.
+func (m *Manager) Remove(name string) error {
+	m.mu.Lock()
+	s, ok := m.servers[name]
+	delete(m.servers, name)
+	m.mu.Unlock()
+
+	if !ok {
+		return errors.Errorf("unknown server %s", name)
+	}
+	return s.close()
+}
+
+// Names returns the sorted names of all servers.
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.servers))
+	for name := range m.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Client returns the client of the server or nil if the server is unknown.
+func (m *Manager) Client(name string) *Client {
+	s := m.server(name)
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c
+}
+
+// Connect connects all servers which are not connected with at most
+// MaxConcurrentConnects connects in parallel. It returns a *ManagerError
+// with the errors of the servers which could not be connected. The
+// other servers are connected in any case.
+func (m *Manager) Connect(ctx context.Context) error {
+	max := m.MaxConcurrentConnects
+	if max <= 0 {
+		max = DefaultMaxConcurrentConnects
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(map[string]error)
+		sem  = make(chan struct{}, max)
+	)
+	for _, s := range m.list() {
+		wg.Add(1)
+		go func(s *managedServer) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				errs[s.name] = ctx.Err()
+				mu.Unlock()
+				return
+			}
+
+			if err := s.connect(ctx); err != nil {
+				mu.Lock()
+				errs[s.name] = err
+				mu.Unlock()
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &ManagerError{Errs: errs}
+	}
+	return nil
+}
+
+// Health returns the health of all servers sorted by name.
+func (m *Manager) Health() []*ServerHealth {
+	servers := m.list()
+	h := make([]*ServerHealth, len(servers))
+	for i, s := range servers {
+		h[i] = s.health()
+	}
+	return h
+}
+
+// Read reads the values of the nodes from their servers. The nodes are
+// grouped by server and every server is read with a single request in
+// parallel. The results are in the order of the node ids.
+//
+// Errors of a single server do not fail the read but are returned as the
+// status of the results for that server: StatusBadNodeIDUnknown for an
+// unknown server, StatusBadServerNotConnected if the client is not
+// connected and the status of the failed request otherwise.
+func (m *Manager) Read(ids ...*ServerNodeID) ([]*ua.DataValue, error) {
+	if len(ids) == 0 {
+		return nil, errors.Errorf("no nodes to read")
+	}
+
+	// group the nodes by server and remember their position
+	type batch struct {
+		rvs []*ua.ReadValueID
+		idx []int
+	}
+	batches := make(map[string]*batch)
+	for i, id := range ids {
+		if id == nil || id.NodeID == nil {
+			return nil, errors.Errorf("invalid node id at index %d", i)
+		}
+		b := batches[id.Server]
+		if b == nil {
+			b = &batch{}
+			batches[id.Server] = b
+		}
+		b.rvs = append(b.rvs, &ua.ReadValueID{NodeID: id.NodeID})
+		b.idx = append(b.idx, i)
+	}
+
+	results := make([]*ua.DataValue, len(ids))
+	fail := func(b *batch, status ua.StatusCode) {
+		for _, i := range b.idx {
+			results[i] = &ua.DataValue{EncodingMask: ua.DataValueStatusCode, Status: status}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for name, b := range batches {
+		c := m.Client(name)
+		if c == nil {
+			fail(b, ua.StatusBadNodeIDUnknown)
+			continue
+		}
+		if c.State() != Connected {
+			fail(b, ua.StatusBadServerNotConnected)
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, c *Client, b *batch) {
+			defer wg.Done()
+
+			res, err := c.Read(&ua.ReadRequest{
+				NodesToRead:        b.rvs,
+				TimestampsToReturn: ua.TimestampsToReturnBoth,
+			})
+			switch {
+			case err != nil:
+				debug.Printf("manager: %s: read failed: %v", name, err)
+				status, ok := err.(ua.StatusCode)
+				if !ok {
+					status = ua.StatusBadCommunicationError
+				}
+				fail(b, status)
+			case len(res.Results) != len(b.rvs):
+				fail(b, ua.StatusBadUnexpectedError)
+			default:
+				for j, dv := range res.Results {
+					results[b.idx[j]] = dv
+				}
+			}
+		}(name, c, b)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// Close closes the clients of all servers.
+func (m *Manager) Close() error {
+	var firstErr error
+	for _, s := range m.list() {
+		if err := s.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m *Manager) server(name string) *managedServer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.servers[name]
+}
+
+// list returns the servers sorted by name.
+func (m *Manager) list() []*managedServer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	servers := make([]*managedServer, 0, len(m.servers))
+	for _, s := range m.servers {
+		servers = append(servers, s)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].name < servers[j].name })
+	return servers
+}
+
+// connect connects the client if it is not connected. A client which has
+// been connected before is replaced since it cannot be connected again.
+// The client is connected without holding the lock so that Client and
+// Health do not block while the server is dialed. Concurrent calls wait
+// for the connect in progress.
+func (s *managedServer) connect(ctx context.Context) error {
+	s.mu.Lock()
+	if done := s.connecting; done != nil {
+		s.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.err
+	}
+	if s.c.State() != Closed {
+		s.mu.Unlock()
+		return nil
+	}
+	var old *Client
+	if s.used {
+		old = s.c
+		s.c = NewClient(s.endpoint, s.opts...)
+		s.used = false
+	}
+	c, done := s.c, make(chan struct{})
+	s.connecting = done
+	s.mu.Unlock()
+
+	if old != nil {
+		_ = old.Close()
+	}
+	err := c.Connect(ctx)
+
+	s.mu.Lock()
+	s.connecting = nil
+	close(done)
+	if s.c != c {
+		// the server has been closed while connecting
+		s.mu.Unlock()
+		if err == nil {
+			_ = c.Close()
+		}
+		return errors.Errorf("server %s closed while connecting", s.name)
+	}
+	defer s.mu.Unlock()
+
+	s.err = err
+	if err != nil {
+		// Connect closes the client if it fails after the secure
+		// channel has been established. Start over next time.
+		s.c = NewClient(s.endpoint, s.opts...)
+		return err
+	}
+	s.used = true
+	return nil
+}
+
+func (s *managedServer) health() *ServerHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := &ServerHealth{
+		Name:        s.name,
+		Endpoint:    s.endpoint,
+		State:       s.c.State(),
+		ServerState: s.c.ServerState(),
+		Err:         s.err,
+	}
+	if err := s.c.Err(); err != nil {
+		h.Err = err
+	}
+	return h
+}
+
+func (s *managedServer) close() error {
+	s.mu.Lock()
+	if !s.used && s.connecting == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	// a connect in progress closes its client when it sees the new one
+	c, used := s.c, s.used
+	s.c = NewClient(s.endpoint, s.opts...)
+	s.used = false
+	s.mu.Unlock()
+
+	if !used {
+		return nil
+	}
+	return c.Close()
+}
@@ -0,0 +1,144 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/goe/verify"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestParseServerNodeID(t *testing.T) {
+	tests := []struct {
+		s    string
+		want *ServerNodeID
+		err  bool
+	}{
+		{s: "plc1/i=2258", want: &ServerNodeID{Server: "plc1", NodeID: ua.NewFourByteNodeID(0, 2258)}},
+		{s: "plc1/ns=2;s=a/b", want: &ServerNodeID{Server: "plc1", NodeID: ua.NewStringNodeID(2, "a/b")}},
+		{s: "i=2258", err: true},
+		{s: "/i=2258", err: true},
+		{s: "plc1/ns=x;i=1", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseServerNodeID(tt.s)
+			if got, want := err != nil, tt.err; got != want {
+				t.Fatalf("got error %v want %v", err, want)
+			}
+			verify.Values(t, "", got, tt.want)
+			if got != nil {
+				verify.Values(t, "", got.String(), tt.s)
+			}
+		})
+	}
+}
+
+func TestManager(t *testing.T) {
+	m := NewManager()
+	if err := m.Add("plc1", "opc.tcp://plc1:4840"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("plc1", "opc.tcp://plc1:4840"); err == nil {
+		t.Fatal("got nil want error for duplicate server")
+	}
+	if err := m.Add("a/b", "opc.tcp://plc2:4840"); err == nil {
+		t.Fatal("got nil want error for invalid name")
+	}
+	if err := m.Add("plc0", "opc.tcp://plc0:4840"); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "names", m.Names(), []string{"plc0", "plc1"})
+
+	res, err := m.Read(
+		&ServerNodeID{Server: "plc1", NodeID: ua.NewNumericNodeID(0, 2258)},
+		&ServerNodeID{Server: "plc9", NodeID: ua.NewNumericNodeID(0, 2258)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := []ua.StatusCode{res[0].Status, res[1].Status}
+	verify.Values(t, "status", status, []ua.StatusCode{ua.StatusBadServerNotConnected, ua.StatusBadNodeIDUnknown})
+
+	h := m.Health()
+	verify.Values(t, "health", len(h), 2)
+	verify.Values(t, "health", h[0].Name, "plc0")
+	verify.Values(t, "health", h[0].Healthy(), false)
+
+	if err := m.Remove("plc0"); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "names", m.Names(), []string{"plc1"})
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManagerConnectUnlocked(t *testing.T) {
+	// the server accepts the connection but never answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	conns := make(chan net.Conn, 1)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- c
+		}
+	}()
+
+	m := NewManager()
+	if err := m.Add("plc1", "opc.tcp://"+l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- m.Connect(context.Background()) }()
+
+	// wait until the connect is in progress
+	s := m.server("plc1")
+	for {
+		s.mu.Lock()
+		connecting := s.connecting != nil
+		s.mu.Unlock()
+		if connecting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	health := make(chan []*ServerHealth)
+	go func() { health <- m.Health() }()
+	select {
+	case h := <-health:
+		verify.Values(t, "health", h[0].Healthy(), false)
+	case <-time.After(time.Second):
+		t.Fatal("Health blocked by connect")
+	}
+
+	// fail the connect
+	(<-conns).Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("got nil want error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connect did not return")
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
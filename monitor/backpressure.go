@@ -0,0 +1,183 @@
+package monitor
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Backpressure defines what a subscription does when its consumer does not
+// keep up and the delivery queue is full.
+type Backpressure int
+
+const (
+	// BackpressureDropNewest drops new messages and sends ErrSlowConsumer
+	// to the ErrHandler. This is the default.
+	BackpressureDropNewest Backpressure = iota
+
+	// BackpressureDropOldest drops the oldest queued message to make room
+	// for the new one and sends ErrSlowConsumer to the ErrHandler.
+	BackpressureDropOldest
+
+	// BackpressureBlock waits until the consumer has caught up. Once the
+	// internal buffer of the subscription is full as well, the client
+	// keeps the following notifications of this subscription in memory
+	// and does not acknowledge them until they have been delivered. The
+	// other subscriptions of the client are not affected.
+	//
+	// Note that the server drops unacknowledged notifications from its
+	// retransmission queue when it is full. The notifications in the
+	// queue and in the internal buffer have already been acknowledged.
+	BackpressureBlock
+
+	// BackpressureCoalesce keeps only the latest value per monitored item
+	// in the queue. Events are never coalesced and the oldest message is
+	// dropped if the queue is full nevertheless.
+	BackpressureCoalesce
+)
+
+// delivery is a queued message for the consumer.
+type delivery struct {
+	// handle is the client handle of a data change or 0 if
+	// the message must not be coalesced.
+	handle uint32
+
+	// msg is a *DataChangeMessage, *TypedMessage or *EventMessage.
+	msg interface{}
+}
+
+// SetBackpressure sets the backpressure strategy for new subscriptions.
+func (m *NodeMonitor) SetBackpressure(b Backpressure) {
+	atomic.StoreInt32(&m.backpressure, int32(b))
+}
+
+// SetBackpressure changes the backpressure strategy of the subscription.
+func (s *Subscription) SetBackpressure(b Backpressure) {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+
+	s.backpressure = b
+	if b != BackpressureCoalesce {
+		s.latest = make(map[uint32]*delivery)
+	}
+	// wake up a blocked producer
+	signal(s.qspace)
+}
+
+// Blocked returns the number of messages which had to wait for the
+// consumer with BackpressureBlock.
+func (s *Subscription) Blocked() uint64 {
+	return atomic.LoadUint64(&s.blocked)
+}
+
+// Coalesced returns the number of values which have been replaced by a
+// newer value with BackpressureCoalesce.
+func (s *Subscription) Coalesced() uint64 {
+	return atomic.LoadUint64(&s.coalesced)
+}
+
+// enqueue adds the message to the delivery queue and applies the
+// backpressure strategy if the queue is full.
+func (s *Subscription) enqueue(ctx context.Context, handle uint32, msg interface{}) {
+	waited := false
+	for {
+		s.qmu.Lock()
+		if s.backpressure == BackpressureCoalesce && handle != 0 {
+			if d := s.latest[handle]; d != nil {
+				d.msg = msg
+				s.qmu.Unlock()
+				atomic.AddUint64(&s.coalesced, 1)
+				return
+			}
+		}
+
+		dropped := false
+		if len(s.queue) >= s.queueLen {
+			switch s.backpressure {
+			case BackpressureBlock:
+				s.qmu.Unlock()
+				if !waited {
+					waited = true
+					atomic.AddUint64(&s.blocked, 1)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-s.closed:
+					return
+				case <-s.qspace:
+				}
+				continue
+
+			case BackpressureDropOldest, BackpressureCoalesce:
+				s.pop()
+				dropped = true
+
+			default:
+				s.qmu.Unlock()
+				atomic.AddUint64(&s.dropped, 1)
+				s.sendError(ErrSlowConsumer)
+				return
+			}
+		}
+
+		d := &delivery{handle: handle, msg: msg}
+		s.queue = append(s.queue, d)
+		if s.backpressure == BackpressureCoalesce && handle != 0 {
+			s.latest[handle] = d
+		}
+		s.qmu.Unlock()
+		signal(s.qready)
+
+		if dropped {
+			atomic.AddUint64(&s.dropped, 1)
+			s.sendError(ErrSlowConsumer)
+		}
+		return
+	}
+}
+
+// pop removes the oldest message from the queue.
+//
+// The caller must hold the qmu lock.
+func (s *Subscription) pop() *delivery {
+	d := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	if s.latest[d.handle] == d {
+		delete(s.latest, d.handle)
+	}
+	return d
+}
+
+// deliver hands the queued messages to the consumer.
+func (s *Subscription) deliver(ctx context.Context, notifyCh chan<- *DataChangeMessage, cb MsgHandler) {
+	for {
+		s.qmu.Lock()
+		if len(s.queue) == 0 {
+			s.qmu.Unlock()
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.closed:
+				return
+			case <-s.qready:
+			}
+			continue
+		}
+		d := s.pop()
+		s.qmu.Unlock()
+		signal(s.qspace)
+
+		if !s.dispatch(ctx, d.msg, notifyCh, cb) {
+			return
+		}
+	}
+}
+
+// signal notifies a waiting goroutine without blocking.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,239 @@
+package monitor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+// newTestSubscription returns a subscription without a client
+// which only has a delivery queue.
+func newTestSubscription(queueLen int, b Backpressure) *Subscription {
+	m := &NodeMonitor{}
+	m.SetBackpressure(b)
+	return &Subscription{
+		monitor:      m,
+		closed:       make(chan struct{}),
+		handles:      make(map[uint32]*ua.NodeID),
+		eventFields:  make(map[uint32][]string),
+		itemLookup:   make(map[uint32]Item),
+		types:        make(map[uint32]reflect.Type),
+		queueLen:     queueLen,
+		latest:       make(map[uint32]*delivery),
+		backpressure: b,
+		qready:       make(chan struct{}, 1),
+		qspace:       make(chan struct{}, 1),
+	}
+}
+
+// queued returns the queued messages.
+func queued(s *Subscription) []interface{} {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	var msgs []interface{}
+	for _, d := range s.queue {
+		msgs = append(msgs, d.msg)
+	}
+	return msgs
+}
+
+func TestEnqueue(t *testing.T) {
+	type msg struct {
+		handle uint32
+		v      string
+	}
+	tests := []struct {
+		name      string
+		b         Backpressure
+		msgs      []msg
+		want      []interface{}
+		dropped   uint64
+		coalesced uint64
+	}{
+		{
+			name: "drop newest",
+			b:    BackpressureDropNewest,
+			msgs: []msg{{1, "a"}, {2, "b"}, {3, "c"}},
+			want: []interface{}{"a", "b"},
+			// c is dropped
+			dropped: 1,
+		},
+		{
+			name:    "drop oldest",
+			b:       BackpressureDropOldest,
+			msgs:    []msg{{1, "a"}, {2, "b"}, {3, "c"}},
+			want:    []interface{}{"b", "c"},
+			dropped: 1,
+		},
+		{
+			name: "drop oldest keeps values of the same item",
+			b:    BackpressureDropOldest,
+			msgs: []msg{{1, "a"}, {1, "b"}},
+			want: []interface{}{"a", "b"},
+		},
+		{
+			name:      "coalesce in place",
+			b:         BackpressureCoalesce,
+			msgs:      []msg{{1, "a"}, {2, "b"}, {1, "c"}},
+			want:      []interface{}{"c", "b"},
+			coalesced: 1,
+		},
+		{
+			name:    "coalesce drops oldest when full",
+			b:       BackpressureCoalesce,
+			msgs:    []msg{{1, "a"}, {2, "b"}, {3, "c"}, {1, "d"}},
+			want:    []interface{}{"c", "d"},
+			dropped: 2,
+		},
+		{
+			name:    "events are not coalesced",
+			b:       BackpressureCoalesce,
+			msgs:    []msg{{0, "a"}, {0, "b"}, {0, "c"}},
+			want:    []interface{}{"b", "c"},
+			dropped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSubscription(2, tt.b)
+			for _, m := range tt.msgs {
+				s.enqueue(context.Background(), m.handle, m.v)
+			}
+			verify.Values(t, "queue", queued(s), tt.want)
+			if got := s.Dropped(); got != tt.dropped {
+				t.Errorf("got %d dropped want %d", got, tt.dropped)
+			}
+			if got := s.Coalesced(); got != tt.coalesced {
+				t.Errorf("got %d coalesced want %d", got, tt.coalesced)
+			}
+			if got := s.Blocked(); got != 0 {
+				t.Errorf("got %d blocked want 0", got)
+			}
+		})
+	}
+}
+
+func TestEnqueueCoalesceAfterPop(t *testing.T) {
+	s := newTestSubscription(2, BackpressureCoalesce)
+	s.enqueue(context.Background(), 1, "a")
+
+	s.qmu.Lock()
+	s.pop()
+	s.qmu.Unlock()
+
+	// the delivered value must not be replaced
+	s.enqueue(context.Background(), 1, "b")
+	verify.Values(t, "queue", queued(s), []interface{}{"b"})
+	if got := s.Coalesced(); got != 0 {
+		t.Fatalf("got %d coalesced want 0", got)
+	}
+}
+
+func TestEnqueueBlock(t *testing.T) {
+	s := newTestSubscription(1, BackpressureBlock)
+	s.enqueue(context.Background(), 1, "a")
+
+	done := make(chan struct{})
+	go func() {
+		s.enqueue(context.Background(), 2, "b")
+		s.enqueue(context.Background(), 3, "c")
+		close(done)
+	}()
+
+	for _, want := range []string{"a", "b"} {
+		// wait until the producer is blocked
+		time.Sleep(10 * time.Millisecond)
+		verify.Values(t, "queue", queued(s), []interface{}{want})
+
+		s.qmu.Lock()
+		s.pop()
+		s.qmu.Unlock()
+		signal(s.qspace)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("producer still blocked")
+	}
+	verify.Values(t, "queue", queued(s), []interface{}{"c"})
+	if got, want := s.Blocked(), uint64(2); got != want {
+		t.Fatalf("got %d blocked want %d", got, want)
+	}
+	if got := s.Dropped(); got != 0 {
+		t.Fatalf("got %d dropped want 0", got)
+	}
+
+	// closing the subscription releases a blocked producer
+	go func() {
+		s.enqueue(context.Background(), 4, "d")
+		close(s.qready)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(s.closed)
+	select {
+	case <-s.qready:
+	case <-time.After(time.Second):
+		t.Fatal("producer still blocked after close")
+	}
+}
+
+func TestSetBackpressure(t *testing.T) {
+	s := newTestSubscription(2, BackpressureCoalesce)
+	s.enqueue(context.Background(), 1, "a")
+	s.SetBackpressure(BackpressureDropOldest)
+	s.enqueue(context.Background(), 1, "b")
+	verify.Values(t, "queue", queued(s), []interface{}{"a", "b"})
+}
+
+func TestDeliver(t *testing.T) {
+	s := newTestSubscription(10, BackpressureBlock)
+	dc := &DataChangeMessage{NodeID: ua.NewNumericNodeID(0, 1)}
+	typed := &TypedMessage{DataChangeMessage: &DataChangeMessage{NodeID: ua.NewNumericNodeID(0, 2)}, Value: 1.5}
+	ev := &EventMessage{NodeID: ua.NewNumericNodeID(0, 3)}
+
+	notifyCh := make(chan *DataChangeMessage, 3)
+	typedCh := make(chan *TypedMessage, 3)
+	eventCh := make(chan *EventMessage, 3)
+	s.typedCh = typedCh
+	s.eventCh = eventCh
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.deliver(ctx, notifyCh, nil)
+
+	s.enqueue(ctx, 1, dc)
+	s.enqueue(ctx, 2, typed)
+	s.enqueue(ctx, 0, ev)
+
+	timeout := time.After(time.Second)
+	select {
+	case got := <-notifyCh:
+		verify.Values(t, "data change", got, dc)
+	case <-timeout:
+		t.Fatal("no data change")
+	}
+	select {
+	case got := <-typedCh:
+		verify.Values(t, "typed", got, typed)
+	case <-timeout:
+		t.Fatal("no typed message")
+	}
+	select {
+	case got := <-eventCh:
+		verify.Values(t, "event", got, ev)
+	case <-timeout:
+		t.Fatal("no event")
+	}
+	// the counter is updated after the message has been sent
+	for i := 0; i < 100 && s.Delivered() < 3; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if got, want := s.Delivered(), uint64(3); got != want {
+		t.Fatalf("got %d delivered want %d", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package monitor
+
+import (
+	"reflect"
+
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// DefaultEventQueueSize is the queue size of event items if the request does not set one
+var DefaultEventQueueSize uint32 = 100
+
+// EventRequest is a struct to manage a request to monitor the events of a notifier node
+type EventRequest struct {
+	// NodeID is the notifier, e.g. the Server object
+	NodeID *ua.NodeID
+
+	// Filter selects the event fields and the events, see ua.EventFilterBuilder
+	Filter *ua.EventFilter
+
+	// QueueSize defaults to DefaultEventQueueSize
+	QueueSize uint32
+}
+
+// EventMessage represents an event from the server. The fields are
+// keyed by the browse paths of the select clauses of the filter.
+type EventMessage struct {
+	Fields map[string]*ua.Variant
+	Error  error
+	NodeID *ua.NodeID
+}
+
+// Value returns the value of the field or nil if the field does not exist.
+func (m *EventMessage) Value(field string) interface{} {
+	v := m.Fields[field]
+	if v == nil {
+		return nil
+	}
+	return v.Value()
+}
+
+// Unmarshal copies the fields into the struct pointed to by v. Struct
+// fields are matched by the browse path in the `opcua` tag or by their
+// name. Fields tagged with "-" are skipped. Null values leave the
+// struct field unchanged. See TypedMessage for the conversions.
+//
+//	type Alarm struct {
+//		Severity uint16
+//		Message  string
+//		Active   bool `opcua:"ActiveState/Id"`
+//	}
+func (m *EventMessage) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("unmarshal: %T is not a pointer to a struct", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" {
+			continue // unexported
+		}
+		name := sf.Name
+		if tag, ok := sf.Tag.Lookup("opcua"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+
+		val := m.Value(name)
+		if val == nil {
+			continue
+		}
+		fv, err := convert(val, sf.Type)
+		if err != nil {
+			return errors.Errorf("unmarshal: field %s: %s", sf.Name, err)
+		}
+		rv.Field(i).Set(fv)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestEventMessage(t *testing.T) {
+	nid := ua.NewNumericNodeID(0, 2253)
+	s := newTestSubscription(1, BackpressureDropNewest)
+	s.handles[1] = nid
+	s.eventFields[1] = []string{"Severity", "Message"}
+
+	tests := []struct {
+		name string
+		ev   *ua.EventFieldList
+		want *EventMessage
+		err  string
+	}{
+		{
+			name: "fields",
+			ev:   &ua.EventFieldList{ClientHandle: 1, EventFields: []*ua.Variant{ua.MustVariant(uint16(500)), ua.MustVariant("hot")}},
+			want: &EventMessage{
+				NodeID: nid,
+				Fields: map[string]*ua.Variant{"Severity": ua.MustVariant(uint16(500)), "Message": ua.MustVariant("hot")},
+			},
+		},
+		{
+			name: "unknown handle",
+			ev:   &ua.EventFieldList{ClientHandle: 2},
+			want: &EventMessage{},
+			err:  "opcua: handle 2 not found",
+		},
+		{
+			name: "field count",
+			ev:   &ua.EventFieldList{ClientHandle: 1, EventFields: []*ua.Variant{ua.MustVariant(uint16(500))}},
+			want: &EventMessage{NodeID: nid},
+			err:  "opcua: got 1 event fields want 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.eventMessage(tt.ev)
+			if got.Error != nil {
+				if got, want := got.Error.Error(), tt.err; got != want {
+					t.Fatalf("got error %q want %q", got, want)
+				}
+				got.Error = nil
+			} else if tt.err != "" {
+				t.Fatalf("got nil want error %q", tt.err)
+			}
+			verify.Values(t, "", got, tt.want)
+		})
+	}
+}
+
+func TestEventMessageUnmarshal(t *testing.T) {
+	type alarm struct {
+		Severity   uint16
+		Message    string
+		Active     bool    `opcua:"ActiveState/Id"`
+		Skipped    string  `opcua:"-"`
+		Missing    float64 // keeps its value
+		unexported string
+	}
+
+	msg := &EventMessage{
+		Fields: map[string]*ua.Variant{
+			"Severity":       ua.MustVariant(uint32(500)),
+			"Message":        ua.MustVariant(&ua.LocalizedText{Text: "hot"}),
+			"ActiveState/Id": ua.MustVariant(true),
+			"Skipped":        ua.MustVariant("x"),
+			"Missing":        ua.MustVariant(nil),
+		},
+	}
+	got := alarm{Skipped: "keep", Missing: 1.5}
+	if err := msg.Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", got, alarm{Severity: 500, Message: "hot", Active: true, Skipped: "keep", Missing: 1.5})
+
+	if err := msg.Unmarshal(got); err == nil {
+		t.Fatal("got nil want error for non-pointer")
+	}
+
+	// the value does not fit into the field
+	msg.Fields["Severity"] = ua.MustVariant(uint32(70000))
+	if err := msg.Unmarshal(&got); err == nil {
+		t.Fatal("got nil want error for overflow")
+	}
+}
+
+func TestDispatchDataChangeWithoutHandler(t *testing.T) {
+	s := newTestSubscription(1, BackpressureDropNewest)
+	s.eventCB = func(*Subscription, *EventMessage) {}
+
+	errs := make(chan error, 1)
+	s.monitor.SetErrorHandler(func(_ *opcua.Client, _ *Subscription, err error) {
+		errs <- err
+	})
+
+	msg := &DataChangeMessage{NodeID: ua.NewNumericNodeID(0, 1)}
+	if !s.dispatch(context.Background(), msg, nil, nil) {
+		t.Fatal("got false want true")
+	}
+	select {
+	case err := <-errs:
+		if got, want := err.Error(), "opcua: data change from i=1 without data change handler"; got != want {
+			t.Fatalf("got error %q want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no error")
+	}
+	if got := s.Delivered(); got != 0 {
+		t.Fatalf("got %d delivered want 0", got)
+	}
+}
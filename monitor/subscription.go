@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"context"
+	"reflect"
 	"sync"
 	"sync/atomic"
 
@@ -18,9 +19,16 @@ var (
 	ErrSlowConsumer = errors.New("slow consumer. messages may be dropped")
 )
 
-// ErrHandler is a function that is called when there is an out of band issue with delivery
+// timestampsToReturn are the timestamps requested for new monitored items.
+const timestampsToReturn = ua.TimestampsToReturnBoth
+
+// ErrHandler is a function that is called when there is an out of band issue with delivery.
 type ErrHandler func(*opcua.Client, *Subscription, error)
 
+// StatusHandler is a function that is called for the status events of a subscription,
+// i.e. *opcua.StatusChangeEvent, *opcua.KeepAliveTimeoutEvent and *opcua.RecreatedEvent.
+type StatusHandler func(*opcua.Client, *Subscription, interface{})
+
 // MsgHandler is a function that is called for each new DataValue
 type MsgHandler func(*Subscription, *DataChangeMessage)
 
@@ -32,18 +40,24 @@ type DataChangeMessage struct {
 	NodeID *ua.NodeID
 }
 
+// EventHandler is a function that is called for each new event
+type EventHandler func(*Subscription, *EventMessage)
+
 // NodeMonitor creates new subscriptions
 type NodeMonitor struct {
 	client           *opcua.Client
 	nextClientHandle uint32
 	errHandlerCB     ErrHandler
+	statusHandlerCB  StatusHandler
+	backpressure     int32 // Backpressure for new subscriptions, accessed atomically
 }
 
 // Item is a struct to manage Monitored Items
 type Item struct {
-	id     uint32     // from server
-	nodeID *ua.NodeID // from request
-	handle uint32     // client provided
+	id     uint32                // from server
+	nodeID *ua.NodeID            // from request
+	handle uint32                // client provided
+	ts     ua.TimestampsToReturn // from request
 }
 
 // ID returns the MonitorItemID set by the server
@@ -62,6 +76,7 @@ type Request struct {
 	MonitoringMode       ua.MonitoringMode
 	MonitoringParameters *ua.MonitoringParameters
 	handle               uint32
+	typ                  reflect.Type
 }
 
 // Subscription is an instance of an active subscription.
@@ -69,13 +84,30 @@ type Request struct {
 type Subscription struct {
 	delivered        uint64
 	dropped          uint64
+	blocked          uint64
+	coalesced        uint64
 	monitor          *NodeMonitor
 	sub              *opcua.Subscription
 	internalNotifyCh chan *opcua.PublishNotificationData
 	closed           chan struct{}
 	mu               sync.RWMutex
 	handles          map[uint32]*ua.NodeID
+	eventFields      map[uint32][]string
 	itemLookup       map[uint32]Item
+	types            map[uint32]reflect.Type
+	eventCh          chan<- *EventMessage
+	eventCB          EventHandler
+	typedCh          chan<- *TypedMessage
+	typedCB          TypedHandler
+
+	// qmu guards the delivery queue
+	qmu          sync.Mutex
+	queue        []*delivery
+	queueLen     int
+	latest       map[uint32]*delivery
+	backpressure Backpressure
+	qready       chan struct{}
+	qspace       chan struct{}
 }
 
 // NewNodeMonitor creates a new NodeMonitor
@@ -88,7 +120,7 @@ func NewNodeMonitor(client *opcua.Client) (*NodeMonitor, error) {
 	return m, nil
 }
 
-func newSubscription(m *NodeMonitor, params *opcua.SubscriptionParameters, notifyChanLength int, nodes ...string) (*Subscription, error) {
+func newSubscription(m *NodeMonitor, params *opcua.SubscriptionParameters, queueLen int, nodes ...string) (*Subscription, error) {
 	if params == nil {
 		params = &opcua.SubscriptionParameters{}
 	}
@@ -96,9 +128,16 @@ func newSubscription(m *NodeMonitor, params *opcua.SubscriptionParameters, notif
 	s := &Subscription{
 		monitor:          m,
 		closed:           make(chan struct{}),
-		internalNotifyCh: make(chan *opcua.PublishNotificationData, notifyChanLength),
+		internalNotifyCh: make(chan *opcua.PublishNotificationData, 16),
 		handles:          make(map[uint32]*ua.NodeID),
+		eventFields:      make(map[uint32][]string),
 		itemLookup:       make(map[uint32]Item),
+		types:            make(map[uint32]reflect.Type),
+		queueLen:         queueLen,
+		latest:           make(map[uint32]*delivery),
+		backpressure:     Backpressure(atomic.LoadInt32(&m.backpressure)),
+		qready:           make(chan struct{}, 1),
+		qspace:           make(chan struct{}, 1),
 	}
 
 	var err error
@@ -118,6 +157,12 @@ func (m *NodeMonitor) SetErrorHandler(cb ErrHandler) {
 	m.errHandlerCB = cb
 }
 
+// SetStatusHandler sets an optional callback for the status events of the subscriptions.
+// Status events are dropped if no callback is set.
+func (m *NodeMonitor) SetStatusHandler(cb StatusHandler) {
+	m.statusHandlerCB = cb
+}
+
 // Subscribe creates a new callback-based subscription and an optional list of nodes.
 // The caller must call `Unsubscribe` to stop and clean up resources. Canceling the context
 // will also cause the subscription to stop, but `Unsubscribe` must still be called.
@@ -148,14 +193,52 @@ func (m *NodeMonitor) ChanSubscribe(ctx context.Context, params *opcua.Subscript
 	return sub, nil
 }
 
+// SubscribeEvents creates a new callback-based subscription for events.
+// Event items are added with AddEventItems. The caller must call
+// `Unsubscribe` to stop and clean up resources.
+func (m *NodeMonitor) SubscribeEvents(ctx context.Context, params *opcua.SubscriptionParameters, cb EventHandler) (*Subscription, error) {
+	sub, err := newSubscription(m, params, DefaultCallbackBufferLen)
+	if err != nil {
+		return nil, err
+	}
+	sub.eventCB = cb
+
+	go sub.pump(ctx, nil, nil)
+
+	return sub, nil
+}
+
+// ChanSubscribeEvents creates a new channel-based subscription for events.
+// Event items are added with AddEventItems. The caller must call
+// `Unsubscribe` to stop and clean up resources.
+func (m *NodeMonitor) ChanSubscribeEvents(ctx context.Context, params *opcua.SubscriptionParameters, ch chan<- *EventMessage) (*Subscription, error) {
+	sub, err := newSubscription(m, params, 16)
+	if err != nil {
+		return nil, err
+	}
+	sub.eventCh = ch
+
+	go sub.pump(ctx, nil, nil)
+
+	return sub, nil
+}
+
 func (s *Subscription) sendError(err error) {
 	if err != nil && s.monitor.errHandlerCB != nil {
 		go s.monitor.errHandlerCB(s.monitor.client, s, err)
 	}
 }
 
-// internal func to read from internal channel and write to client provided channel
+func (s *Subscription) sendStatus(ev interface{}) {
+	if s.monitor.statusHandlerCB != nil {
+		go s.monitor.statusHandlerCB(s.monitor.client, s, ev)
+	}
+}
+
+// internal func to read from internal channel and queue the messages for the consumer
 func (s *Subscription) pump(ctx context.Context, notifyCh chan<- *DataChangeMessage, cb MsgHandler) {
+	go s.deliver(ctx, notifyCh, cb)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -169,53 +252,31 @@ func (s *Subscription) pump(ctx context.Context, notifyCh chan<- *DataChangeMess
 				continue
 			}
 
-			if msg.SubscriptionID != s.sub.SubscriptionID {
-				s.sendError(errors.Errorf("message sub id %v does not match sub id %v", msg.SubscriptionID, s.sub.SubscriptionID))
+			// the subscription id changes when the subscription is recreated
+			switch msg.Value.(type) {
+			case *opcua.StatusChangeEvent, *opcua.KeepAliveTimeoutEvent, *opcua.RecreatedEvent:
+				s.sendStatus(msg.Value)
 				continue
 			}
 
-			// this is sort of a hack to emulate an `ErrSlowConsumer` error from the underlying subscription
-			// we check to see if the channel is "full" from the outside, and bail if it is.
-			if cb != nil && cap(s.internalNotifyCh) > 0 {
-				if len(s.internalNotifyCh) == cap(s.internalNotifyCh) {
-					s.sendError(ErrSlowConsumer)
-					atomic.AddUint64(&s.dropped, 1)
-					continue
-				}
+			if msg.SubscriptionID != s.sub.SubscriptionID {
+				s.sendError(errors.Errorf("message sub id %v does not match sub id %v", msg.SubscriptionID, s.sub.SubscriptionID))
+				continue
 			}
 
 			switch v := msg.Value.(type) {
 			case *ua.DataChangeNotification:
 				for _, item := range v.MonitoredItems {
-					s.mu.RLock()
-					nid, ok := s.handles[item.ClientHandle]
-					s.mu.RUnlock()
-
-					out := &DataChangeMessage{}
-
-					if !ok {
-						out.Error = errors.Errorf("handle %d not found", item.ClientHandle)
-						// TODO: should the error also propagate via the monitor callback?
+					if s.typedCh != nil || s.typedCB != nil {
+						s.enqueue(ctx, item.ClientHandle, s.typedMessage(item))
 					} else {
-						out.NodeID = nid
-						out.DataValue = item.Value
-					}
-
-					if notifyCh != nil {
-						select {
-						case notifyCh <- out:
-							atomic.AddUint64(&s.delivered, 1)
-						default:
-							atomic.AddUint64(&s.dropped, 1)
-							s.sendError(ErrSlowConsumer)
-						}
-					} else if cb != nil {
-						cb(s, out)
-						atomic.AddUint64(&s.delivered, 1)
-					} else {
-						panic("notifyCh or cb must be set")
+						s.enqueue(ctx, item.ClientHandle, s.dataChangeMessage(item))
 					}
 				}
+			case *ua.EventNotificationList:
+				for _, ev := range v.Events {
+					s.enqueue(ctx, 0, s.eventMessage(ev))
+				}
 			default:
 				s.sendError(errors.Errorf("unknown message type: %T", msg.Value))
 			}
@@ -223,11 +284,122 @@ func (s *Subscription) pump(ctx context.Context, notifyCh chan<- *DataChangeMess
 	}
 }
 
+// dispatch sends the message to the consumer. It returns false
+// if the subscription has been closed.
+func (s *Subscription) dispatch(ctx context.Context, msg interface{}, notifyCh chan<- *DataChangeMessage, cb MsgHandler) bool {
+	switch out := msg.(type) {
+	case *DataChangeMessage:
+		switch {
+		case notifyCh != nil:
+			select {
+			case notifyCh <- out:
+			case <-ctx.Done():
+				return false
+			case <-s.closed:
+				return false
+			}
+		case cb != nil:
+			cb(s, out)
+		default:
+			s.sendError(errors.Errorf("data change from %s without data change handler", out.NodeID))
+			return true
+		}
+
+	case *TypedMessage:
+		if s.typedCh != nil {
+			select {
+			case s.typedCh <- out:
+			case <-ctx.Done():
+				return false
+			case <-s.closed:
+				return false
+			}
+		} else {
+			s.typedCB(s, out)
+		}
+
+	case *EventMessage:
+		switch {
+		case s.eventCh != nil:
+			select {
+			case s.eventCh <- out:
+			case <-ctx.Done():
+				return false
+			case <-s.closed:
+				return false
+			}
+		case s.eventCB != nil:
+			s.eventCB(s, out)
+		default:
+			s.sendError(errors.Errorf("event from %s without event handler", out.NodeID))
+			return true
+		}
+	}
+	atomic.AddUint64(&s.delivered, 1)
+	return true
+}
+
+func (s *Subscription) dataChangeMessage(item *ua.MonitoredItemNotification) *DataChangeMessage {
+	s.mu.RLock()
+	nid, ok := s.handles[item.ClientHandle]
+	s.mu.RUnlock()
+
+	out := &DataChangeMessage{}
+
+	if !ok {
+		out.Error = &NodeError{ClientHandle: item.ClientHandle, Err: errors.Errorf("handle not found")}
+		s.sendError(out.Error)
+	} else {
+		out.NodeID = nid
+		out.DataValue = item.Value
+	}
+	return out
+}
+
+func (s *Subscription) eventMessage(ev *ua.EventFieldList) *EventMessage {
+	s.mu.RLock()
+	nid, ok := s.handles[ev.ClientHandle]
+	names := s.eventFields[ev.ClientHandle]
+	s.mu.RUnlock()
+
+	out := &EventMessage{}
+
+	switch {
+	case !ok:
+		out.Error = errors.Errorf("handle %d not found", ev.ClientHandle)
+	case len(names) != len(ev.EventFields):
+		out.NodeID = nid
+		out.Error = errors.Errorf("got %d event fields want %d", len(ev.EventFields), len(names))
+	default:
+		out.NodeID = nid
+		out.Fields = make(map[string]*ua.Variant, len(names))
+		for i, name := range names {
+			out.Fields[name] = ev.EventFields[i]
+		}
+	}
+	return out
+}
+
 // Unsubscribe removes the subscription interests and cleans up any resources
 func (s *Subscription) Unsubscribe() error {
 	// TODO: make idempotent
 	close(s.closed)
-	return s.sub.Cancel()
+
+	// keep reading notifications until the subscription has been
+	// removed from the client so that its publish loop does not block.
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-s.internalNotifyCh:
+			case <-done:
+				return
+			}
+		}
+	}()
+	err := s.sub.Cancel()
+	close(done)
+	return err
 }
 
 // Subscribed returns the number of currently subscribed to nodes
@@ -243,12 +415,12 @@ func (s *Subscription) SubscriptionID() uint32 {
 	return s.sub.SubscriptionID
 }
 
-// Delivered returns the number of DataChangeMessages delivered
+// Delivered returns the number of messages delivered
 func (s *Subscription) Delivered() uint64 {
 	return atomic.LoadUint64(&s.delivered)
 }
 
-// Dropped returns the number of DataChangeMessages dropped due to a slow consumer
+// Dropped returns the number of messages dropped due to a slow consumer
 func (s *Subscription) Dropped() uint64 {
 	return atomic.LoadUint64(&s.dropped)
 }
@@ -294,6 +466,9 @@ func (s *Subscription) AddMonitorItems(nodes ...Request) ([]Item, error) {
 	for i, node := range nodes {
 		handle := atomic.AddUint32(&s.monitor.nextClientHandle, 1)
 		s.handles[handle] = nodes[i].NodeID
+		if node.typ != nil {
+			s.types[handle] = node.typ
+		}
 		nodes[i].handle = handle
 
 		request := opcua.NewMonitoredItemCreateRequestWithDefaults(node.NodeID, ua.AttributeIDValue, handle)
@@ -305,7 +480,7 @@ func (s *Subscription) AddMonitorItems(nodes ...Request) ([]Item, error) {
 		}
 		toAdd = append(toAdd, request)
 	}
-	resp, err := s.sub.Monitor(ua.TimestampsToReturnBoth, toAdd...)
+	resp, err := s.sub.Monitor(timestampsToReturn, toAdd...)
 	if err != nil {
 		return nil, err
 	}
@@ -326,6 +501,7 @@ func (s *Subscription) AddMonitorItems(nodes ...Request) ([]Item, error) {
 			id:     res.MonitoredItemID,
 			handle: nodes[i].handle,
 			nodeID: toAdd[i].ItemToMonitor.NodeID,
+			ts:     timestampsToReturn,
 		}
 		s.itemLookup[res.MonitoredItemID] = mn
 		monitoredItems = append(monitoredItems, mn)
@@ -334,6 +510,78 @@ func (s *Subscription) AddMonitorItems(nodes ...Request) ([]Item, error) {
 	return monitoredItems, nil
 }
 
+// AddEventItems adds event items for the notifier nodes to the subscription.
+// The events are delivered with their fields named by the browse paths of
+// the select clauses, see ua.EventFieldName.
+func (s *Subscription) AddEventItems(reqs ...EventRequest) ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	toAdd := make([]*ua.MonitoredItemCreateRequest, 0, len(reqs))
+	fields := make([][]string, 0, len(reqs))
+	for _, r := range reqs {
+		if r.Filter == nil {
+			return nil, errors.Errorf("event filter for %s must not be nil", r.NodeID)
+		}
+		names := make([]string, len(r.Filter.SelectClauses))
+		for i, op := range r.Filter.SelectClauses {
+			names[i] = ua.EventFieldName(op)
+		}
+		queueSize := r.QueueSize
+		if queueSize == 0 {
+			queueSize = DefaultEventQueueSize
+		}
+
+		handle := atomic.AddUint32(&s.monitor.nextClientHandle, 1)
+		toAdd = append(toAdd, &ua.MonitoredItemCreateRequest{
+			ItemToMonitor: &ua.ReadValueID{
+				NodeID:       r.NodeID,
+				AttributeID:  ua.AttributeIDEventNotifier,
+				DataEncoding: &ua.QualifiedName{},
+			},
+			MonitoringMode: ua.MonitoringModeReporting,
+			RequestedParameters: &ua.MonitoringParameters{
+				ClientHandle:  handle,
+				DiscardOldest: true,
+				Filter:        ua.NewExtensionObject(r.Filter),
+				QueueSize:     queueSize,
+			},
+		})
+		fields = append(fields, names)
+	}
+
+	resp, err := s.sub.Monitor(timestampsToReturn, toAdd...)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ResponseHeader.ServiceResult != ua.StatusOK {
+		return nil, resp.ResponseHeader.ServiceResult
+	}
+	if len(resp.Results) != len(toAdd) {
+		return nil, errors.Errorf("monitor items response length mismatch")
+	}
+
+	var items []Item
+	for i, res := range resp.Results {
+		handle := toAdd[i].RequestedParameters.ClientHandle
+		mn := Item{
+			id:     res.MonitoredItemID,
+			handle: handle,
+			nodeID: toAdd[i].ItemToMonitor.NodeID,
+			ts:     timestampsToReturn,
+		}
+		s.handles[handle] = mn.nodeID
+		s.eventFields[handle] = fields[i]
+		s.itemLookup[res.MonitoredItemID] = mn
+		items = append(items, mn)
+	}
+	return items, nil
+}
+
 // RemoveNodes removes nodes defined by their string representation
 func (s *Subscription) RemoveNodes(nodes ...string) error {
 	nodeIDs, err := parseNodeSlice(nodes...)
@@ -382,6 +630,8 @@ func (s *Subscription) RemoveMonitorItems(items ...Item) error {
 		}
 		delete(s.itemLookup, item.id)
 		delete(s.handles, item.handle)
+		delete(s.eventFields, item.handle)
+		delete(s.types, item.handle)
 		toRemove = append(toRemove, item.id)
 	}
 
@@ -407,6 +657,64 @@ func (s *Subscription) RemoveMonitorItems(items ...Item) error {
 	return nil
 }
 
+// ModifyMonitorItems changes the monitoring parameters of the items, e.g.
+// the sampling interval, the queue size or the filter. The client handle
+// of each item is kept.
+func (s *Subscription) ModifyMonitorItems(params *ua.MonitoringParameters, items ...Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+	if params == nil {
+		return errors.Errorf("monitoring parameters must not be nil")
+	}
+
+	// the items keep the timestamps they have been created with
+	toModify := make(map[ua.TimestampsToReturn][]*ua.MonitoredItemModifyRequest)
+	for _, item := range items {
+		mi, ok := s.itemLookup[item.id]
+		if !ok {
+			return errors.Errorf("item not found: %d", item.id)
+		}
+		p := *params
+		p.ClientHandle = mi.handle
+		toModify[mi.ts] = append(toModify[mi.ts], &ua.MonitoredItemModifyRequest{
+			MonitoredItemID:     mi.id,
+			RequestedParameters: &p,
+		})
+	}
+
+	for ts, reqs := range toModify {
+		if _, err := s.sub.ModifyMonitoredItems(ts, reqs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMonitoringMode switches the items between Disabled, Sampling and Reporting.
+func (s *Subscription) SetMonitoringMode(mode ua.MonitoringMode, items ...Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	ids := make([]uint32, 0, len(items))
+	for _, item := range items {
+		if _, ok := s.itemLookup[item.id]; !ok {
+			return errors.Errorf("item not found: %d", item.id)
+		}
+		ids = append(ids, item.id)
+	}
+
+	_, err := s.sub.SetMonitoringMode(mode, ids...)
+	return err
+}
+
 // Stats returns statistics for the subscription
 func (s *Subscription) Stats() (*ua.SubscriptionDiagnosticsDataType, error) {
 	return s.sub.Stats()
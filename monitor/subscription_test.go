@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/goe/verify"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestModifyItemsNotSent(t *testing.T) {
+	// the requests fail before they are sent since the
+	// subscription has no client.
+	s := newTestSubscription(1, BackpressureDropNewest)
+	s.itemLookup[1] = Item{id: 1, handle: 2, ts: ua.TimestampsToReturnSource}
+	unknown := Item{id: 5}
+	params := &ua.MonitoringParameters{SamplingInterval: 100}
+
+	if err := s.ModifyMonitorItems(params); err != nil {
+		t.Fatalf("no items: got %v want nil", err)
+	}
+	if err := s.ModifyMonitorItems(nil, s.itemLookup[1]); err == nil {
+		t.Fatal("nil parameters: got nil want error")
+	}
+	if err := s.ModifyMonitorItems(params, s.itemLookup[1], unknown); err == nil {
+		t.Fatal("unknown item: got nil want error")
+	}
+
+	if err := s.SetMonitoringMode(ua.MonitoringModeDisabled); err != nil {
+		t.Fatalf("no items: got %v want nil", err)
+	}
+	if err := s.SetMonitoringMode(ua.MonitoringModeDisabled, s.itemLookup[1], unknown); err == nil {
+		t.Fatal("unknown item: got nil want error")
+	}
+}
+
+func TestPumpStatusEvents(t *testing.T) {
+	s := newTestSubscription(1, BackpressureDropNewest)
+	s.internalNotifyCh = make(chan *opcua.PublishNotificationData, 1)
+
+	events := make(chan interface{}, 1)
+	s.monitor.SetStatusHandler(func(_ *opcua.Client, _ *Subscription, ev interface{}) {
+		events <- ev
+	})
+	s.monitor.SetErrorHandler(func(_ *opcua.Client, _ *Subscription, err error) {
+		t.Errorf("got error %v want status event", err)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.pump(ctx, nil, nil)
+
+	// the subscription id of the event does not need to match
+	ev := &opcua.RecreatedEvent{PreviousSubscriptionID: 1, SubscriptionID: 2}
+	s.internalNotifyCh <- &opcua.PublishNotificationData{SubscriptionID: 1, Value: ev}
+	select {
+	case got := <-events:
+		verify.Values(t, "event", got, ev)
+	case <-time.After(time.Second):
+		t.Fatal("no status event")
+	}
+}
@@ -0,0 +1,223 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// TypedHandler is a function that is called for each new typed value
+type TypedHandler func(*Subscription, *TypedMessage)
+
+// TypedMessage is a DataChangeMessage with the value decoded into the Go
+// type which has been registered for the node with AddTypedNodeID.
+//
+// Values are decoded as follows:
+//   - values which are assignable to the type are used as is
+//   - numbers are converted to other number types if the value fits
+//   - slices are converted element by element
+//   - ExtensionObjects are replaced by their registered struct value
+//   - LocalizedText can be decoded into a string
+//
+// Nodes without a registered type deliver the value of the variant.
+type TypedMessage struct {
+	*DataChangeMessage
+
+	// Value is the decoded value or nil if the value
+	// is null or could not be decoded.
+	Value interface{}
+}
+
+// NodeError is the error of a single monitored item. It is sent to the
+// ErrHandler and set as the error of the message.
+type NodeError struct {
+	NodeID       *ua.NodeID
+	ClientHandle uint32
+	Err          error
+}
+
+func (e *NodeError) Error() string {
+	return fmt.Sprintf("opcua: node %s (handle %d): %v", e.NodeID, e.ClientHandle, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// TypedSubscribe creates a new callback-based subscription which decodes
+// the values of the nodes added with AddTypedNodeID. The caller must call
+// `Unsubscribe` to stop and clean up resources.
+func (m *NodeMonitor) TypedSubscribe(ctx context.Context, params *opcua.SubscriptionParameters, cb TypedHandler) (*Subscription, error) {
+	sub, err := newSubscription(m, params, DefaultCallbackBufferLen)
+	if err != nil {
+		return nil, err
+	}
+	sub.typedCB = cb
+
+	go sub.pump(ctx, nil, nil)
+
+	return sub, nil
+}
+
+// ChanTypedSubscribe creates a new channel-based subscription which decodes
+// the values of the nodes added with AddTypedNodeID. The caller must call
+// `Unsubscribe` to stop and clean up resources.
+func (m *NodeMonitor) ChanTypedSubscribe(ctx context.Context, params *opcua.SubscriptionParameters, ch chan<- *TypedMessage) (*Subscription, error) {
+	sub, err := newSubscription(m, params, 16)
+	if err != nil {
+		return nil, err
+	}
+	sub.typedCh = ch
+
+	go sub.pump(ctx, nil, nil)
+
+	return sub, nil
+}
+
+// AddTypedNode adds a node defined by its string representation
+// whose values are decoded into the type of v.
+func (s *Subscription) AddTypedNode(node string, v interface{}) (Item, error) {
+	id, err := ua.ParseNodeID(node)
+	if err != nil {
+		return Item{}, err
+	}
+	return s.AddTypedNodeID(id, v)
+}
+
+// AddTypedNodeID adds a node whose values are decoded into the type of v,
+// e.g. float64(0), []int32(nil) or (*MyUDT)(nil) for a registered
+// ExtensionObject.
+func (s *Subscription) AddTypedNodeID(node *ua.NodeID, v interface{}) (Item, error) {
+	if v == nil {
+		return Item{}, errors.Errorf("type for node %s must not be nil", node)
+	}
+	items, err := s.AddMonitorItems(Request{
+		NodeID:         node,
+		MonitoringMode: ua.MonitoringModeReporting,
+		typ:            reflect.TypeOf(v),
+	})
+	if err != nil {
+		return Item{}, err
+	}
+	return items[0], nil
+}
+
+func (s *Subscription) typedMessage(item *ua.MonitoredItemNotification) *TypedMessage {
+	s.mu.RLock()
+	nid, ok := s.handles[item.ClientHandle]
+	typ := s.types[item.ClientHandle]
+	s.mu.RUnlock()
+
+	out := &TypedMessage{DataChangeMessage: &DataChangeMessage{NodeID: nid, DataValue: item.Value}}
+
+	switch {
+	case !ok:
+		out.Error = &NodeError{ClientHandle: item.ClientHandle, Err: errors.Errorf("handle not found")}
+	case item.Value == nil || item.Value.Value == nil || item.Value.Value.Value() == nil:
+		// null value
+	case typ == nil:
+		out.Value = item.Value.Value.Value()
+	default:
+		v, err := convert(item.Value.Value.Value(), typ)
+		if err != nil {
+			out.Error = &NodeError{NodeID: nid, ClientHandle: item.ClientHandle, Err: err}
+			break
+		}
+		out.Value = v.Interface()
+	}
+	if out.Error != nil {
+		s.sendError(out.Error)
+	}
+	return out
+}
+
+// convert converts the value of a variant to the Go type.
+func convert(val interface{}, t reflect.Type) (reflect.Value, error) {
+	if eo, ok := val.(*ua.ExtensionObject); ok {
+		if eo == nil || eo.Value == nil {
+			return reflect.Value{}, errors.Errorf("cannot convert empty extension object to %s", t)
+		}
+		val = eo.Value
+	}
+	if lt, ok := val.(*ua.LocalizedText); ok && lt != nil && t.Kind() == reflect.String {
+		return reflect.ValueOf(lt.Text).Convert(t), nil
+	}
+
+	src := reflect.ValueOf(val)
+	switch {
+	case !src.IsValid():
+		return reflect.Value{}, errors.Errorf("cannot convert null value to %s", t)
+
+	case src.Type().AssignableTo(t):
+		return src, nil
+
+	case src.Kind() == reflect.Ptr && !src.IsNil() && src.Elem().Type().AssignableTo(t):
+		return src.Elem(), nil
+
+	case isNumber(src.Kind()) && isNumber(t.Kind()):
+		dst := src.Convert(t)
+		if !sameNumber(src, dst) {
+			return reflect.Value{}, errors.Errorf("%v (%s) does not fit into %s", val, src.Type(), t)
+		}
+		return dst, nil
+
+	case src.Kind() == reflect.Slice && t.Kind() == reflect.Slice:
+		out := reflect.MakeSlice(t, src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			v, err := convert(src.Index(i).Interface(), t.Elem())
+			if err != nil {
+				return reflect.Value{}, errors.Errorf("index %d: %s", i, err)
+			}
+			out.Index(i).Set(v)
+		}
+		return out, nil
+
+	default:
+		return reflect.Value{}, errors.Errorf("cannot convert %T to %s", val, t)
+	}
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// sameNumber returns true if the converted number dst has the value of src.
+// Floats may lose precision but must not overflow.
+func sameNumber(src, dst reflect.Value) bool {
+	// a conversion between signed and unsigned integers
+	// can change the sign and still convert back.
+	if negative(src) != negative(dst) {
+		return false
+	}
+	switch src.Kind() {
+	case reflect.Float32, reflect.Float64:
+		switch dst.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f := src.Float()
+			return math.IsNaN(f) || math.IsInf(f, 0) || !math.IsInf(dst.Float(), 0)
+		}
+	}
+	return dst.Convert(src.Type()).Interface() == src.Interface()
+}
+
+func negative(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() < 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() < 0
+	}
+	return false
+}
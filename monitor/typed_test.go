@@ -0,0 +1,163 @@
+package monitor
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+type testUDT struct {
+	A int32
+	B string
+}
+
+type celsius float64
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name string
+		val  interface{}
+		typ  interface{}
+		want interface{}
+		err  bool
+	}{
+		{name: "assignable", val: int32(5), typ: int32(0), want: int32(5)},
+		{name: "widen", val: int16(-5), typ: int64(0), want: int64(-5)},
+		{name: "narrow", val: uint32(255), typ: uint8(0), want: uint8(255)},
+		{name: "int overflow", val: uint32(256), typ: uint8(0), err: true},
+		{name: "negative to unsigned", val: int32(-1), typ: uint32(0), err: true},
+		{name: "unsigned to negative", val: uint64(math.MaxUint64), typ: int64(0), err: true},
+		{name: "int to float", val: int32(3), typ: float64(0), want: float64(3)},
+		{name: "fraction to int", val: 1.5, typ: int32(0), err: true},
+		{name: "float precision", val: 0.1, typ: float32(0), want: float32(0.1)},
+		{name: "float overflow", val: math.MaxFloat64, typ: float32(0), err: true},
+		{name: "float infinity", val: math.Inf(1), typ: float32(0), want: float32(math.Inf(1))},
+		{name: "named type", val: 21.5, typ: celsius(0), want: celsius(21.5)},
+		{name: "slice", val: []int16{1, 2}, typ: []int32(nil), want: []int32{1, 2}},
+		{name: "slice overflow", val: []int32{1, 1 << 20}, typ: []int16(nil), err: true},
+		{name: "slice to scalar", val: []int32{1}, typ: int32(0), err: true},
+		{name: "localized text", val: &ua.LocalizedText{Locale: "en", Text: "hot"}, typ: "", want: "hot"},
+		{name: "localized text struct", val: &ua.LocalizedText{Text: "hot"}, typ: ua.LocalizedText{}, want: ua.LocalizedText{Text: "hot"}},
+		{name: "udt pointer", val: &ua.ExtensionObject{Value: &testUDT{A: 1, B: "x"}}, typ: (*testUDT)(nil), want: &testUDT{A: 1, B: "x"}},
+		{name: "udt value", val: &ua.ExtensionObject{Value: &testUDT{A: 1, B: "x"}}, typ: testUDT{}, want: testUDT{A: 1, B: "x"}},
+		{name: "empty extension object", val: &ua.ExtensionObject{}, typ: testUDT{}, err: true},
+		{name: "wrong udt", val: &ua.ExtensionObject{Value: &ua.Range{}}, typ: testUDT{}, err: true},
+		{name: "string to number", val: "1", typ: int32(0), err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := convert(tt.val, reflect.TypeOf(tt.typ))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %v want error", v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "", v.Interface(), tt.want)
+		})
+	}
+}
+
+func TestSameNumber(t *testing.T) {
+	tests := []struct {
+		name     string
+		src, dst interface{}
+		want     bool
+	}{
+		{"same int", int64(5), int8(5), true},
+		{"truncated int", int64(300), int8(44), false},
+		{"sign", int8(-1), uint8(255), false},
+		{"unsigned to negative", uint32(math.MaxUint32), int32(-1), false},
+		{"float to int", 2.0, int32(2), true},
+		{"fraction", 2.5, int32(2), false},
+		{"float precision", 0.1, float32(0.1), true},
+		{"float overflow", math.MaxFloat64, float32(math.Inf(1)), false},
+		{"nan", math.NaN(), float32(math.NaN()), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sameNumber(reflect.ValueOf(tt.src), reflect.ValueOf(tt.dst))
+			verify.Values(t, "", got, tt.want)
+		})
+	}
+}
+
+func TestUnmarshalConversions(t *testing.T) {
+	// Unmarshal uses the conversions of TypedMessage. Numbers which
+	// do not fit are rejected instead of being truncated.
+	type event struct {
+		Severity uint8
+		Codes    []int32
+		Data     testUDT
+	}
+	msg := &EventMessage{Fields: map[string]*ua.Variant{
+		"Severity": ua.MustVariant(uint16(200)),
+		"Codes":    ua.MustVariant([]int16{1, 2}),
+		"Data":     ua.MustVariant(&ua.ExtensionObject{Value: &testUDT{A: 1}}),
+	}}
+	var got event
+	if err := msg.Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", got, event{Severity: 200, Codes: []int32{1, 2}, Data: testUDT{A: 1}})
+
+	msg.Fields["Severity"] = ua.MustVariant(uint16(1000))
+	if err := msg.Unmarshal(&got); err == nil {
+		t.Fatal("got nil want error for overflow")
+	}
+}
+
+func TestTypedMessage(t *testing.T) {
+	nid := ua.NewNumericNodeID(0, 1)
+	s := newTestSubscription(1, BackpressureDropNewest)
+	s.handles[1] = nid
+	s.types[1] = reflect.TypeOf(int16(0))
+	s.handles[2] = nid
+
+	errs := make(chan error, 4)
+	s.monitor.SetErrorHandler(func(_ *opcua.Client, _ *Subscription, err error) { errs <- err })
+
+	item := func(handle uint32, v interface{}) *ua.MonitoredItemNotification {
+		return &ua.MonitoredItemNotification{ClientHandle: handle, Value: &ua.DataValue{Value: ua.MustVariant(v)}}
+	}
+
+	tests := []struct {
+		name  string
+		item  *ua.MonitoredItemNotification
+		value interface{}
+		err   bool
+	}{
+		{name: "typed", item: item(1, int32(7)), value: int16(7)},
+		{name: "untyped", item: item(2, int32(7)), value: int32(7)},
+		{name: "null", item: item(1, nil)},
+		{name: "conversion error", item: item(1, int32(1<<20)), err: true},
+		{name: "unknown handle", item: item(3, int32(7)), err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := s.typedMessage(tt.item)
+			verify.Values(t, "value", msg.Value, tt.value)
+			if !tt.err {
+				if msg.Error != nil {
+					t.Fatal(msg.Error)
+				}
+				return
+			}
+			nerr, ok := msg.Error.(*NodeError)
+			if !ok {
+				t.Fatalf("got %T want *NodeError", msg.Error)
+			}
+			verify.Values(t, "handle", nerr.ClientHandle, tt.item.ClientHandle)
+			if got := <-errs; got != msg.Error {
+				t.Fatalf("got error %v want %v", got, msg.Error)
+			}
+		})
+	}
+}
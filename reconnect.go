@@ -0,0 +1,164 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacp"
+	"github.com/gopcua/opcua/uasc"
+)
+
+const (
+	DefaultReconnectInitialInterval = 500 * time.Millisecond
+	DefaultReconnectMaxInterval     = time.Minute
+	DefaultReconnectMultiplier      = 2
+	DefaultReconnectJitter          = 0.2
+)
+
+// ExponentialBackoff is a reconnect policy which increases the delay
+// between the reconnect attempts exponentially up to MaxInterval.
+// A random jitter is applied to every delay so that many clients
+// which lose their connection at the same time do not reconnect
+// in lockstep.
+//
+// The zero value retries forever with the default intervals.
+type ExponentialBackoff struct {
+	// InitialInterval is the delay after the first failed attempt.
+	// Defaults to DefaultReconnectInitialInterval.
+	InitialInterval time.Duration
+
+	// MaxInterval is the upper bound of the delay.
+	// Defaults to DefaultReconnectMaxInterval.
+	MaxInterval time.Duration
+
+	// Multiplier is the factor by which the delay grows after
+	// every failed attempt. Defaults to DefaultReconnectMultiplier.
+	Multiplier float64
+
+	// Jitter is the randomization factor between 0 and 1. A delay d
+	// is randomized to a value between d*(1-Jitter) and d*(1+Jitter).
+	Jitter float64
+
+	// MaxAttempts is the number of failed attempts after which the
+	// client gives up. 0 means no limit.
+	MaxAttempts int
+
+	// MaxElapsedTime is the duration after which the client gives up.
+	// 0 means no limit.
+	MaxElapsedTime time.Duration
+
+	// Retryable classifies the errors. If it returns false the client
+	// gives up immediately. Defaults to RetryableError.
+	Retryable func(error) bool
+}
+
+// Retry implements the uasc.ReconnectPolicy interface.
+func (b *ExponentialBackoff) Retry(attempt int, elapsed time.Duration, err error) (time.Duration, bool) {
+	retryable := b.Retryable
+	if retryable == nil {
+		retryable = RetryableError
+	}
+	if err != nil && !retryable(err) {
+		return 0, false
+	}
+	if b.MaxAttempts > 0 && attempt >= b.MaxAttempts {
+		return 0, false
+	}
+	if b.MaxElapsedTime > 0 && elapsed >= b.MaxElapsedTime {
+		return 0, false
+	}
+
+	// try to reconnect immediately after the connection was lost
+	if attempt == 0 {
+		return 0, true
+	}
+
+	initial, max, mult := b.InitialInterval, b.MaxInterval, b.Multiplier
+	if initial <= 0 {
+		initial = DefaultReconnectInitialInterval
+	}
+	if max <= 0 {
+		max = DefaultReconnectMaxInterval
+	}
+	if mult < 1 {
+		mult = DefaultReconnectMultiplier
+	}
+
+	d := float64(initial) * math.Pow(mult, float64(attempt-1))
+	if d > float64(max) {
+		d = float64(max)
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d), true
+}
+
+// defaultReconnectPolicy returns the reconnect policy for the configuration.
+// Without an explicit policy the client retries every ReconnectInterval
+// until it succeeds.
+func defaultReconnectPolicy(cfg *uasc.Config) uasc.ReconnectPolicy {
+	if cfg.ReconnectPolicy != nil {
+		return cfg.ReconnectPolicy
+	}
+	return &ExponentialBackoff{
+		InitialInterval: cfg.ReconnectInterval,
+		MaxInterval:     cfg.ReconnectInterval,
+		Multiplier:      1,
+	}
+}
+
+// RetryableError returns false for errors which will not go away by
+// reconnecting, e.g. a rejected certificate or invalid user credentials.
+// All other errors, including refused connections, are retryable.
+func RetryableError(err error) bool {
+	var code ua.StatusCode
+	switch x := err.(type) {
+	case ua.StatusCode:
+		code = x
+	case *uacp.Error:
+		code = ua.StatusCode(x.ErrorCode)
+	default:
+		return true
+	}
+
+	switch code {
+	case ua.StatusBadCertificateUntrusted,
+		ua.StatusBadCertificateRevoked,
+		ua.StatusBadCertificateUseNotAllowed,
+		ua.StatusBadCertificateURIInvalid,
+		ua.StatusBadCertificateHostNameInvalid,
+		ua.StatusBadSecurityPolicyRejected,
+		ua.StatusBadIdentityTokenInvalid,
+		ua.StatusBadIdentityTokenRejected,
+		ua.StatusBadUserAccessDenied,
+		ua.StatusBadTCPEndpointURLInvalid:
+		return false
+	default:
+		return true
+	}
+}
+
+// ReconnectError is the error of a client which has given up
+// reconnecting to the server.
+type ReconnectError struct {
+	// Attempts is the number of failed reconnect attempts.
+	Attempts int
+
+	// Elapsed is the time since the connection was lost.
+	Elapsed time.Duration
+
+	// Err is the error of the last attempt.
+	Err error
+}
+
+func (e *ReconnectError) Error() string {
+	return fmt.Sprintf("opcua: reconnect failed after %d attempts in %s: %v", e.Attempts, e.Elapsed, e.Err)
+}
@@ -0,0 +1,95 @@
+package opcua
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacp"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	type result struct {
+		d  time.Duration
+		ok bool
+	}
+
+	tests := []struct {
+		name    string
+		b       *ExponentialBackoff
+		attempt int
+		elapsed time.Duration
+		err     error
+		want    result
+	}{
+		{
+			name: "initial error",
+			b:    &ExponentialBackoff{},
+			err:  syscall.ECONNREFUSED,
+			want: result{0, true},
+		},
+		{
+			name:    "first attempt",
+			b:       &ExponentialBackoff{InitialInterval: time.Second},
+			attempt: 1,
+			err:     syscall.ECONNREFUSED,
+			want:    result{time.Second, true},
+		},
+		{
+			name:    "grows",
+			b:       &ExponentialBackoff{InitialInterval: time.Second, Multiplier: 3},
+			attempt: 3,
+			want:    result{9 * time.Second, true},
+		},
+		{
+			name:    "max interval",
+			b:       &ExponentialBackoff{InitialInterval: time.Second, MaxInterval: 5 * time.Second},
+			attempt: 10,
+			want:    result{5 * time.Second, true},
+		},
+		{
+			name:    "max attempts",
+			b:       &ExponentialBackoff{MaxAttempts: 3},
+			attempt: 3,
+			want:    result{0, false},
+		},
+		{
+			name:    "max elapsed time",
+			b:       &ExponentialBackoff{MaxElapsedTime: time.Minute},
+			attempt: 1,
+			elapsed: time.Minute,
+			want:    result{0, false},
+		},
+		{
+			name: "not retryable",
+			b:    &ExponentialBackoff{},
+			err:  &uacp.Error{ErrorCode: uint32(ua.StatusBadCertificateUntrusted)},
+			want: result{0, false},
+		},
+		{
+			name: "custom classification",
+			b:    &ExponentialBackoff{Retryable: func(error) bool { return false }},
+			err:  syscall.ECONNREFUSED,
+			want: result{0, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := tt.b.Retry(tt.attempt, tt.elapsed, tt.err)
+			verify.Values(t, "", result{d, ok}, tt.want)
+		})
+	}
+}
+
+func TestExponentialBackoffJitter(t *testing.T) {
+	b := &ExponentialBackoff{InitialInterval: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d, _ := b.Retry(1, 0, nil)
+		if d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("got %s want between 500ms and 1.5s", d)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+const (
+	// DefaultServiceLevelInterval is the default interval for
+	// polling the ServiceLevel of the redundant servers.
+	DefaultServiceLevelInterval = 5 * time.Second
+
+	// DefaultMinServiceLevel is the default ServiceLevel below which
+	// the RedundantClient fails over to another server.
+	//
+	// See Part 4, 6.6.2.4.2 ServiceLevel
+	DefaultMinServiceLevel = 200
+)
+
+// ServerRedundancy describes the redundancy state of a single server
+// as reported by the ServerRedundancy object and the ServiceLevel
+// variable of the Server object.
+//
+// See Part 4, 6.6.2 Server Redundancy
+type ServerRedundancy struct {
+	// EndpointURL is the endpoint the information was read from.
+	EndpointURL string
+
+	// Support is the redundancy mode of the server.
+	Support ua.RedundancySupport
+
+	// ServerURIs contains the application URIs of the servers in the
+	// redundant server set. It is only available for servers which
+	// support non-transparent redundancy.
+	ServerURIs []string
+
+	// ServiceLevel is the ability of the server to provide its data.
+	// 0 means that the server is not operational and 255 that it is
+	// fully operational.
+	ServiceLevel uint8
+}
+
+// ReadServerRedundancy reads the redundancy support, the server uri array
+// and the service level of the server in a single Read request.
+func (c *Client) ReadServerRedundancy() (*ServerRedundancy, error) {
+	req := &ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{
+			{NodeID: ua.NewNumericNodeID(0, id.Server_ServerRedundancy_RedundancySupport)},
+			{NodeID: ua.NewNumericNodeID(0, id.Server_ServerRedundancy_ServerURIArray)},
+			{NodeID: ua.NewNumericNodeID(0, id.Server_ServiceLevel)},
+		},
+	}
+	res, err := c.Read(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Results) != len(req.NodesToRead) {
+		return nil, ua.StatusBadUnexpectedError
+	}
+
+	r := &ServerRedundancy{EndpointURL: c.endpointURL}
+	if dv := res.Results[0]; dv.Status == ua.StatusOK && dv.Value != nil {
+		r.Support = ua.RedundancySupport(dv.Value.Int())
+	}
+	// the ServerUriArray is optional and only exists for
+	// non-transparent redundancy.
+	if dv := res.Results[1]; dv.Status == ua.StatusOK && dv.Value != nil {
+		r.ServerURIs, _ = dv.Value.Value().([]string)
+	}
+	dv := res.Results[2]
+	if dv.Status != ua.StatusOK {
+		return nil, dv.Status
+	}
+	if dv.Value != nil {
+		r.ServiceLevel = uint8(dv.Value.Uint())
+	}
+	return r, nil
+}
+
+// RedundantClient connects to the healthiest server of a redundant server
+// set and fails over to another server when the ServiceLevel of the active
+// server drops below MinServiceLevel or the connection is lost.
+//
+// For Cold redundancy only the active server is connected and the next
+// server is connected during the failover. For Warm and Hot redundancy the
+// client keeps a connection to all servers so that it can switch without
+// the delay of establishing a new session. Subscriptions of the active
+// client are moved to the new server with TransferSubscriptions for Hot
+// and Transparent redundancy and are recreated otherwise.
+//
+// The exported fields must be set before calling Connect.
+type RedundantClient struct {
+	// ServiceLevelInterval is the interval in which the ServiceLevel of
+	// the servers is checked. Defaults to DefaultServiceLevelInterval.
+	ServiceLevelInterval time.Duration
+
+	// MinServiceLevel is the ServiceLevel below which the client fails
+	// over to another server. Defaults to DefaultMinServiceLevel.
+	MinServiceLevel uint8
+
+	// FailoverHandler is called after the client has switched to a new
+	// server. err contains the error of the subscription transfer, if any.
+	FailoverHandler func(from, to string, err error)
+
+	endpoints []string
+	opts      []Option
+
+	mu      sync.RWMutex
+	mode    ua.RedundancySupport
+	active  *Client
+	clients map[string]*Client // connected clients by endpoint
+	levels  map[string]uint8   // last known ServiceLevel by endpoint
+	cancel  func()
+
+	// serviceLevel reads the ServiceLevel of a server.
+	serviceLevel func(c *Client) (uint8, error)
+}
+
+// NewRedundantClient creates a client for the redundant server set
+// reachable through the given endpoints. The options are applied to the
+// clients for all endpoints.
+func NewRedundantClient(endpoints []string, opts ...Option) *RedundantClient {
+	return &RedundantClient{
+		ServiceLevelInterval: DefaultServiceLevelInterval,
+		MinServiceLevel:      DefaultMinServiceLevel,
+		endpoints:            endpoints,
+		opts:                 opts,
+		clients:              make(map[string]*Client),
+		levels:               make(map[string]uint8),
+		serviceLevel:         serviceLevel,
+	}
+}
+
+// Connect probes all endpoints, connects to the server with the highest
+// ServiceLevel and starts watching the ServiceLevel of the servers.
+// Servers of a non-transparent redundant server set which are listed in
+// the ServerUriArray but not in the endpoints are found with FindServers
+// and probed as well.
+func (r *RedundantClient) Connect(ctx context.Context) error {
+	if len(r.endpoints) == 0 {
+		return errors.Errorf("no endpoints")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.active != nil {
+		return errors.Errorf("already connected")
+	}
+
+	var firstErr error
+	for i := 0; i < len(r.endpoints); i++ {
+		ep := r.endpoints[i]
+		c := NewClient(ep, r.opts...)
+		if err := c.Connect(ctx); err != nil {
+			debug.Printf("redundancy: %s: connect failed: %v", ep, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		red, err := c.ReadServerRedundancy()
+		if err != nil {
+			debug.Printf("redundancy: %s: read redundancy failed: %v", ep, err)
+			_ = c.Close()
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if red.Support > r.mode {
+			r.mode = red.Support
+		}
+		if len(red.ServerURIs) > 0 {
+			r.discover(c, red.ServerURIs)
+		}
+		r.clients[ep] = c
+		r.levels[ep] = red.ServiceLevel
+	}
+	if len(r.clients) == 0 {
+		return firstErr
+	}
+
+	best := r.bestEndpoint("")
+	r.active = r.clients[best]
+	debug.Printf("redundancy: mode %s: active server %s", r.mode, best)
+
+	// with cold redundancy we only keep the active connection open
+	if !r.keepStandby() {
+		for ep, c := range r.clients {
+			if c != r.active {
+				_ = c.Close()
+				delete(r.clients, ep)
+			}
+		}
+	}
+
+	wctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.watch(wctx)
+	return nil
+}
+
+// Client returns the client for the active server.
+func (r *RedundantClient) Client() *Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+// Mode returns the redundancy mode of the server set.
+func (r *RedundantClient) Mode() ua.RedundancySupport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mode
+}
+
+// Close stops watching the servers and closes all connections.
+func (r *RedundantClient) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil {
+		r.cancel()
+	}
+	for ep, c := range r.clients {
+		_ = c.Close()
+		delete(r.clients, ep)
+	}
+	r.active = nil
+	return nil
+}
+
+// keepStandby returns true if connections to the standby servers
+// should be kept open.
+func (r *RedundantClient) keepStandby() bool {
+	return r.mode >= ua.RedundancySupportWarm
+}
+
+// transferSubscriptions returns true if the servers share their
+// subscriptions and they can be moved with TransferSubscriptions.
+func (r *RedundantClient) transferSubscriptions() bool {
+	return r.mode >= ua.RedundancySupportHot
+}
+
+// bestEndpoint returns the endpoint with the highest known ServiceLevel
+// ignoring the given endpoint. It returns an empty string if there
+// is no candidate.
+func (r *RedundantClient) bestEndpoint(skip string) string {
+	var best string
+	for _, ep := range r.endpoints {
+		if ep == skip {
+			continue
+		}
+		lvl, ok := r.levels[ep]
+		if !ok {
+			continue
+		}
+		if best == "" || lvl > r.levels[best] {
+			best = ep
+		}
+	}
+	return best
+}
+
+// discover adds the endpoints of the servers with the given application
+// uris which are not yet known. The caller must hold the lock.
+func (r *RedundantClient) discover(c *Client, serverURIs []string) {
+	res, err := c.FindServers(serverURIs, nil)
+	if err != nil {
+		debug.Printf("redundancy: %s: find servers failed: %v", c.endpointURL, err)
+		return
+	}
+	known := make(map[string]bool)
+	for _, ep := range r.endpoints {
+		known[ep] = true
+	}
+	for _, srv := range res.Servers {
+		for _, ep := range srv.DiscoveryURLs {
+			if !strings.HasPrefix(ep, "opc.tcp://") {
+				continue
+			}
+			if !known[ep] {
+				debug.Printf("redundancy: discovered %s at %s", srv.ApplicationURI, ep)
+				r.endpoints = append(r.endpoints, ep)
+				known[ep] = true
+			}
+			break
+		}
+	}
+}
+
+// watch checks the ServiceLevel of the servers periodically
+// and triggers the failover.
+func (r *RedundantClient) watch(ctx context.Context) {
+	dlog := debug.NewPrefixLogger("redundancy: ")
+	defer dlog.Print("done")
+
+	t := time.NewTicker(r.ServiceLevelInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			r.check(ctx)
+		}
+	}
+}
+
+// check updates the service levels and fails over if the active
+// server is no longer healthy. The servers are queried without
+// holding the lock so that Client does not block during the check.
+// check must not be called concurrently.
+func (r *RedundantClient) check(ctx context.Context) {
+	dlog := debug.NewPrefixLogger("redundancy: ")
+
+	r.mu.RLock()
+	active := r.active
+	clients := make(map[string]*Client, len(r.clients))
+	for ep, c := range r.clients {
+		clients[ep] = c
+	}
+	r.mu.RUnlock()
+
+	if active == nil {
+		return
+	}
+	from := active.endpointURL
+
+	levels := make(map[string]uint8, len(clients))
+	for ep, c := range clients {
+		lvl, err := r.serviceLevel(c)
+		if err != nil {
+			dlog.Printf("%s: %v", ep, err)
+		}
+		levels[ep] = lvl
+	}
+
+	r.mu.Lock()
+	for ep, lvl := range levels {
+		r.levels[ep] = lvl
+	}
+	r.mu.Unlock()
+
+	if levels[from] >= r.MinServiceLevel {
+		return
+	}
+	dlog.Printf("%s: service level %d below %d", from, levels[from], r.MinServiceLevel)
+
+	// cold redundancy: probe the other servers
+	if !r.keepStandby() {
+		probed := make(map[string]*Client)
+		for _, ep := range r.endpoints {
+			if ep == from {
+				continue
+			}
+			c := NewClient(ep, r.opts...)
+			if err := c.Connect(ctx); err != nil {
+				dlog.Printf("%s: connect failed: %v", ep, err)
+				levels[ep] = 0
+				continue
+			}
+			lvl, err := r.serviceLevel(c)
+			if err != nil {
+				dlog.Printf("%s: %v", ep, err)
+			}
+			probed[ep] = c
+			levels[ep] = lvl
+		}
+
+		r.mu.Lock()
+		if r.active != active {
+			r.mu.Unlock()
+			closeClients(probed)
+			return
+		}
+		for ep, c := range probed {
+			r.clients[ep] = c
+		}
+		for ep, lvl := range levels {
+			if _, ok := probed[ep]; ok || ep == from {
+				r.levels[ep] = lvl
+			} else {
+				delete(r.levels, ep)
+			}
+		}
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	to := r.bestEndpoint(from)
+	if to == "" || r.levels[to] <= r.levels[from] || r.active != active {
+		unused := r.unused()
+		r.mu.Unlock()
+		dlog.Printf("no better server available")
+		closeClients(unused)
+		return
+	}
+	next := r.clients[to]
+	transfer := r.transferSubscriptions()
+	r.mu.Unlock()
+
+	err := next.moveSubscriptions(active, transfer)
+
+	r.mu.Lock()
+	if r.active != active {
+		// closed during the failover
+		r.mu.Unlock()
+		return
+	}
+	r.active = next
+	if !r.keepStandby() {
+		delete(r.clients, from)
+	}
+	unused := r.unused()
+	r.mu.Unlock()
+	dlog.Printf("failed over from %s to %s", from, to)
+
+	if !r.keepStandby() {
+		_ = active.Close()
+	}
+	closeClients(unused)
+
+	if r.FailoverHandler != nil {
+		go r.FailoverHandler(from, to, err)
+	}
+}
+
+// unused removes the standby connections for cold redundancy
+// and returns them so that they can be closed without the lock.
+// The caller must hold the lock.
+func (r *RedundantClient) unused() map[string]*Client {
+	if r.keepStandby() {
+		return nil
+	}
+	unused := make(map[string]*Client)
+	for ep, c := range r.clients {
+		if c != r.active {
+			unused[ep] = c
+			delete(r.clients, ep)
+		}
+	}
+	return unused
+}
+
+func closeClients(clients map[string]*Client) {
+	for _, c := range clients {
+		_ = c.Close()
+	}
+}
+
+// serviceLevel returns the ServiceLevel of the server. A server which
+// is not connected has a ServiceLevel of 0.
+func serviceLevel(c *Client) (uint8, error) {
+	if c.State() != Connected {
+		return 0, nil
+	}
+	v, err := c.Node(ua.NewNumericNodeID(0, id.Server_ServiceLevel)).Value()
+	if err != nil {
+		return 0, err
+	}
+	return uint8(v.Uint()), nil
+}
@@ -0,0 +1,135 @@
+package opcua
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestRedundantClientBestEndpoint(t *testing.T) {
+	r := NewRedundantClient([]string{"a", "b", "c", "d"})
+	r.levels = map[string]uint8{"a": 200, "b": 250, "c": 250}
+
+	verify.Values(t, "", r.bestEndpoint(""), "b")
+	verify.Values(t, "", r.bestEndpoint("b"), "c")
+
+	r.levels = map[string]uint8{"a": 10}
+	verify.Values(t, "", r.bestEndpoint("a"), "")
+}
+
+// warmClient returns a redundant client in warm mode with unconnected
+// clients for the endpoints whose service levels are read from levels.
+func warmClient(levels map[string]uint8, endpoints ...string) *RedundantClient {
+	r := NewRedundantClient(endpoints)
+	r.mode = ua.RedundancySupportWarm
+	for _, ep := range endpoints {
+		r.clients[ep] = NewClient(ep)
+	}
+	r.active = r.clients[endpoints[0]]
+	r.serviceLevel = func(c *Client) (uint8, error) {
+		// the lock must not be held while the servers are queried
+		r.Client()
+		return levels[c.endpointURL], nil
+	}
+	return r
+}
+
+func TestRedundantClientCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		levels map[string]uint8
+		active string
+	}{
+		{"healthy", map[string]uint8{"a": 250, "b": 255}, "a"},
+		{"no better server", map[string]uint8{"a": 100, "b": 50}, "a"},
+		{"failover", map[string]uint8{"a": 100, "b": 250}, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := warmClient(tt.levels, "a", "b")
+
+			failover := make(chan []string, 1)
+			r.FailoverHandler = func(from, to string, err error) {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+				failover <- []string{from, to}
+			}
+
+			r.check(context.Background())
+
+			if got := r.Client().endpointURL; got != tt.active {
+				t.Fatalf("got active %s want %s", got, tt.active)
+			}
+			verify.Values(t, "levels", r.levels, tt.levels)
+			if got, want := len(r.clients), 2; got != want {
+				t.Fatalf("got %d clients want %d", got, want)
+			}
+
+			if tt.active == "a" {
+				return
+			}
+			select {
+			case got := <-failover:
+				verify.Values(t, "failover", got, []string{"a", tt.active})
+			case <-time.After(time.Second):
+				t.Fatal("failover handler not called")
+			}
+		})
+	}
+}
+
+func TestRedundantClientCheckMovesSubscriptions(t *testing.T) {
+	r := warmClient(map[string]uint8{"a": 0, "b": 255}, "a", "b")
+	a, b := r.clients["a"], r.clients["b"]
+
+	// the old subscription must be deleted on the previous server
+	// before it is created on the new one.
+	var calls []string
+	setFakeChannel(a, func(req ua.Request, h func(interface{}) error) error {
+		calls = append(calls, "a")
+		verify.Values(t, "delete", req, &ua.DeleteSubscriptionsRequest{SubscriptionIDs: []uint32{1}})
+		return h(&ua.DeleteSubscriptionsResponse{Results: []ua.StatusCode{ua.StatusOK}})
+	})
+	setFakeChannel(b, func(req ua.Request, h func(interface{}) error) error {
+		calls = append(calls, "b")
+		if _, ok := req.(*ua.CreateSubscriptionRequest); !ok {
+			t.Fatalf("got %T want *ua.CreateSubscriptionRequest", req)
+		}
+		return h(&ua.CreateSubscriptionResponse{ResponseHeader: &ua.ResponseHeader{}, SubscriptionID: 7, RevisedPublishingInterval: 1000, RevisedLifetimeCount: 30, RevisedMaxKeepAliveCount: 10})
+	})
+	a.setState(Connected, nil)
+	a.subs[1] = &Subscription{SubscriptionID: 1, c: a, params: &SubscriptionParameters{}}
+
+	r.check(context.Background())
+
+	verify.Values(t, "calls", calls, []string{"a", "b"})
+	if r.Client() != b {
+		t.Fatal("no failover")
+	}
+	if _, ok := b.subs[7]; !ok {
+		t.Fatal("subscription not moved")
+	}
+	if len(a.subs) != 0 {
+		t.Fatalf("got %d subscriptions on the previous server want 0", len(a.subs))
+	}
+}
+
+func TestRedundantClientDiscover(t *testing.T) {
+	r := NewRedundantClient([]string{"opc.tcp://a:4840"})
+	c := NewClient("opc.tcp://a:4840")
+	setFakeChannel(c, func(req ua.Request, h func(interface{}) error) error {
+		verify.Values(t, "uris", req.(*ua.FindServersRequest).ServerURIs, []string{"urn:a", "urn:b"})
+		return h(&ua.FindServersResponse{
+			Servers: []*ua.ApplicationDescription{
+				{ApplicationURI: "urn:a", DiscoveryURLs: []string{"opc.tcp://a:4840"}},
+				{ApplicationURI: "urn:b", DiscoveryURLs: []string{"https://b", "opc.tcp://b:4840", "opc.tcp://b:4841"}},
+			},
+		})
+	})
+	r.discover(c, []string{"urn:a", "urn:b"})
+	verify.Values(t, "", r.endpoints, []string{"opc.tcp://a:4840", "opc.tcp://b:4840"})
+}
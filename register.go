@@ -0,0 +1,212 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// RegisteredNodes is a handle for a set of nodes which have been registered
+// with the server for efficient repeated access. Reads and writes use the
+// node ids assigned by the server.
+//
+// Registered node ids are only valid within a session. The client registers
+// the nodes again when it has to recreate the session after a reconnect.
+//
+// See Part 4, 5.8.5
+type RegisteredNodes struct {
+	c *Client
+
+	mu         sync.RWMutex
+	nodeIDs    []*ua.NodeID // node ids as requested
+	registered []*ua.NodeID // node ids as returned by the server
+	closed     bool
+}
+
+// RegisterNodeIDs registers the nodes in a single request and returns a
+// handle to access them. The caller must call Close to unregister the
+// nodes when they are no longer needed.
+func (c *Client) RegisterNodeIDs(ids ...*ua.NodeID) (*RegisteredNodes, error) {
+	if len(ids) == 0 {
+		return nil, errors.Errorf("no nodes to register")
+	}
+	r := &RegisteredNodes{
+		c:       c,
+		nodeIDs: ids,
+	}
+	if err := r.register(); err != nil {
+		return nil, err
+	}
+
+	c.regMu.Lock()
+	c.regs[r] = struct{}{}
+	c.regMu.Unlock()
+	return r, nil
+}
+
+// register registers the nodes with the server and
+// stores the node ids returned by the server.
+func (r *RegisteredNodes) register() error {
+	res, err := r.c.RegisterNodes(&ua.RegisterNodesRequest{
+		NodesToRegister: r.nodeIDs,
+	})
+	if err != nil {
+		return err
+	}
+	if len(res.RegisteredNodeIDs) != len(r.nodeIDs) {
+		return errors.Errorf("register nodes response length mismatch: got %d want %d", len(res.RegisteredNodeIDs), len(r.nodeIDs))
+	}
+
+	r.mu.Lock()
+	r.registered = res.RegisteredNodeIDs
+	r.mu.Unlock()
+	return nil
+}
+
+// NodeIDs returns the node ids assigned by the server in the
+// order of the node ids passed to RegisterNodeIDs.
+func (r *RegisteredNodes) NodeIDs() []*ua.NodeID {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]*ua.NodeID, len(r.registered))
+	copy(ids, r.registered)
+	return ids
+}
+
+// Read reads the values of all registered nodes in a single request.
+// The results are in the order of the node ids passed to RegisterNodeIDs.
+func (r *RegisteredNodes) Read() ([]*ua.DataValue, error) {
+	return r.ReadWithMaxAge(0)
+}
+
+// ReadWithMaxAge reads the values of all registered nodes and accepts
+// values from the cache of the server which are not older than maxAge.
+// A maxAge of 0 makes the server read the current values.
+func (r *RegisteredNodes) ReadWithMaxAge(maxAge time.Duration) ([]*ua.DataValue, error) {
+	r.mu.RLock()
+	if r.closed {
+		r.mu.RUnlock()
+		return nil, errors.Errorf("registered nodes closed")
+	}
+	rvs := make([]*ua.ReadValueID, len(r.registered))
+	for i, id := range r.registered {
+		rvs[i] = &ua.ReadValueID{NodeID: id}
+	}
+	r.mu.RUnlock()
+
+	res, err := r.c.Read(&ua.ReadRequest{
+		MaxAge:             float64(maxAge / time.Millisecond),
+		NodesToRead:        rvs,
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Results) != len(rvs) {
+		return nil, errors.Errorf("read response length mismatch: got %d want %d", len(res.Results), len(rvs))
+	}
+	return res.Results, nil
+}
+
+// Write writes the values to the registered nodes in a single request.
+// The number of values must match the number of registered nodes and
+// a nil value skips the node.
+func (r *RegisteredNodes) Write(values ...*ua.DataValue) ([]ua.StatusCode, error) {
+	r.mu.RLock()
+	if r.closed {
+		r.mu.RUnlock()
+		return nil, errors.Errorf("registered nodes closed")
+	}
+	if len(values) != len(r.registered) {
+		r.mu.RUnlock()
+		return nil, errors.Errorf("got %d values for %d registered nodes", len(values), len(r.registered))
+	}
+
+	// map the results back to the position of the value
+	var (
+		wvs []*ua.WriteValue
+		idx []int
+	)
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		wvs = append(wvs, &ua.WriteValue{
+			NodeID:      r.registered[i],
+			AttributeID: ua.AttributeIDValue,
+			Value:       v,
+		})
+		idx = append(idx, i)
+	}
+	r.mu.RUnlock()
+
+	results := make([]ua.StatusCode, len(values))
+	if len(wvs) == 0 {
+		return results, nil
+	}
+
+	res, err := r.c.Write(&ua.WriteRequest{NodesToWrite: wvs})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Results) != len(wvs) {
+		return nil, errors.Errorf("write response length mismatch: got %d want %d", len(res.Results), len(wvs))
+	}
+	for i, status := range res.Results {
+		results[idx[i]] = status
+	}
+	return results, nil
+}
+
+// Close unregisters the nodes and removes the handle from the client.
+func (r *RegisteredNodes) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	ids := r.registered
+	r.mu.Unlock()
+
+	r.c.regMu.Lock()
+	delete(r.c.regs, r)
+	r.c.regMu.Unlock()
+
+	_, err := r.c.UnregisterNodes(&ua.UnregisterNodesRequest{
+		NodesToUnregister: ids,
+	})
+	return err
+}
+
+// reregisterNodes registers all node handles again after the
+// session has been recreated.
+func (c *Client) reregisterNodes() error {
+	dlog := debug.NewPrefixLogger("client: reregister nodes: ")
+
+	c.regMu.Lock()
+	regs := make([]*RegisteredNodes, 0, len(c.regs))
+	for r := range c.regs {
+		regs = append(regs, r)
+	}
+	c.regMu.Unlock()
+
+	var firstErr error
+	for _, r := range regs {
+		if err := r.register(); err != nil {
+			dlog.Printf("failed: %v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
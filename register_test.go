@@ -0,0 +1,143 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/goe/verify"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// registerServer assigns the string node id "<session>:<id>" to a
+// registered node. session is incremented to simulate a new session.
+type registerServer struct {
+	t       *testing.T
+	session int
+
+	// results overrides the number of read results if it is not negative.
+	results int
+
+	reads        []*ua.ReadRequest
+	writes       []*ua.WriteRequest
+	unregistered []*ua.NodeID
+}
+
+func (s *registerServer) client() *Client {
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		switch r := req.(type) {
+		case *ua.RegisterNodesRequest:
+			res := &ua.RegisterNodesResponse{}
+			for _, id := range r.NodesToRegister {
+				res.RegisteredNodeIDs = append(res.RegisteredNodeIDs, ua.NewStringNodeID(1, fmt.Sprintf("%d:%d", s.session, id.IntID())))
+			}
+			return h(res)
+		case *ua.UnregisterNodesRequest:
+			s.unregistered = append(s.unregistered, r.NodesToUnregister...)
+			return h(&ua.UnregisterNodesResponse{})
+		case *ua.ReadRequest:
+			s.reads = append(s.reads, r)
+			n := len(r.NodesToRead)
+			if s.results >= 0 {
+				n = s.results
+			}
+			res := &ua.ReadResponse{}
+			for i := 0; i < n; i++ {
+				res.Results = append(res.Results, &ua.DataValue{Value: ua.MustVariant(r.NodesToRead[i%len(r.NodesToRead)].NodeID.StringID())})
+			}
+			return h(res)
+		case *ua.WriteRequest:
+			s.writes = append(s.writes, r)
+			res := &ua.WriteResponse{}
+			for range r.NodesToWrite {
+				res.Results = append(res.Results, ua.StatusBadNotWritable)
+			}
+			return h(res)
+		default:
+			s.t.Fatalf("unexpected request %T", req)
+			return nil
+		}
+	})
+	return c
+}
+
+func variantValues(dvs []*ua.DataValue) []interface{} {
+	var v []interface{}
+	for _, dv := range dvs {
+		v = append(v, dv.Value.Value())
+	}
+	return v
+}
+
+func TestRegisteredNodes(t *testing.T) {
+	s := &registerServer{t: t, results: -1}
+	c := s.client()
+
+	if _, err := c.RegisterNodeIDs(); err == nil {
+		t.Fatal("got nil want error for no nodes")
+	}
+
+	r, err := c.RegisterNodeIDs(ua.NewNumericNodeID(0, 1), ua.NewNumericNodeID(0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "node ids", r.NodeIDs(), []*ua.NodeID{ua.NewStringNodeID(1, "0:1"), ua.NewStringNodeID(1, "0:2")})
+
+	dvs, err := r.ReadWithMaxAge(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "read", variantValues(dvs), []interface{}{"0:1", "0:2"})
+	verify.Values(t, "max age", s.reads[0].MaxAge, 2000.0)
+
+	// the values are mapped back to their position
+	status, err := r.Write(nil, &ua.DataValue{Value: ua.MustVariant(int32(5))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "write status", status, []ua.StatusCode{ua.StatusOK, ua.StatusBadNotWritable})
+	verify.Values(t, "write node", s.writes[0].NodesToWrite[0].NodeID, ua.NewStringNodeID(1, "0:2"))
+	if _, err := r.Write(nil); err == nil {
+		t.Fatal("got nil want error for value count")
+	}
+
+	// the session has been recreated
+	s.session++
+	if err := c.reregisterNodes(); err != nil {
+		t.Fatal(err)
+	}
+	dvs, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "read after reregister", variantValues(dvs), []interface{}{"1:1", "1:2"})
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "unregistered", s.unregistered, []*ua.NodeID{ua.NewStringNodeID(1, "1:1"), ua.NewStringNodeID(1, "1:2")})
+	verify.Values(t, "handles", len(c.regs), 0)
+	if _, err := r.Read(); err == nil {
+		t.Fatal("got nil want error after close")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "unregistered twice", len(s.unregistered), 2)
+}
+
+func TestRegisteredNodesReadMismatch(t *testing.T) {
+	s := &registerServer{t: t, results: 1}
+	r, err := s.client().RegisterNodeIDs(ua.NewNumericNodeID(0, 1), ua.NewNumericNodeID(0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(); err == nil {
+		t.Fatal("got nil want error for missing results")
+	}
+}
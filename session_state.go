@@ -0,0 +1,281 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// SessionState is the state of a session which is required to activate
+// the session again from a different process, e.g. after a restart.
+// It can be serialized with encoding/json.
+//
+// The state contains the authentication token of the session which
+// grants access to the session and must be kept secret.
+type SessionState struct {
+	// EndpointURL is the endpoint of the server.
+	EndpointURL string
+
+	// SessionID is the id of the session.
+	SessionID *ua.NodeID
+
+	// AuthenticationToken is the secret token which identifies the session.
+	AuthenticationToken *ua.NodeID
+
+	// SessionTimeout is the session timeout revised by the server.
+	// The session has to be resumed before it expires.
+	SessionTimeout time.Duration
+
+	// ServerNonce is the last nonce received from the server.
+	ServerNonce []byte
+
+	// ServerCertificate is the certificate of the server.
+	ServerCertificate []byte
+
+	// Subscriptions are the subscriptions of the session.
+	Subscriptions []*SubscriptionState
+
+	// PendingAcks are the notifications which have been received
+	// but not yet acknowledged.
+	PendingAcks []*ua.SubscriptionAcknowledgement
+
+	// Saved is the time when the state was taken.
+	Saved time.Time
+}
+
+// Expired returns true if the server has already closed the session
+// since it was saved more than SessionTimeout ago.
+func (s *SessionState) Expired() bool {
+	return s.SessionTimeout > 0 && time.Since(s.Saved) > s.SessionTimeout
+}
+
+// SubscriptionState is the state of a subscription of a SessionState.
+type SubscriptionState struct {
+	SubscriptionID            uint32
+	RevisedPublishingInterval time.Duration
+	RevisedLifetimeCount      uint32
+	RevisedMaxKeepAliveCount  uint32
+	Params                    *SubscriptionParameters
+
+	// NextSequenceNumber is the sequence number of
+	// the next expected notification.
+	NextSequenceNumber uint32
+}
+
+// SessionState returns the state of the active session and its
+// subscriptions.
+//
+// To hand the session over to another process call DetachSession before
+// Close so that the session and its subscriptions are not deleted. The
+// server keeps them until the session timeout or the subscription
+// lifetime expires.
+func (c *Client) SessionState() (*SessionState, error) {
+	s := c.Session()
+	if s == nil {
+		return nil, errors.Errorf("no active session")
+	}
+
+	st := &SessionState{
+		EndpointURL:         c.endpointURL,
+		SessionID:           s.resp.SessionID,
+		AuthenticationToken: s.resp.AuthenticationToken,
+		SessionTimeout:      time.Duration(s.resp.RevisedSessionTimeout) * time.Millisecond,
+		ServerNonce:         s.serverNonce,
+		ServerCertificate:   s.serverCertificate,
+		Saved:               time.Now(),
+	}
+
+	c.subMux.RLock()
+	for _, sub := range c.subs {
+		st.Subscriptions = append(st.Subscriptions, &SubscriptionState{
+			SubscriptionID:            sub.SubscriptionID,
+			RevisedPublishingInterval: sub.RevisedPublishingInterval,
+			RevisedLifetimeCount:      sub.RevisedLifetimeCount,
+			RevisedMaxKeepAliveCount:  sub.RevisedMaxKeepAliveCount,
+			Params:                    sub.params,
+			NextSequenceNumber:        sub.nextSeq,
+		})
+	}
+	c.subMux.RUnlock()
+
+	c.pendingAcksMux.RLock()
+	st.PendingAcks = append(st.PendingAcks, c.pendingAcks...)
+	c.pendingAcksMux.RUnlock()
+
+	return st, nil
+}
+
+// ResumeSession establishes a secure channel and activates the session
+// from the state instead of creating a new one. The subscriptions of the
+// session are transferred to the client with TransferSubscriptions and
+// their notifications are sent to notifyCh. Notifications which the
+// server has queued in the meantime are delivered with the next publish
+// responses.
+//
+// The client uses the identity configured with the options to activate
+// the session. It must be the identity which created the session.
+//
+// Subscriptions which cannot be transferred are not recreated since the
+// monitored items are not part of the state. Their ids are reported in
+// the error. The transferred subscriptions are returned in any case.
+func (c *Client) ResumeSession(ctx context.Context, st *SessionState, notifyCh chan *PublishNotificationData) ([]*Subscription, error) {
+	dlog := debug.NewPrefixLogger("client: resume session: ")
+
+	if c.sechan != nil {
+		return nil, errors.Errorf("already connected")
+	}
+	if st == nil || st.AuthenticationToken == nil {
+		return nil, errors.Errorf("invalid session state")
+	}
+	if st.Expired() {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+	if c.endpointURL == "" {
+		c.endpointURL = st.EndpointURL
+	}
+	if c.endpointURL != st.EndpointURL {
+		return nil, errors.Errorf("session state is for endpoint %s", st.EndpointURL)
+	}
+
+	c.setState(Connecting, nil)
+	if err := c.Dial(ctx); err != nil {
+		c.setState(Closed, err)
+		return nil, err
+	}
+
+	if c.sessionCfg.UserIdentityToken == nil {
+		p := defaultAnonymousPolicyID
+		if res, err := c.GetEndpoints(); err == nil {
+			p = anonymousPolicyID(res.Endpoints)
+		}
+		AuthAnonymous()(c.cfg, c.sessionCfg)
+		AuthPolicyID(p)(c.cfg, c.sessionCfg)
+	}
+
+	s := &Session{
+		cfg: c.sessionCfg,
+		resp: &ua.CreateSessionResponse{
+			SessionID:             st.SessionID,
+			AuthenticationToken:   st.AuthenticationToken,
+			RevisedSessionTimeout: float64(st.SessionTimeout / time.Millisecond),
+			ServerNonce:           st.ServerNonce,
+			ServerCertificate:     st.ServerCertificate,
+		},
+		serverNonce:       st.ServerNonce,
+		serverCertificate: st.ServerCertificate,
+	}
+	if err := c.ActivateSession(s); err != nil {
+		c.setState(Closed, err)
+		_ = c.Close()
+		return nil, err
+	}
+	dlog.Printf("session %s activated", st.SessionID)
+
+	c.pendingAcksMux.Lock()
+	c.pendingAcks = append(c.pendingAcks, st.PendingAcks...)
+	c.pendingAcksMux.Unlock()
+
+	c.autoTune()
+	c.setState(Connected, nil)
+	c.setErr(nil)
+	c.startMonitor()
+
+	if len(st.Subscriptions) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint32, len(st.Subscriptions))
+	for i, ss := range st.Subscriptions {
+		ids[i] = ss.SubscriptionID
+	}
+	res, err := c.transferSubscriptions(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Results) != len(ids) {
+		return nil, errors.Errorf("transfer subscriptions response length mismatch: got %d want %d", len(res.Results), len(ids))
+	}
+
+	var (
+		subs   []*Subscription
+		failed []uint32
+	)
+	for i, r := range res.Results {
+		ss := st.Subscriptions[i]
+		if r.StatusCode != ua.StatusOK {
+			dlog.Printf("sub %d: transfer failed: %v", ss.SubscriptionID, r.StatusCode)
+			failed = append(failed, ss.SubscriptionID)
+			continue
+		}
+
+		params := ss.Params
+		if params == nil {
+			params = &SubscriptionParameters{}
+		}
+		params.setDefaults()
+
+		sub := &Subscription{
+			SubscriptionID:            ss.SubscriptionID,
+			RevisedPublishingInterval: ss.RevisedPublishingInterval,
+			RevisedLifetimeCount:      ss.RevisedLifetimeCount,
+			RevisedMaxKeepAliveCount:  ss.RevisedMaxKeepAliveCount,
+			Notifs:                    notifyCh,
+			params:                    params,
+			nextSeq:                   ss.NextSequenceNumber,
+			c:                         c,
+		}
+		if sub.nextSeq > 1 {
+			sub.lastSeq = sub.nextSeq - 1
+		}
+		if err := c.registerSubscription(sub); err != nil {
+			return subs, err
+		}
+		dlog.Printf("sub %d: transferred", ss.SubscriptionID)
+		subs = append(subs, sub)
+	}
+
+	c.subMux.Lock()
+	c.updatePublishTimeout()
+	c.subMux.Unlock()
+	if len(subs) > 0 {
+		c.resumeSubscriptions()
+	}
+
+	if len(failed) > 0 {
+		return subs, errors.Errorf("transfer of subscriptions %v failed", failed)
+	}
+	return subs, nil
+}
+
+// SaveSessionState writes the session state as JSON to the file.
+// The file is only readable by the owner since it contains the
+// authentication token.
+func SaveSessionState(filename string, st *SessionState) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0600)
+}
+
+// LoadSessionState reads a session state written by SaveSessionState.
+func LoadSessionState(filename string) (*SessionState, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var st SessionState
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
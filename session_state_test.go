@@ -0,0 +1,66 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/goe/verify"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestSessionState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opcua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	st := &SessionState{
+		EndpointURL:         "opc.tcp://example.com:4840",
+		SessionID:           ua.NewFourByteNodeID(1, 1234),
+		AuthenticationToken: ua.NewByteStringNodeID(0, []byte{0xca, 0xfe}),
+		SessionTimeout:      time.Minute,
+		ServerNonce:         []byte{1, 2, 3},
+		ServerCertificate:   []byte{4, 5, 6},
+		Subscriptions: []*SubscriptionState{
+			{
+				SubscriptionID:            7,
+				RevisedPublishingInterval: time.Second,
+				RevisedLifetimeCount:      10000,
+				RevisedMaxKeepAliveCount:  3000,
+				Params:                    &SubscriptionParameters{Interval: time.Second, Priority: 1},
+				NextSequenceNumber:        42,
+			},
+		},
+		PendingAcks: []*ua.SubscriptionAcknowledgement{
+			{SubscriptionID: 7, SequenceNumber: 41},
+		},
+		Saved: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	filename := filepath.Join(dir, "session.json")
+	if err := SaveSessionState(filename, st); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadSessionState(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", got, st)
+
+	if !got.Expired() {
+		t.Fatal("session state should be expired")
+	}
+	got.Saved = time.Now()
+	if got.Expired() {
+		t.Fatal("session state should not be expired")
+	}
+}
@@ -3,6 +3,9 @@ package opcua
 import (
 	"context"
 	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gopcua/opcua/debug"
@@ -22,6 +25,14 @@ const (
 
 const terminatedSubscriptionID uint32 = 0xC0CAC01B
 
+// maxRepublishGap is the maximum number of missing notifications which
+// are requested with Republish. Older notifications are reported as lost.
+const maxRepublishGap = 1000
+
+// errSubscriptionChanged is returned when the subscription has been
+// recreated or removed while the client was waiting for the server.
+var errSubscriptionChanged = errors.New("subscription changed")
+
 type Subscription struct {
 	SubscriptionID            uint32
 	RevisedPublishingInterval time.Duration
@@ -29,10 +40,184 @@ type Subscription struct {
 	RevisedMaxKeepAliveCount  uint32
 	Notifs                    chan *PublishNotificationData
 	params                    *SubscriptionParameters
-	items                     []*monitoredItem
+	publishingDisabled        bool
 	lastSeq                   uint32
 	nextSeq                   uint32
+	store                     NotificationStore
+	status                    int32
+	lastMessage               time.Time
+	keepAliveMissed           bool
 	c                         *Client
+
+	statsMu sync.Mutex
+	stats   PublishStats
+
+	// itemsMu guards items and their fields.
+	itemsMu sync.Mutex
+	items   []*monitoredItem
+
+	// deliverMu guards the notifications which wait for the
+	// subscriber and whether a goroutine delivers them.
+	deliverMu  sync.Mutex
+	deliveries []*delivery
+	delivering bool
+}
+
+// delivery is notification data which waits for the subscriber.
+type delivery struct {
+	ctx  context.Context
+	data []*PublishNotificationData
+
+	// msg is stored in store with the subscription id subID before
+	// data is delivered if both are set.
+	msg   *ua.NotificationMessage
+	store NotificationStore
+	subID uint32
+
+	// ack is queued on c once data has been delivered.
+	c   *Client
+	ack *ua.SubscriptionAcknowledgement
+}
+
+// PublishStats contains the results of the acknowledgements and the
+// recovery of missing notifications of a subscription.
+type PublishStats struct {
+	// Acked is the number of notifications which the server
+	// has removed from its retransmission queue.
+	Acked uint64
+
+	// Unknown is the number of acknowledgements for notifications
+	// which the server did not have anymore.
+	Unknown uint64
+
+	// Retried is the number of acknowledgements which failed
+	// and were sent again.
+	Retried uint64
+
+	// Republished is the number of missing notifications
+	// which were recovered with Republish.
+	Republished uint64
+
+	// Lost is the number of missing notifications
+	// which could not be recovered.
+	Lost uint64
+
+	// LastAckError is the status of the last failed acknowledgement.
+	LastAckError ua.StatusCode
+}
+
+// DataLossError is sent as the error of a PublishNotificationData
+// when notifications of a subscription were missing and could not
+// be recovered with Republish.
+type DataLossError struct {
+	SubscriptionID uint32
+
+	// FirstSequenceNumber is the sequence number of the first
+	// of Count consecutive notifications which were lost.
+	FirstSequenceNumber uint32
+	Count               uint32
+
+	// Err is the error of the Republish request, usually
+	// ua.StatusBadMessageNotAvailable.
+	Err error
+}
+
+func (e *DataLossError) Error() string {
+	return fmt.Sprintf("opcua: sub %d: lost %d notifications starting with %d: %v", e.SubscriptionID, e.Count, e.FirstSequenceNumber, e.Err)
+}
+
+// SubscriptionStatus describes whether the server
+// publishes the subscription for the client.
+type SubscriptionStatus int32
+
+const (
+	// SubscriptionActive is the status of a subscription
+	// which is published for the client.
+	SubscriptionActive SubscriptionStatus = iota
+
+	// SubscriptionDead is the status of a subscription which the server
+	// has closed with BadTimeout or BadSubscriptionIdInvalid. The client
+	// recreates it and reports the result with a RecreatedEvent.
+	SubscriptionDead
+
+	// SubscriptionTransferred is the status of a subscription which has
+	// been transferred to another session. It is no longer published for
+	// the client.
+	SubscriptionTransferred
+)
+
+// Status events of a subscription are sent as the Value of a
+// PublishNotificationData and not as its Error since they describe the
+// state of the subscription and not a failed delivery. They do not
+// implement the error interface.
+
+// StatusChangeEvent is sent as the Value of a PublishNotificationData
+// when the server reports a StatusChangeNotification for the subscription.
+//
+// See Part 4, 7.20.4 StatusChangeNotification parameter
+type StatusChangeEvent struct {
+	SubscriptionID uint32
+	Status         ua.StatusCode
+	DiagnosticInfo *ua.DiagnosticInfo
+}
+
+// KeepAliveTimeoutEvent is sent as the Value of a PublishNotificationData
+// when the server has not sent a notification or keep-alive message for
+// RevisedMaxKeepAliveCount times the RevisedPublishingInterval. It is sent
+// once until the next message arrives.
+type KeepAliveTimeoutEvent struct {
+	SubscriptionID uint32
+	Timeout        time.Duration
+
+	// LastMessage is the time of the last message
+	// or when the publish loop was resumed.
+	LastMessage time.Time
+}
+
+// RecreatedEvent is sent as the Value of a PublishNotificationData when
+// the client has recreated a subscription which the server has closed.
+// If all attempts failed Err is set and the subscription remains
+// SubscriptionDead.
+type RecreatedEvent struct {
+	PreviousSubscriptionID uint32
+	SubscriptionID         uint32
+
+	// Err is the error of the recreate attempt, if any.
+	Err error
+}
+
+// NotificationStore persists the notifications of a subscription
+// before they are acknowledged, e.g. in a write-ahead log.
+type NotificationStore interface {
+	// Store must return only after msg has been written durably.
+	// It is called by the delivery goroutine of the subscription
+	// without holding a lock of the client so that a slow store does
+	// not block the other subscriptions.
+	//
+	// subID is the id which the server has assigned to the subscription.
+	// It changes when the subscription is recreated, e.g. after the
+	// session has been lost, and the sequence numbers start again at 1.
+	// The change is reported as a *RecreatedEvent.
+	Store(subID uint32, msg *ua.NotificationMessage) error
+}
+
+// StoreError is sent as the error of a PublishNotificationData when a
+// notification could not be stored. The notification is neither
+// acknowledged nor delivered and it is requested again with Republish
+// when the next notification or keep-alive message arrives.
+type StoreError struct {
+	SubscriptionID uint32
+	SequenceNumber uint32
+	Err            error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("opcua: sub %d: store notif %d: %v", e.SubscriptionID, e.SequenceNumber, e.Err)
+}
+
+// Unwrap returns the error of the store.
+func (e *StoreError) Unwrap() error {
+	return e.Err
 }
 
 type SubscriptionParameters struct {
@@ -105,6 +290,17 @@ func (s *Subscription) delete() error {
 }
 
 func (s *Subscription) Monitor(ts ua.TimestampsToReturn, items ...*ua.MonitoredItemCreateRequest) (*ua.CreateMonitoredItemsResponse, error) {
+	// raise the sampling intervals to the minimum of the server
+	// without changing the items of the caller
+	items = append([]*ua.MonitoredItemCreateRequest(nil), items...)
+	for i, item := range items {
+		if p := s.c.samplingParameters(item.RequestedParameters); p != item.RequestedParameters {
+			cp := *item
+			cp.RequestedParameters = p
+			items[i] = &cp
+		}
+	}
+
 	// Part 4, 5.12.2.2 CreateMonitoredItems Service Parameters
 	req := &ua.CreateMonitoredItemsRequest{
 		SubscriptionID:     s.SubscriptionID,
@@ -121,13 +317,24 @@ func (s *Subscription) Monitor(ts ua.TimestampsToReturn, items ...*ua.MonitoredI
 		return nil, err
 	}
 
-	for _, result := range res.Results {
-		if status := result.StatusCode; status != ua.StatusOK {
-			return nil, status
+	for i, result := range res.Results {
+		status := result.StatusCode
+		if status == ua.StatusOK {
+			continue
+		}
+		if isFilterError(status) && i < len(items) {
+			return nil, &FilterError{
+				NodeID:       items[i].ItemToMonitor.NodeID,
+				Status:       status,
+				FilterResult: result.FilterResult,
+			}
 		}
+		return nil, status
 	}
 
 	// store monitored items
+	s.itemsMu.Lock()
+	defer s.itemsMu.Unlock()
 	for i, item := range items {
 		result := res.Results[i]
 
@@ -156,6 +363,124 @@ func (s *Subscription) Unmonitor(monitoredItemIDs ...uint32) (*ua.DeleteMonitore
 	return res, err
 }
 
+// ModifyMonitoredItems changes the sampling interval, queue size, discard
+// policy and filter of monitored items. The modified parameters of the
+// successful items are kept when the subscription is recreated. If an
+// item fails the response is returned together with the first error.
+func (s *Subscription) ModifyMonitoredItems(ts ua.TimestampsToReturn, items ...*ua.MonitoredItemModifyRequest) (*ua.ModifyMonitoredItemsResponse, error) {
+	// raise the sampling intervals to the minimum of the server
+	// without changing the items of the caller
+	items = append([]*ua.MonitoredItemModifyRequest(nil), items...)
+	for i, item := range items {
+		if p := s.c.samplingParameters(item.RequestedParameters); p != item.RequestedParameters {
+			cp := *item
+			cp.RequestedParameters = p
+			items[i] = &cp
+		}
+	}
+
+	// Part 4, 5.12.3.2 ModifyMonitoredItems Service Parameters
+	req := &ua.ModifyMonitoredItemsRequest{
+		SubscriptionID:     s.SubscriptionID,
+		TimestampsToReturn: ts,
+		ItemsToModify:      items,
+	}
+
+	var res *ua.ModifyMonitoredItemsResponse
+	err := s.c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status := res.ResponseHeader.ServiceResult; status != ua.StatusOK {
+		return nil, status
+	}
+	if len(res.Results) != len(items) {
+		return nil, ua.StatusBadUnexpectedError
+	}
+
+	s.itemsMu.Lock()
+	defer s.itemsMu.Unlock()
+
+	var firstErr error
+	for i, result := range res.Results {
+		if status := result.StatusCode; status != ua.StatusOK {
+			if firstErr == nil {
+				firstErr = status
+				if mi := s.item(items[i].MonitoredItemID); mi != nil && isFilterError(status) {
+					firstErr = &FilterError{
+						NodeID:       mi.ItemToMonitor.NodeID,
+						Status:       status,
+						FilterResult: result.FilterResult,
+					}
+				}
+			}
+			continue
+		}
+		if mi := s.item(items[i].MonitoredItemID); mi != nil {
+			mi.MonitoringParameters = items[i].RequestedParameters
+			mi.TimestampsToReturn = ts
+		}
+	}
+	return res, firstErr
+}
+
+// SetMonitoringMode switches monitored items between Disabled, Sampling
+// and Reporting. The mode of the successful items is kept when the
+// subscription is recreated. If an item fails the response is returned
+// together with the first error.
+func (s *Subscription) SetMonitoringMode(mode ua.MonitoringMode, monitoredItemIDs ...uint32) (*ua.SetMonitoringModeResponse, error) {
+	// Part 4, 5.12.4.2 SetMonitoringMode Service Parameters
+	req := &ua.SetMonitoringModeRequest{
+		SubscriptionID:   s.SubscriptionID,
+		MonitoringMode:   mode,
+		MonitoredItemIDs: monitoredItemIDs,
+	}
+
+	var res *ua.SetMonitoringModeResponse
+	err := s.c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status := res.ResponseHeader.ServiceResult; status != ua.StatusOK {
+		return nil, status
+	}
+	if len(res.Results) != len(monitoredItemIDs) {
+		return nil, ua.StatusBadUnexpectedError
+	}
+
+	s.itemsMu.Lock()
+	defer s.itemsMu.Unlock()
+
+	var firstErr error
+	for i, status := range res.Results {
+		if status != ua.StatusOK {
+			if firstErr == nil {
+				firstErr = status
+			}
+			continue
+		}
+		if mi := s.item(monitoredItemIDs[i]); mi != nil {
+			mi.MonitoringMode = mode
+		}
+	}
+	return res, firstErr
+}
+
+// item returns the stored monitored item with the server assigned id.
+// The caller must hold itemsMu.
+func (s *Subscription) item(monitoredItemID uint32) *monitoredItem {
+	for _, mi := range s.items {
+		if mi.createResult != nil && mi.createResult.MonitoredItemID == monitoredItemID {
+			return mi
+		}
+	}
+	return nil
+}
+
 // SetTriggering sends a request to the server to add and/or remove triggering links from a triggering item.
 // To add links from a triggering item to an item to report provide the server assigned ID(s) in the `add` argument.
 // To remove links from a triggering item to an item to report provide the server assigned ID(s) in the `remove` argument.
@@ -175,6 +500,99 @@ func (s *Subscription) SetTriggering(triggeringItemID uint32, add, remove []uint
 	return res, err
 }
 
+// ModifySubscription changes the parameters of the subscription. Parameters
+// that have not been set are set to their default values. The revised
+// values of the server are stored in the subscription and the new
+// parameters are used when the subscription is recreated.
+func (s *Subscription) ModifySubscription(params SubscriptionParameters) (*ua.ModifySubscriptionResponse, error) {
+	// Part 4, 5.13.3.2 ModifySubscription Service Parameters
+	params.setDefaults()
+	req := &ua.ModifySubscriptionRequest{
+		SubscriptionID:              s.SubscriptionID,
+		RequestedPublishingInterval: float64(params.Interval / time.Millisecond),
+		RequestedLifetimeCount:      params.LifetimeCount,
+		RequestedMaxKeepAliveCount:  params.MaxKeepAliveCount,
+		MaxNotificationsPerPublish:  params.MaxNotificationsPerPublish,
+		Priority:                    params.Priority,
+	}
+
+	var res *ua.ModifySubscriptionResponse
+	err := s.c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status := res.ResponseHeader.ServiceResult; status != ua.StatusOK {
+		return nil, status
+	}
+
+	s.c.subMux.Lock()
+	defer s.c.subMux.Unlock()
+
+	s.params = &params
+	s.RevisedPublishingInterval = time.Duration(res.RevisedPublishingInterval) * time.Millisecond
+	s.RevisedLifetimeCount = res.RevisedLifetimeCount
+	s.RevisedMaxKeepAliveCount = res.RevisedMaxKeepAliveCount
+	s.c.updatePublishTimeout()
+
+	return res, nil
+}
+
+// SetPublishingMode enables or disables sending notifications for the
+// subscription. The server still sends keep-alive messages while
+// publishing is disabled. The mode is kept when the subscription is
+// recreated.
+func (s *Subscription) SetPublishingMode(enabled bool) error {
+	// Part 4, 5.13.4.2 SetPublishingMode Service Parameters
+	req := &ua.SetPublishingModeRequest{
+		PublishingEnabled: enabled,
+		SubscriptionIDs:   []uint32{s.SubscriptionID},
+	}
+
+	var res *ua.SetPublishingModeResponse
+	err := s.c.Send(req, func(v interface{}) error {
+		return safeAssign(v, &res)
+	})
+	if err != nil {
+		return err
+	}
+	if status := res.ResponseHeader.ServiceResult; status != ua.StatusOK {
+		return status
+	}
+	if len(res.Results) != 1 {
+		return ua.StatusBadUnexpectedError
+	}
+	if status := res.Results[0]; status != ua.StatusOK {
+		return status
+	}
+
+	s.c.subMux.Lock()
+	s.publishingDisabled = !enabled
+	s.c.subMux.Unlock()
+	return nil
+}
+
+// Status returns whether the server publishes the subscription for the client.
+func (s *Subscription) Status() SubscriptionStatus {
+	return SubscriptionStatus(atomic.LoadInt32(&s.status))
+}
+
+func (s *Subscription) setStatus(status SubscriptionStatus) {
+	atomic.StoreInt32(&s.status, int32(status))
+}
+
+// keepAliveTimeout returns the time after which the server
+// must have sent a notification or a keep-alive message. It allows
+// for one more publishing interval since the server may send the
+// keep-alive late and for the interval in which it is checked.
+func (s *Subscription) keepAliveTimeout() time.Duration {
+	if s.RevisedMaxKeepAliveCount == 0 || s.RevisedPublishingInterval == 0 {
+		return 0
+	}
+	return time.Duration(s.RevisedMaxKeepAliveCount+1)*s.RevisedPublishingInterval + keepAliveCheckInterval
+}
+
 func (s *Subscription) publishTimeout() time.Duration {
 	timeout := time.Duration(s.RevisedMaxKeepAliveCount) * s.RevisedPublishingInterval // expected keepalive interval
 	if timeout > uasc.MaxTimeout {
@@ -186,7 +604,177 @@ func (s *Subscription) publishTimeout() time.Duration {
 	return timeout
 }
 
+// PublishStats returns the results of the acknowledgements and the
+// recovery of missing notifications since the subscription was created.
+func (s *Subscription) PublishStats() PublishStats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	return s.stats
+}
+
+// addStats adds the counters to the statistics. It ignores a nil
+// subscription so that it can be called for unknown subscription ids.
+func (s *Subscription) addStats(d PublishStats) {
+	if s == nil {
+		return
+	}
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	s.stats.Acked += d.Acked
+	s.stats.Unknown += d.Unknown
+	s.stats.Retried += d.Retried
+	s.stats.Republished += d.Republished
+	s.stats.Lost += d.Lost
+	if d.LastAckError != 0 {
+		s.stats.LastAckError = d.LastAckError
+	}
+}
+
+// nextSequenceNumber returns the sequence number after seq.
+// Sequence numbers roll over to 1 after the maximum.
+//
+// See Part 4, 7.22
+func nextSequenceNumber(seq uint32) uint32 {
+	if seq == math.MaxUint32 {
+		return 1
+	}
+	return seq + 1
+}
+
+// addSequenceNumber returns the sequence number which is n after seq.
+// Sequence numbers roll over to 1 after math.MaxUint32.
+func addSequenceNumber(seq, n uint32) uint32 {
+	s := uint64(seq) + uint64(n)
+	if s > math.MaxUint32 {
+		s -= math.MaxUint32
+	}
+	return uint32(s)
+}
+
+// sequenceGap returns the number of sequence numbers from next up to but
+// not including seq. after is false if seq is before next, e.g. for a
+// notification which has been received already.
+func sequenceGap(next, seq uint32) (n uint32, after bool) {
+	n = seq - next
+	if seq < next {
+		// rolled over and skipped 0
+		n--
+	}
+	if n > math.MaxUint32/2 {
+		return 0, false
+	}
+	return n, true
+}
+
+// SetStore sets the store which persists the notifications before
+// they are acknowledged. The notifications are still delivered to the
+// Notifs channel if it is not nil.
+func (s *Subscription) SetStore(store NotificationStore) {
+	s.c.subMux.Lock()
+	s.store = store
+	s.c.subMux.Unlock()
+}
+
+// deliver queues the notification data for the subscriber without
+// blocking. The data is sent to the Notifs channel in order by a
+// goroutine of the subscription so that a slow subscriber blocks
+// neither the publish loop nor the other subscriptions. ack is queued
+// on c once the data has been delivered, if it is not nil.
+func (s *Subscription) deliver(ctx context.Context, c *Client, ack *ua.SubscriptionAcknowledgement, data ...*PublishNotificationData) {
+	s.queue(&delivery{ctx: ctx, data: data, c: c, ack: ack})
+}
+
+// deliverMessage queues the notification data of msg like deliver.
+// If the subscription has a store, msg is stored before the data is
+// delivered. If msg cannot be stored a *StoreError is delivered instead of the data and
+// the notifications from msg on are requested again with Republish
+// when the next notification or keep-alive message arrives.
+//
+// The caller must hold the subMux lock.
+func (s *Subscription) deliverMessage(ctx context.Context, c *Client, msg *ua.NotificationMessage, ack *ua.SubscriptionAcknowledgement, data ...*PublishNotificationData) {
+	s.queue(&delivery{ctx: ctx, data: data, msg: msg, store: s.store, subID: s.SubscriptionID, c: c, ack: ack})
+}
+
+// queue adds d to the delivery queue and starts delivering.
+func (s *Subscription) queue(d *delivery) {
+	s.deliverMu.Lock()
+	defer s.deliverMu.Unlock()
+	s.deliveries = append(s.deliveries, d)
+	if !s.delivering {
+		s.delivering = true
+		go s.deliverAll()
+	}
+}
+
+// deliverAll delivers the queued notification data
+// until the queue is empty.
+func (s *Subscription) deliverAll() {
+	for {
+		s.deliverMu.Lock()
+		if len(s.deliveries) == 0 {
+			s.delivering = false
+			s.deliverMu.Unlock()
+			return
+		}
+		d := s.deliveries[0]
+		s.deliveries[0] = nil
+		s.deliveries = s.deliveries[1:]
+		s.deliverMu.Unlock()
+
+		if d.msg != nil && d.store != nil {
+			if err := d.store.Store(d.subID, d.msg); err != nil {
+				serr := &StoreError{SubscriptionID: d.subID, SequenceNumber: d.msg.SequenceNumber, Err: err}
+				debug.Printf("sub %d: %s", d.subID, serr)
+				s.notify(d.ctx, &PublishNotificationData{SubscriptionID: d.subID, Error: serr})
+				s.storeFailed(d)
+				continue
+			}
+		}
+
+		for _, data := range d.data {
+			s.notify(d.ctx, data)
+		}
+		// the notification has not been delivered
+		if d.ctx.Err() != nil {
+			continue
+		}
+		if d.ack != nil {
+			d.c.queueAcks(d.ack)
+		}
+	}
+}
+
+// storeFailed drops the queued messages of the subscription from the
+// message which could not be stored on and rewinds the next expected
+// sequence number to it so that they are requested again with Republish.
+func (s *Subscription) storeFailed(d *delivery) {
+	d.c.subMux.Lock()
+	defer d.c.subMux.Unlock()
+
+	// the subscription has been recreated in the meantime
+	if d.c.subs[d.subID] != s || s.SubscriptionID != d.subID {
+		return
+	}
+	s.nextSeq = d.msg.SequenceNumber
+	s.lastSeq = d.msg.SequenceNumber - 1
+
+	s.deliverMu.Lock()
+	defer s.deliverMu.Unlock()
+	var keep []*delivery
+	for _, q := range s.deliveries {
+		if q.msg != nil && q.subID == d.subID {
+			continue
+		}
+		keep = append(keep, q)
+	}
+	s.deliveries = keep
+}
+
 func (s *Subscription) notify(ctx context.Context, data *PublishNotificationData) {
+	if s.Notifs == nil {
+		return
+	}
 	select {
 	case <-ctx.Done():
 		return
@@ -248,25 +836,46 @@ func (s *Subscription) recreate() error {
 		return nil
 	}
 
-	params := s.params
-	{
-		req := &ua.DeleteSubscriptionsRequest{
-			SubscriptionIDs: []uint32{s.SubscriptionID},
-		}
-		var res *ua.DeleteSubscriptionsResponse
-		_ = s.c.Send(req, func(v interface{}) error {
-			return safeAssign(v, &res)
-		})
-		dlog.Print("subscription deleted")
-	}
+	_ = s.c.deleteSubscriptions(s.SubscriptionID)
+	dlog.Print("subscription deleted")
 	s.c.forgetSubscription(s.SubscriptionID)
 	dlog.Printf("subscription forgotton")
 
+	return s.create()
+}
+
+// create creates the subscription and its monitored items with the
+// previous parameters on the server of the client. It does not delete
+// the previous subscription.
+func (s *Subscription) create() error {
+	res, err := s.createSubscription()
+	if err != nil {
+		return err
+	}
+
+	s.c.subMux.Lock()
+	s.setCreated(res)
+	err = s.c.addSubscription(s)
+	s.c.subMux.Unlock()
+	if err != nil {
+		return err
+	}
+	debug.Printf("sub %d: recreate: subscription registered", s.SubscriptionID)
+
+	return s.createMonitoredItems()
+}
+
+// createSubscription creates a new subscription with the previous
+// parameters on the server. It does not modify the subscription.
+func (s *Subscription) createSubscription() (*ua.CreateSubscriptionResponse, error) {
+	dlog := debug.NewPrefixLogger("sub %d: recreate: ", s.SubscriptionID)
+
+	params := s.params
 	req := &ua.CreateSubscriptionRequest{
 		RequestedPublishingInterval: float64(params.Interval / time.Millisecond),
 		RequestedLifetimeCount:      params.LifetimeCount,
 		RequestedMaxKeepAliveCount:  params.MaxKeepAliveCount,
-		PublishingEnabled:           true,
+		PublishingEnabled:           !s.publishingDisabled,
 		MaxNotificationsPerPublish:  params.MaxNotificationsPerPublish,
 		Priority:                    params.Priority,
 	}
@@ -276,29 +885,38 @@ func (s *Subscription) recreate() error {
 	})
 	if err != nil {
 		dlog.Printf("failed to recreate subscription")
-		return err
+		return nil, err
 	}
 	// todo (unknownet): check if necessary
 	if status := res.ResponseHeader.ServiceResult; status != ua.StatusOK {
-		return status
+		return nil, status
 	}
 	dlog.Printf("recreated as subscription %d", res.SubscriptionID)
-	dlog.SetPrefix(fmt.Sprintf("sub %d: recreate: ", res.SubscriptionID))
+	return res, nil
+}
 
+// setCreated updates the subscription with the response of the
+// CreateSubscription request. The caller must hold the subMux lock.
+func (s *Subscription) setCreated(res *ua.CreateSubscriptionResponse) {
 	s.SubscriptionID = res.SubscriptionID
 	s.RevisedPublishingInterval = time.Duration(res.RevisedPublishingInterval) * time.Millisecond
 	s.RevisedLifetimeCount = res.RevisedLifetimeCount
 	s.RevisedMaxKeepAliveCount = res.RevisedMaxKeepAliveCount
 	s.lastSeq = 0
 	s.nextSeq = 1
+	s.lastMessage = time.Now()
+	s.keepAliveMissed = false
+}
 
-	if err := s.c.registerSubscription(s); err != nil {
-		return err
-	}
-	dlog.Printf("subscription registered")
+// createMonitoredItems creates the monitored items of the subscription
+// on the server.
+func (s *Subscription) createMonitoredItems() error {
+	dlog := debug.NewPrefixLogger("sub %d: recreate: ", s.SubscriptionID)
 
 	// Sort by timestamp to return
 	itemsByTs := make(map[ua.TimestampsToReturn][]*ua.MonitoredItemCreateRequest)
+	monitoredByTs := make(map[ua.TimestampsToReturn][]*monitoredItem)
+	s.itemsMu.Lock()
 	for _, m := range s.items {
 		cr := &ua.MonitoredItemCreateRequest{
 			ItemToMonitor:       m.ItemToMonitor,
@@ -306,7 +924,9 @@ func (s *Subscription) recreate() error {
 			RequestedParameters: m.MonitoringParameters,
 		}
 		itemsByTs[m.TimestampsToReturn] = append(itemsByTs[m.TimestampsToReturn], cr)
+		monitoredByTs[m.TimestampsToReturn] = append(monitoredByTs[m.TimestampsToReturn], m)
 	}
+	s.itemsMu.Unlock()
 
 	for ts, items := range itemsByTs {
 		req := &ua.CreateMonitoredItemsRequest{
@@ -329,9 +949,15 @@ func (s *Subscription) recreate() error {
 			}
 		}
 
-		for i, m := range s.items {
+		if len(res.Results) != len(items) {
+			return ua.StatusBadUnexpectedError
+		}
+
+		s.itemsMu.Lock()
+		for i, m := range monitoredByTs[ts] {
 			m.createResult = res.Results[i]
 		}
+		s.itemsMu.Unlock()
 	}
 	dlog.Printf("subscription successfully recreated")
 
@@ -0,0 +1,941 @@
+package opcua
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestSequenceGap(t *testing.T) {
+	tests := []struct {
+		next, seq uint32
+		n         uint32
+		after     bool
+	}{
+		{1, 1, 0, true},
+		{1, 2, 1, true},
+		{1, 5, 4, true},
+		{5, 4, 0, false},
+		{5, 1, 0, false},
+		{math.MaxUint32, 1, 1, true},
+		{math.MaxUint32 - 1, 2, 3, true},
+		{2, math.MaxUint32, 0, false},
+	}
+	for _, tt := range tests {
+		n, after := sequenceGap(tt.next, tt.seq)
+		if n != tt.n || after != tt.after {
+			t.Errorf("sequenceGap(%d, %d): got %d, %v want %d, %v", tt.next, tt.seq, n, after, tt.n, tt.after)
+		}
+	}
+}
+
+func TestNextSequenceNumber(t *testing.T) {
+	if got, want := nextSequenceNumber(1), uint32(2); got != want {
+		t.Errorf("got %d want %d", got, want)
+	}
+	if got, want := nextSequenceNumber(math.MaxUint32), uint32(1); got != want {
+		t.Errorf("got %d want %d", got, want)
+	}
+}
+
+func TestHandleAcks(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	sub := &Subscription{SubscriptionID: 1, c: c}
+	c.subs[1] = sub
+
+	acks := []*ua.SubscriptionAcknowledgement{
+		{SubscriptionID: 1, SequenceNumber: 1},
+		{SubscriptionID: 1, SequenceNumber: 2},
+		{SubscriptionID: 1, SequenceNumber: 3},
+		{SubscriptionID: 2, SequenceNumber: 1},
+	}
+	res := []ua.StatusCode{
+		ua.StatusOK,
+		ua.StatusBadSequenceNumberUnknown,
+		ua.StatusBadInternalError,
+		ua.StatusBadSubscriptionIDInvalid,
+	}
+	c.handleAcks(acks, res)
+
+	verify.Values(t, "pendingAcks", c.pendingAcks, []*ua.SubscriptionAcknowledgement{
+		{SubscriptionID: 1, SequenceNumber: 3},
+	})
+	verify.Values(t, "stats", sub.PublishStats(), PublishStats{
+		Acked:        1,
+		Unknown:      1,
+		Retried:      1,
+		LastAckError: ua.StatusBadInternalError,
+	})
+}
+
+func TestHandleNotificationDuplicate(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	ch := make(chan *PublishNotificationData, 1)
+	sub := &Subscription{SubscriptionID: 1, Notifs: ch, lastSeq: 4, nextSeq: 5, c: c}
+	c.subs[1] = sub
+
+	c.handleNotification(context.Background(), &ua.PublishResponse{
+		SubscriptionID: 1,
+		NotificationMessage: &ua.NotificationMessage{
+			SequenceNumber:   4,
+			NotificationData: []*ua.ExtensionObject{ua.NewExtensionObject(&ua.DataChangeNotification{})},
+		},
+	})
+
+	if len(ch) != 0 {
+		t.Fatal("duplicate notification was delivered")
+	}
+	if sub.nextSeq != 5 {
+		t.Fatalf("got next sequence number %d want 5", sub.nextSeq)
+	}
+	if len(c.pendingAcks) != 0 {
+		t.Fatalf("got acks %v want none", c.pendingAcks)
+	}
+}
+
+// waitDelivered waits until the queued notification data of the
+// subscription has been delivered.
+func waitDelivered(t *testing.T, sub *Subscription) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		sub.deliverMu.Lock()
+		busy := sub.delivering
+		sub.deliverMu.Unlock()
+		if !busy {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("notifications not delivered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type failingStore struct{ err error }
+
+func (s *failingStore) Store(uint32, *ua.NotificationMessage) error { return s.err }
+
+func TestHandleNotificationStoreError(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	ch := make(chan *PublishNotificationData, 2)
+	sub := &Subscription{SubscriptionID: 1, Notifs: ch, lastSeq: 4, nextSeq: 5, c: c}
+	sub.SetStore(&failingStore{err: ua.StatusBadOutOfMemory})
+	c.subs[1] = sub
+
+	c.handleNotification(context.Background(), &ua.PublishResponse{
+		SubscriptionID: 1,
+		NotificationMessage: &ua.NotificationMessage{
+			SequenceNumber:   5,
+			NotificationData: []*ua.ExtensionObject{ua.NewExtensionObject(&ua.DataChangeNotification{})},
+		},
+	})
+	waitDelivered(t, sub)
+
+	if len(ch) != 1 {
+		t.Fatalf("got %d notifications want 1", len(ch))
+	}
+	verify.Values(t, "error", (<-ch).Error, &StoreError{SubscriptionID: 1, SequenceNumber: 5, Err: ua.StatusBadOutOfMemory})
+	if sub.nextSeq != 5 {
+		t.Fatalf("got next sequence number %d want 5", sub.nextSeq)
+	}
+	if len(c.pendingAcks) != 0 {
+		t.Fatalf("got acks %v want none", c.pendingAcks)
+	}
+}
+
+// lockingStore takes the subscription lock of the client to verify that
+// it is not called while the lock is held.
+type lockingStore struct {
+	c    *Client
+	msgs chan *ua.NotificationMessage
+}
+
+func (s *lockingStore) Store(_ uint32, msg *ua.NotificationMessage) error {
+	s.c.subMux.Lock()
+	s.c.subMux.Unlock()
+	s.msgs <- msg
+	return nil
+}
+
+func TestHandleNotificationStoreUnlocked(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	ch := make(chan *PublishNotificationData, 1)
+	sub := &Subscription{SubscriptionID: 1, Notifs: ch, lastSeq: 4, nextSeq: 5, c: c}
+	store := &lockingStore{c: c, msgs: make(chan *ua.NotificationMessage, 1)}
+	sub.SetStore(store)
+	c.subs[1] = sub
+
+	msg := &ua.NotificationMessage{
+		SequenceNumber:   5,
+		NotificationData: []*ua.ExtensionObject{ua.NewExtensionObject(&ua.DataChangeNotification{})},
+	}
+	c.subMux.Lock()
+	c.handleNotification(context.Background(), &ua.PublishResponse{SubscriptionID: 1, NotificationMessage: msg})
+	c.subMux.Unlock()
+
+	select {
+	case got := <-store.msgs:
+		verify.Values(t, "stored", got, msg)
+	case <-time.After(time.Second):
+		t.Fatal("notification not stored")
+	}
+	waitDelivered(t, sub)
+
+	if len(ch) != 1 {
+		t.Fatalf("got %d notifications want 1", len(ch))
+	}
+	verify.Values(t, "acks", c.pendingAcks, []*ua.SubscriptionAcknowledgement{{SubscriptionID: 1, SequenceNumber: 5}})
+}
+
+func TestHandleStatusChangeTransferred(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	ch := make(chan *PublishNotificationData, 1)
+	sub := &Subscription{SubscriptionID: 1, Notifs: ch, nextSeq: 1, c: c}
+	c.subs[1] = sub
+
+	c.handleNotification(context.Background(), &ua.PublishResponse{
+		SubscriptionID: 1,
+		NotificationMessage: &ua.NotificationMessage{
+			SequenceNumber: 1,
+			NotificationData: []*ua.ExtensionObject{
+				ua.NewExtensionObject(&ua.StatusChangeNotification{Status: ua.StatusGoodSubscriptionTransferred}),
+			},
+		},
+	})
+
+	verify.Values(t, "event", (<-ch).Value, &StatusChangeEvent{SubscriptionID: 1, Status: ua.StatusGoodSubscriptionTransferred})
+	if got, want := sub.Status(), SubscriptionTransferred; got != want {
+		t.Fatalf("got status %v want %v", got, want)
+	}
+	if len(c.subs) != 0 {
+		t.Fatal("transferred subscription was not removed")
+	}
+}
+
+func TestCheckKeepAlives(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	ch := make(chan *PublishNotificationData, 1)
+	now := time.Now()
+	sub := &Subscription{
+		SubscriptionID:            1,
+		RevisedMaxKeepAliveCount:  3,
+		RevisedPublishingInterval: 100 * time.Millisecond,
+		Notifs:                    ch,
+		lastMessage:               now.Add(-1300 * time.Millisecond),
+		c:                         c,
+	}
+	c.subs[1] = sub
+
+	// the timeout includes one more publishing interval
+	// and the check interval.
+	timeout := 400*time.Millisecond + keepAliveCheckInterval
+	if got := sub.keepAliveTimeout(); got != timeout {
+		t.Fatalf("got timeout %v want %v", got, timeout)
+	}
+
+	// within the grace period
+	c.checkKeepAlives(context.Background(), now, time.Time{})
+	if sub.keepAliveMissed {
+		t.Fatal("got keep-alive timeout within grace period")
+	}
+
+	// the publish loop was resumed recently
+	sub.lastMessage = now.Add(-2 * time.Second)
+	c.checkKeepAlives(context.Background(), now, now.Add(-100*time.Millisecond))
+	if sub.keepAliveMissed {
+		t.Fatal("got keep-alive timeout within timeout after resume")
+	}
+
+	c.checkKeepAlives(context.Background(), now, time.Time{})
+	select {
+	case msg := <-ch:
+		verify.Values(t, "event", msg.Value, &KeepAliveTimeoutEvent{
+			SubscriptionID: 1,
+			Timeout:        timeout,
+			LastMessage:    now.Add(-2 * time.Second),
+		})
+	case <-time.After(time.Second):
+		t.Fatal("no keep-alive timeout")
+	}
+
+	// reported only once
+	c.checkKeepAlives(context.Background(), now, time.Time{})
+	select {
+	case msg := <-ch:
+		t.Fatalf("got second event %v", msg.Value)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestRecoverSubscription(t *testing.T) {
+	for _, status := range []ua.StatusCode{ua.StatusBadTimeout, ua.StatusBadSubscriptionIDInvalid} {
+		t.Run(status.Error(), func(t *testing.T) {
+			c := NewClient("opc.tcp://example.com:4840", ReconnectInterval(time.Millisecond))
+
+			var reqs []string
+			var mu sync.Mutex
+			setFakeChannel(c, func(req ua.Request, h func(interface{}) error) error {
+				mu.Lock()
+				defer mu.Unlock()
+				switch r := req.(type) {
+				case *ua.DeleteSubscriptionsRequest:
+					reqs = append(reqs, fmt.Sprintf("delete %v", r.SubscriptionIDs))
+					return h(&ua.DeleteSubscriptionsResponse{})
+				case *ua.CreateSubscriptionRequest:
+					reqs = append(reqs, "create")
+					// the first attempt fails
+					if len(reqs) == 2 {
+						return ua.StatusBadTooManySubscriptions
+					}
+					return h(&ua.CreateSubscriptionResponse{ResponseHeader: &ua.ResponseHeader{}, SubscriptionID: 7})
+				}
+				return errors.Errorf("unexpected request %T", req)
+			})
+
+			// the client starts with a paused publish loop
+			paused := len(c.pausech)
+
+			ch := make(chan *PublishNotificationData, 2)
+			sub := &Subscription{SubscriptionID: 1, Notifs: ch, nextSeq: 5, params: &SubscriptionParameters{}, c: c}
+			c.subs[1] = sub
+
+			c.subMux.Lock()
+			c.handleNotification(context.Background(), &ua.PublishResponse{
+				SubscriptionID: 1,
+				NotificationMessage: &ua.NotificationMessage{
+					SequenceNumber: 5,
+					NotificationData: []*ua.ExtensionObject{
+						ua.NewExtensionObject(&ua.StatusChangeNotification{Status: status}),
+					},
+				},
+			})
+			c.subMux.Unlock()
+
+			verify.Values(t, "status change", (<-ch).Value, &StatusChangeEvent{SubscriptionID: 1, Status: status})
+			select {
+			case n := <-ch:
+				verify.Values(t, "recreated", n.Value, &RecreatedEvent{PreviousSubscriptionID: 1, SubscriptionID: 7})
+			case <-time.After(time.Second):
+				t.Fatal("subscription not recreated")
+			}
+
+			mu.Lock()
+			verify.Values(t, "requests", reqs, []string{"delete [1]", "create", "delete [1]", "create"})
+			mu.Unlock()
+
+			c.subMux.RLock()
+			defer c.subMux.RUnlock()
+			if c.subs[7] != sub || len(c.subs) != 1 {
+				t.Fatalf("got subscriptions %v want 7", c.subs)
+			}
+			if got, want := sub.Status(), SubscriptionActive; got != want {
+				t.Fatalf("got status %v want %v", got, want)
+			}
+			if sub.nextSeq != 1 {
+				t.Fatalf("got next sequence number %d want 1", sub.nextSeq)
+			}
+			// the publish loop was not paused and must not be resumed
+			if len(c.pausech) != paused || len(c.resumech) != 0 {
+				t.Fatal("publish loop was paused or resumed")
+			}
+		})
+	}
+}
+
+func TestRecoverSubscriptionCancelled(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	var deleted []uint32
+	setFakeChannel(c, func(req ua.Request, h func(interface{}) error) error {
+		switch r := req.(type) {
+		case *ua.DeleteSubscriptionsRequest:
+			deleted = append(deleted, r.SubscriptionIDs...)
+			return h(&ua.DeleteSubscriptionsResponse{})
+		case *ua.CreateSubscriptionRequest:
+			// the subscription is cancelled meanwhile
+			c.subMux.Lock()
+			delete(c.subs, 1)
+			c.subMux.Unlock()
+			return h(&ua.CreateSubscriptionResponse{ResponseHeader: &ua.ResponseHeader{}, SubscriptionID: 7})
+		}
+		return errors.Errorf("unexpected request %T", req)
+	})
+	ch := make(chan *PublishNotificationData, 1)
+	sub := &Subscription{SubscriptionID: 1, Notifs: ch, params: &SubscriptionParameters{}, c: c}
+	c.subs[1] = sub
+
+	c.recoverSubscription(context.Background(), sub, 1)
+
+	verify.Values(t, "deleted", deleted, []uint32{1, 7})
+	if len(ch) != 0 || len(c.subs) != 0 {
+		t.Fatal("cancelled subscription was recreated")
+	}
+}
+
+func TestPublishDepth(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840", PublishRequests(3))
+	if got, want := c.publishDepth(), 1; got != want {
+		t.Fatalf("no subscriptions: got depth %d want %d", got, want)
+	}
+
+	for i := uint32(1); i <= 3; i++ {
+		c.subs[i] = &Subscription{SubscriptionID: i, c: c}
+	}
+	if got, want := c.publishDepth(), 3; got != want {
+		t.Fatalf("three subscriptions: got depth %d want %d", got, want)
+	}
+
+	if n, ok := c.lowerPublishLimit(); n != 2 || !ok {
+		t.Fatalf("got %d, %v want 2, true", n, ok)
+	}
+	if n, ok := c.lowerPublishLimit(); n != 1 || !ok {
+		t.Fatalf("got %d, %v want 1, true", n, ok)
+	}
+	if n, ok := c.lowerPublishLimit(); n != 1 || ok {
+		t.Fatalf("got %d, %v want 1, false", n, ok)
+	}
+	if got, want := c.publishDepth(), 1; got != want {
+		t.Fatalf("lowered: got depth %d want %d", got, want)
+	}
+}
+
+func TestAddSequenceNumber(t *testing.T) {
+	tests := []struct {
+		seq, n, want uint32
+	}{
+		{1, 0, 1},
+		{1, 5, 6},
+		{math.MaxUint32, 1, 1},
+		{math.MaxUint32 - 1, 3, 2},
+		{math.MaxUint32, math.MaxUint32 / 2, math.MaxUint32 / 2},
+	}
+	for _, tt := range tests {
+		if got := addSequenceNumber(tt.seq, tt.n); got != tt.want {
+			t.Errorf("addSequenceNumber(%d, %d): got %d want %d", tt.seq, tt.n, got, tt.want)
+		}
+	}
+}
+
+// republishClient returns a client whose Republish requests are answered
+// by f. The subMux lock must not be held while the requests are sent.
+func republishClient(f func(seq uint32) error) *Client {
+	var c *Client
+	c = newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		c.subMux.RLock()
+		c.subMux.RUnlock()
+
+		seq := req.(*ua.RepublishRequest).RetransmitSequenceNumber
+		if err := f(seq); err != nil {
+			return err
+		}
+		return h(&ua.RepublishResponse{
+			ResponseHeader: &ua.ResponseHeader{},
+			NotificationMessage: &ua.NotificationMessage{
+				SequenceNumber: seq,
+				NotificationData: []*ua.ExtensionObject{
+					ua.NewExtensionObject(&ua.DataChangeNotification{
+						MonitoredItems: []*ua.MonitoredItemNotification{{ClientHandle: seq}},
+					}),
+				},
+			},
+		})
+	})
+	return c
+}
+
+func TestRepublishGap(t *testing.T) {
+	c := republishClient(func(seq uint32) error {
+		switch seq {
+		case 6, 7, 9:
+			return ua.StatusBadMessageNotAvailable
+		}
+		return nil
+	})
+	ch := make(chan *PublishNotificationData, 10)
+	sub := &Subscription{SubscriptionID: 1, Notifs: ch, lastSeq: 4, nextSeq: 5, c: c}
+	c.subs[1] = sub
+
+	c.subMux.Lock()
+	err := c.republishGap(context.Background(), sub, 10)
+	c.subMux.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitDelivered(t, sub)
+
+	var got []interface{}
+	for len(ch) > 0 {
+		n := <-ch
+		if n.Error != nil {
+			got = append(got, n.Error)
+			continue
+		}
+		got = append(got, n.Value.(*ua.DataChangeNotification).MonitoredItems[0].ClientHandle)
+	}
+	verify.Values(t, "notifications", got, []interface{}{
+		uint32(5),
+		&DataLossError{SubscriptionID: 1, FirstSequenceNumber: 6, Count: 2, Err: ua.StatusBadMessageNotAvailable},
+		uint32(8),
+		&DataLossError{SubscriptionID: 1, FirstSequenceNumber: 9, Count: 1, Err: ua.StatusBadMessageNotAvailable},
+	})
+	verify.Values(t, "pendingAcks", c.pendingAcks, []*ua.SubscriptionAcknowledgement{
+		{SubscriptionID: 1, SequenceNumber: 5},
+		{SubscriptionID: 1, SequenceNumber: 8},
+	})
+	if sub.nextSeq != 10 || sub.lastSeq != 8 {
+		t.Fatalf("got next %d last %d want 10, 8", sub.nextSeq, sub.lastSeq)
+	}
+	if got, want := sub.PublishStats().Republished, uint64(2); got != want {
+		t.Fatalf("got %d republished want %d", got, want)
+	}
+}
+
+func TestRepublishGapTooLarge(t *testing.T) {
+	first := uint32(math.MaxUint32 - 10)
+	var seqs []uint32
+	c := republishClient(func(seq uint32) error {
+		seqs = append(seqs, seq)
+		return ua.StatusBadMessageNotAvailable
+	})
+	ch := make(chan *PublishNotificationData, 1)
+	sub := &Subscription{SubscriptionID: 1, Notifs: ch, nextSeq: first, c: c}
+	c.subs[1] = sub
+
+	seq := addSequenceNumber(first, maxRepublishGap+5)
+	c.subMux.Lock()
+	err := c.republishGap(context.Background(), sub, seq)
+	c.subMux.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitDelivered(t, sub)
+
+	if got, want := len(seqs), maxRepublishGap; got != want {
+		t.Fatalf("got %d republish requests want %d", got, want)
+	}
+	if got, want := seqs[0], addSequenceNumber(first, 5); got != want {
+		t.Fatalf("got first republished %d want %d", got, want)
+	}
+	verify.Values(t, "error", (<-ch).Error, &DataLossError{
+		SubscriptionID:      1,
+		FirstSequenceNumber: first,
+		Count:               maxRepublishGap + 5,
+		Err:                 ua.StatusBadMessageNotAvailable,
+	})
+	if sub.nextSeq != seq {
+		t.Fatalf("got next sequence number %d want %d", sub.nextSeq, seq)
+	}
+}
+
+func TestRepublishGapSubscriptionChanged(t *testing.T) {
+	var c *Client
+	c = republishClient(func(seq uint32) error {
+		// the subscription is recreated while the lock is released
+		c.subMux.Lock()
+		delete(c.subs, 1)
+		c.subMux.Unlock()
+		return nil
+	})
+	ch := make(chan *PublishNotificationData, 1)
+	sub := &Subscription{SubscriptionID: 1, Notifs: ch, nextSeq: 5, c: c}
+	c.subs[1] = sub
+
+	c.subMux.Lock()
+	err := c.republishGap(context.Background(), sub, 6)
+	c.subMux.Unlock()
+	if err != errSubscriptionChanged {
+		t.Fatalf("got %v want %v", err, errSubscriptionChanged)
+	}
+	if len(ch) != 0 || len(c.pendingAcks) != 0 {
+		t.Fatal("notification of changed subscription was delivered")
+	}
+}
+
+func TestPublishInOrder(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	ch := make(chan *PublishNotificationData, 2)
+	sub := &Subscription{SubscriptionID: 1, Notifs: ch, nextSeq: 1, c: c}
+	c.subs[1] = sub
+
+	sent := make(chan chan *ua.PublishResponse, 2)
+	setFakeChannel(c, func(req ua.Request, h func(interface{}) error) error {
+		if _, ok := req.(*ua.PublishRequest); !ok {
+			t.Errorf("got %T want *ua.PublishRequest", req)
+			return ua.StatusBadMessageNotAvailable
+		}
+		resc := make(chan *ua.PublishResponse)
+		sent <- resc
+		return h(<-resc)
+	})
+	response := func(seq uint32) *ua.PublishResponse {
+		return &ua.PublishResponse{
+			SubscriptionID: 1,
+			NotificationMessage: &ua.NotificationMessage{
+				SequenceNumber: seq,
+				NotificationData: []*ua.ExtensionObject{
+					ua.NewExtensionObject(&ua.DataChangeNotification{
+						MonitoredItems: []*ua.MonitoredItemNotification{{ClientHandle: seq}},
+					}),
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	start, first, second := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	close(start)
+	done := make(chan error, 2)
+	go func() { done <- c.publish(ctx, start, first) }()
+	r1 := <-sent
+	go func() { done <- c.publish(ctx, first, second) }()
+	r2 := <-sent
+
+	// the response of the second request is received first
+	r2 <- response(2)
+	time.Sleep(10 * time.Millisecond)
+	r1 <- response(1)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-second
+	waitDelivered(t, sub)
+
+	for _, want := range []uint32{1, 2} {
+		n := <-ch
+		if got := n.Value.(*ua.DataChangeNotification).MonitoredItems[0].ClientHandle; got != want {
+			t.Fatalf("got notif %d want %d", got, want)
+		}
+	}
+	verify.Values(t, "pendingAcks", c.pendingAcks, []*ua.SubscriptionAcknowledgement{
+		{SubscriptionID: 1, SequenceNumber: 1},
+		{SubscriptionID: 1, SequenceNumber: 2},
+	})
+}
+
+func TestPublishBlockedSubscriber(t *testing.T) {
+	responses := make(chan *ua.PublishResponse, 2)
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		return h(<-responses)
+	})
+	blocked := make(chan *PublishNotificationData)
+	ch := make(chan *PublishNotificationData, 1)
+	c.subs[1] = &Subscription{SubscriptionID: 1, Notifs: blocked, nextSeq: 1, c: c}
+	c.subs[2] = &Subscription{SubscriptionID: 2, Notifs: ch, nextSeq: 1, c: c}
+
+	response := func(subID uint32) *ua.PublishResponse {
+		return &ua.PublishResponse{
+			SubscriptionID: subID,
+			NotificationMessage: &ua.NotificationMessage{
+				SequenceNumber:   1,
+				NotificationData: []*ua.ExtensionObject{ua.NewExtensionObject(&ua.DataChangeNotification{})},
+			},
+		}
+	}
+	responses <- response(1)
+	responses <- response(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		prev, next := make(chan struct{}), make(chan struct{})
+		close(prev)
+		if err := c.publish(ctx, prev, next); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the other subscription gets its notification
+	select {
+	case n := <-ch:
+		verify.Values(t, "sub id", n.SubscriptionID, uint32(2))
+	case <-time.After(time.Second):
+		t.Fatal("notification blocked by the other subscription")
+	}
+	waitDelivered(t, c.subs[2])
+
+	// the lock of the subscriptions is not held
+	verify.Values(t, "ids", len(c.SubscriptionIDs()), 2)
+	c.checkKeepAlives(ctx, time.Now(), time.Time{})
+
+	// the blocked notification is acknowledged after it was delivered
+	c.pendingAcksMux.Lock()
+	verify.Values(t, "acks before", c.pendingAcks, []*ua.SubscriptionAcknowledgement{{SubscriptionID: 2, SequenceNumber: 1}})
+	c.pendingAcksMux.Unlock()
+
+	verify.Values(t, "sub id", (<-blocked).SubscriptionID, uint32(1))
+	waitDelivered(t, c.subs[1])
+	c.pendingAcksMux.Lock()
+	verify.Values(t, "acks after", c.pendingAcks, []*ua.SubscriptionAcknowledgement{
+		{SubscriptionID: 2, SequenceNumber: 1},
+		{SubscriptionID: 1, SequenceNumber: 1},
+	})
+	c.pendingAcksMux.Unlock()
+}
+
+// monitoredSubscription returns a subscription with monitored items for
+// the nodes i=1, i=2 and i=3 whose monitored item ids are 11, 12 and 13.
+// The requests after the items have been created are sent to send.
+func monitoredSubscription(t *testing.T, send fakeChannel) *Subscription {
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		r := req.(*ua.CreateMonitoredItemsRequest)
+		res := &ua.CreateMonitoredItemsResponse{ResponseHeader: &ua.ResponseHeader{}}
+		for _, item := range r.ItemsToCreate {
+			res.Results = append(res.Results, &ua.MonitoredItemCreateResult{MonitoredItemID: 10 + item.ItemToMonitor.NodeID.IntID()})
+		}
+		return h(res)
+	})
+	sub := &Subscription{SubscriptionID: 1, c: c}
+	var items []*ua.MonitoredItemCreateRequest
+	for i := uint32(1); i <= 3; i++ {
+		items = append(items, NewMonitoredItemCreateRequestWithDefaults(ua.NewNumericNodeID(0, i), ua.AttributeIDValue, i))
+	}
+	if _, err := sub.Monitor(ua.TimestampsToReturnBoth, items...); err != nil {
+		t.Fatal(err)
+	}
+	setFakeChannel(c, send)
+	return sub
+}
+
+func TestModifyMonitoredItems(t *testing.T) {
+	params := func(interval float64) *ua.MonitoringParameters {
+		return &ua.MonitoringParameters{SamplingInterval: interval}
+	}
+
+	t.Run("results", func(t *testing.T) {
+		filterResult := ua.NewExtensionObject(&ua.EventFilterResult{})
+		sub := monitoredSubscription(t, func(req ua.Request, h func(interface{}) error) error {
+			r := req.(*ua.ModifyMonitoredItemsRequest)
+			verify.Values(t, "timestamps", r.TimestampsToReturn, ua.TimestampsToReturnSource)
+			return h(&ua.ModifyMonitoredItemsResponse{
+				ResponseHeader: &ua.ResponseHeader{},
+				Results: []*ua.MonitoredItemModifyResult{
+					{StatusCode: ua.StatusOK},
+					{StatusCode: ua.StatusBadFilterNotAllowed, FilterResult: filterResult},
+					{StatusCode: ua.StatusBadMonitoredItemIDInvalid},
+				},
+			})
+		})
+
+		res, err := sub.ModifyMonitoredItems(ua.TimestampsToReturnSource,
+			&ua.MonitoredItemModifyRequest{MonitoredItemID: 11, RequestedParameters: params(100)},
+			&ua.MonitoredItemModifyRequest{MonitoredItemID: 12, RequestedParameters: params(200)},
+			&ua.MonitoredItemModifyRequest{MonitoredItemID: 13, RequestedParameters: params(300)},
+		)
+		if res == nil {
+			t.Fatal("got nil response")
+		}
+		verify.Values(t, "error", err, &FilterError{
+			NodeID:       ua.NewNumericNodeID(0, 2),
+			Status:       ua.StatusBadFilterNotAllowed,
+			FilterResult: filterResult,
+		})
+
+		// only the successful item is changed
+		verify.Values(t, "parameters", sub.items[0].MonitoringParameters, params(100))
+		verify.Values(t, "timestamps", sub.items[0].TimestampsToReturn, ua.TimestampsToReturnSource)
+		for _, mi := range sub.items[1:] {
+			verify.Values(t, "parameters", mi.MonitoringParameters.SamplingInterval, 0.0)
+			verify.Values(t, "timestamps", mi.TimestampsToReturn, ua.TimestampsToReturnBoth)
+		}
+	})
+
+	t.Run("service result", func(t *testing.T) {
+		sub := monitoredSubscription(t, func(req ua.Request, h func(interface{}) error) error {
+			return h(&ua.ModifyMonitoredItemsResponse{ResponseHeader: &ua.ResponseHeader{ServiceResult: ua.StatusBadTooManyOperations}})
+		})
+		_, err := sub.ModifyMonitoredItems(ua.TimestampsToReturnBoth, &ua.MonitoredItemModifyRequest{MonitoredItemID: 11, RequestedParameters: params(100)})
+		verify.Values(t, "error", err, ua.StatusBadTooManyOperations)
+	})
+
+	t.Run("result count", func(t *testing.T) {
+		sub := monitoredSubscription(t, func(req ua.Request, h func(interface{}) error) error {
+			return h(&ua.ModifyMonitoredItemsResponse{ResponseHeader: &ua.ResponseHeader{}})
+		})
+		_, err := sub.ModifyMonitoredItems(ua.TimestampsToReturnBoth, &ua.MonitoredItemModifyRequest{MonitoredItemID: 11, RequestedParameters: params(100)})
+		verify.Values(t, "error", err, ua.StatusBadUnexpectedError)
+		verify.Values(t, "parameters", sub.items[0].MonitoringParameters.SamplingInterval, 0.0)
+	})
+}
+
+func TestSetMonitoringMode(t *testing.T) {
+	t.Run("results", func(t *testing.T) {
+		sub := monitoredSubscription(t, func(req ua.Request, h func(interface{}) error) error {
+			r := req.(*ua.SetMonitoringModeRequest)
+			verify.Values(t, "mode", r.MonitoringMode, ua.MonitoringModeDisabled)
+			verify.Values(t, "ids", r.MonitoredItemIDs, []uint32{11, 13})
+			return h(&ua.SetMonitoringModeResponse{
+				ResponseHeader: &ua.ResponseHeader{},
+				Results:        []ua.StatusCode{ua.StatusBadMonitoredItemIDInvalid, ua.StatusOK},
+			})
+		})
+
+		res, err := sub.SetMonitoringMode(ua.MonitoringModeDisabled, 11, 13)
+		if res == nil {
+			t.Fatal("got nil response")
+		}
+		verify.Values(t, "error", err, ua.StatusBadMonitoredItemIDInvalid)
+
+		var modes []ua.MonitoringMode
+		for _, mi := range sub.items {
+			modes = append(modes, mi.MonitoringMode)
+		}
+		verify.Values(t, "modes", modes, []ua.MonitoringMode{ua.MonitoringModeReporting, ua.MonitoringModeReporting, ua.MonitoringModeDisabled})
+	})
+
+	t.Run("service result", func(t *testing.T) {
+		sub := monitoredSubscription(t, func(req ua.Request, h func(interface{}) error) error {
+			return h(&ua.SetMonitoringModeResponse{ResponseHeader: &ua.ResponseHeader{ServiceResult: ua.StatusBadSubscriptionIDInvalid}})
+		})
+		_, err := sub.SetMonitoringMode(ua.MonitoringModeDisabled, 11)
+		verify.Values(t, "error", err, ua.StatusBadSubscriptionIDInvalid)
+	})
+
+	t.Run("result count", func(t *testing.T) {
+		sub := monitoredSubscription(t, func(req ua.Request, h func(interface{}) error) error {
+			return h(&ua.SetMonitoringModeResponse{ResponseHeader: &ua.ResponseHeader{}, Results: []ua.StatusCode{ua.StatusOK}})
+		})
+		_, err := sub.SetMonitoringMode(ua.MonitoringModeDisabled, 11, 12)
+		verify.Values(t, "error", err, ua.StatusBadUnexpectedError)
+	})
+}
+
+func TestCreateMonitoredItemsTimestamps(t *testing.T) {
+	sub := monitoredSubscription(t, nil)
+	sub.items[1].TimestampsToReturn = ua.TimestampsToReturnSource
+
+	// the server assigns new ids when the items are recreated
+	setFakeChannel(sub.c, func(req ua.Request, h func(interface{}) error) error {
+		r := req.(*ua.CreateMonitoredItemsRequest)
+		res := &ua.CreateMonitoredItemsResponse{ResponseHeader: &ua.ResponseHeader{}}
+		for _, item := range r.ItemsToCreate {
+			res.Results = append(res.Results, &ua.MonitoredItemCreateResult{MonitoredItemID: 20 + item.ItemToMonitor.NodeID.IntID()})
+		}
+		return h(res)
+	})
+	if err := sub.createMonitoredItems(); err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint32
+	for _, mi := range sub.items {
+		ids = append(ids, mi.createResult.MonitoredItemID)
+	}
+	verify.Values(t, "ids", ids, []uint32{21, 22, 23})
+}
+
+func TestModifySubscription(t *testing.T) {
+	c := NewClient("opc.tcp://example.com:4840")
+	// the sample rate of the server does not limit the publishing interval
+	c.caps.Store(&ServerCapabilities{MinSupportedSampleRate: time.Second})
+
+	var reqs []*ua.ModifySubscriptionRequest
+	setFakeChannel(c, func(req ua.Request, h func(interface{}) error) error {
+		r := req.(*ua.ModifySubscriptionRequest)
+		reqs = append(reqs, r)
+		if r.RequestedLifetimeCount == 1 {
+			return h(&ua.ModifySubscriptionResponse{ResponseHeader: &ua.ResponseHeader{ServiceResult: ua.StatusBadInvalidArgument}})
+		}
+		return h(&ua.ModifySubscriptionResponse{
+			ResponseHeader:            &ua.ResponseHeader{},
+			RevisedPublishingInterval: 250,
+			RevisedLifetimeCount:      r.RequestedLifetimeCount,
+			RevisedMaxKeepAliveCount:  r.RequestedMaxKeepAliveCount,
+		})
+	})
+	sub := &Subscription{SubscriptionID: 1, params: &SubscriptionParameters{Interval: time.Second}, c: c}
+	c.subs[1] = sub
+
+	params := SubscriptionParameters{Interval: 100 * time.Millisecond, LifetimeCount: 30, MaxKeepAliveCount: 10, MaxNotificationsPerPublish: 100}
+	if _, err := sub.ModifySubscription(params); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "request", reqs[0], &ua.ModifySubscriptionRequest{
+		SubscriptionID:              1,
+		RequestedPublishingInterval: 100,
+		RequestedLifetimeCount:      30,
+		RequestedMaxKeepAliveCount:  10,
+		MaxNotificationsPerPublish:  100,
+	})
+	verify.Values(t, "interval", sub.RevisedPublishingInterval, 250*time.Millisecond)
+	verify.Values(t, "lifetime", sub.RevisedLifetimeCount, uint32(30))
+	verify.Values(t, "keep-alive", sub.RevisedMaxKeepAliveCount, uint32(10))
+	verify.Values(t, "params", sub.params.Interval, 100*time.Millisecond)
+	verify.Values(t, "publish timeout", c.publishTimeout.Load(), sub.publishTimeout())
+
+	// a rejected change keeps the parameters
+	params = SubscriptionParameters{Interval: 500 * time.Millisecond, LifetimeCount: 1, MaxKeepAliveCount: 10}
+	if _, err := sub.ModifySubscription(params); err != ua.StatusBadInvalidArgument {
+		t.Fatalf("got %v want %v", err, ua.StatusBadInvalidArgument)
+	}
+	verify.Values(t, "params", sub.params.Interval, 100*time.Millisecond)
+	verify.Values(t, "interval", sub.RevisedPublishingInterval, 250*time.Millisecond)
+}
+
+func TestSetPublishingMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		res      *ua.SetPublishingModeResponse
+		err      error
+		disabled bool
+	}{
+		{
+			name:     "ok",
+			res:      &ua.SetPublishingModeResponse{ResponseHeader: &ua.ResponseHeader{}, Results: []ua.StatusCode{ua.StatusOK}},
+			disabled: true,
+		},
+		{
+			name: "service result",
+			res:  &ua.SetPublishingModeResponse{ResponseHeader: &ua.ResponseHeader{ServiceResult: ua.StatusBadTooManyOperations}},
+			err:  ua.StatusBadTooManyOperations,
+		},
+		{
+			name: "result",
+			res:  &ua.SetPublishingModeResponse{ResponseHeader: &ua.ResponseHeader{}, Results: []ua.StatusCode{ua.StatusBadSubscriptionIDInvalid}},
+			err:  ua.StatusBadSubscriptionIDInvalid,
+		},
+		{
+			name: "result count",
+			res:  &ua.SetPublishingModeResponse{ResponseHeader: &ua.ResponseHeader{}},
+			err:  ua.StatusBadUnexpectedError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+				verify.Values(t, "request", req, &ua.SetPublishingModeRequest{SubscriptionIDs: []uint32{1}})
+				return h(tt.res)
+			})
+			sub := &Subscription{SubscriptionID: 1, params: &SubscriptionParameters{}, c: c}
+			verify.Values(t, "error", sub.SetPublishingMode(false), tt.err)
+			verify.Values(t, "disabled", sub.publishingDisabled, tt.disabled)
+		})
+	}
+}
+
+func TestSetPublishingModeRecreate(t *testing.T) {
+	c := newFakeClient(func(req ua.Request, h func(interface{}) error) error {
+		switch r := req.(type) {
+		case *ua.SetPublishingModeRequest:
+			return h(&ua.SetPublishingModeResponse{ResponseHeader: &ua.ResponseHeader{}, Results: []ua.StatusCode{ua.StatusOK}})
+		case *ua.CreateSubscriptionRequest:
+			// the mode is kept when the subscription is recreated
+			verify.Values(t, "publishing enabled", r.PublishingEnabled, false)
+			return h(&ua.CreateSubscriptionResponse{ResponseHeader: &ua.ResponseHeader{}, SubscriptionID: 2})
+		}
+		return errors.Errorf("unexpected request %T", req)
+	})
+	sub := &Subscription{SubscriptionID: 1, params: &SubscriptionParameters{}, c: c}
+	if err := sub.SetPublishingMode(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.createSubscription(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/id"
+)
+
+// EventFilterBuilder builds an EventFilter from browse paths
+// and filter expressions.
+//
+//	f, err := ua.NewEventFilterBuilder().
+//		Select("EventId", "EventType", "Severity", "Time", "Message").
+//		Where(ua.And(
+//			ua.OfType(ua.NewNumericNodeID(0, id.AlarmConditionType)),
+//			ua.GreaterThanOrEqual(ua.EventField("Severity"), uint16(500)),
+//		)).
+//		Build()
+//
+// Specification: Part 4, 7.17.3
+type EventFilterBuilder struct {
+	selects []*SimpleAttributeOperand
+	where   *FilterExpr
+}
+
+// NewEventFilterBuilder returns an empty EventFilterBuilder.
+func NewEventFilterBuilder() *EventFilterBuilder {
+	return &EventFilterBuilder{}
+}
+
+// Select adds select clauses for the fields of the BaseEventType.
+// See EventField for the format of the browse paths.
+func (b *EventFilterBuilder) Select(paths ...string) *EventFilterBuilder {
+	return b.SelectOf(NewNumericNodeID(0, id.BaseEventType), paths...)
+}
+
+// SelectOf adds select clauses for the fields of the event type.
+func (b *EventFilterBuilder) SelectOf(typeID *NodeID, paths ...string) *EventFilterBuilder {
+	for _, p := range paths {
+		b.selects = append(b.selects, EventFieldOf(typeID, p))
+	}
+	return b
+}
+
+// SelectOperand adds a select clause, e.g. for the NodeId
+// of a condition which has an empty browse path.
+func (b *EventFilterBuilder) SelectOperand(op *SimpleAttributeOperand) *EventFilterBuilder {
+	b.selects = append(b.selects, op)
+	return b
+}
+
+// Where sets the where clause.
+func (b *EventFilterBuilder) Where(e *FilterExpr) *EventFilterBuilder {
+	b.where = e
+	return b
+}
+
+// Build returns the EventFilter.
+func (b *EventFilterBuilder) Build() (*EventFilter, error) {
+	if len(b.selects) == 0 {
+		return nil, errors.New("event filter without select clauses")
+	}
+	f := &EventFilter{
+		SelectClauses: b.selects,
+		WhereClause:   &ContentFilter{},
+	}
+	if b.where != nil {
+		cf, err := b.where.ContentFilter()
+		if err != nil {
+			return nil, err
+		}
+		f.WhereClause = cf
+	}
+	return f, nil
+}
+
+// EventField returns an operand for the field of the BaseEventType with the
+// given browse path. The path consists of browse names separated by a slash.
+// A browse name can be prefixed with a namespace index and a colon, e.g.
+// "EnabledState/Id" or "2:MyField".
+func EventField(path string) *SimpleAttributeOperand {
+	return EventFieldOf(NewNumericNodeID(0, id.BaseEventType), path)
+}
+
+// EventFieldOf returns an operand for the field of the event type.
+func EventFieldOf(typeID *NodeID, path string) *SimpleAttributeOperand {
+	return &SimpleAttributeOperand{
+		TypeDefinitionID: typeID,
+		BrowsePath:       parseBrowsePath(path),
+		AttributeID:      AttributeIDValue,
+	}
+}
+
+// EventFieldName returns the browse path of the operand in the
+// format accepted by EventField.
+func EventFieldName(op *SimpleAttributeOperand) string {
+	names := make([]string, len(op.BrowsePath))
+	for i, qn := range op.BrowsePath {
+		if qn.NamespaceIndex != 0 {
+			names[i] = strconv.Itoa(int(qn.NamespaceIndex)) + ":" + qn.Name
+		} else {
+			names[i] = qn.Name
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+func parseBrowsePath(path string) []*QualifiedName {
+	if path == "" {
+		return nil
+	}
+	var qns []*QualifiedName
+	for _, s := range strings.Split(path, "/") {
+		qn := &QualifiedName{Name: s}
+		if i := strings.Index(s, ":"); i > 0 {
+			if ns, err := strconv.ParseUint(s[:i], 10, 16); err == nil {
+				qn.NamespaceIndex = uint16(ns)
+				qn.Name = s[i+1:]
+			}
+		}
+		qns = append(qns, qn)
+	}
+	return qns
+}
+
+// FilterExpr is an element of a ContentFilter. The operands of an
+// expression are other expressions, operands like the ones returned by
+// EventField or literal values. Values which are not operands are
+// converted to a LiteralOperand with NewVariant.
+//
+// Specification: Part 4, 7.4
+type FilterExpr struct {
+	Op   FilterOperator
+	Args []interface{}
+}
+
+// ContentFilter returns the content filter with the
+// expression as the first element.
+func (e *FilterExpr) ContentFilter() (*ContentFilter, error) {
+	cf := &ContentFilter{}
+	if _, err := e.appendTo(cf); err != nil {
+		return nil, err
+	}
+	return cf, nil
+}
+
+// appendTo appends the expression and its sub expressions to the
+// filter and returns the index of the expression.
+func (e *FilterExpr) appendTo(cf *ContentFilter) (uint32, error) {
+	idx := uint32(len(cf.Elements))
+	el := &ContentFilterElement{FilterOperator: e.Op}
+	cf.Elements = append(cf.Elements, el)
+
+	for _, arg := range e.Args {
+		var op interface{}
+		switch x := arg.(type) {
+		case *FilterExpr:
+			i, err := x.appendTo(cf)
+			if err != nil {
+				return 0, err
+			}
+			op = &ElementOperand{Index: i}
+		case *ElementOperand, *LiteralOperand, *AttributeOperand, *SimpleAttributeOperand:
+			op = x
+		case *Variant:
+			op = &LiteralOperand{Value: x}
+		default:
+			v, err := NewVariant(x)
+			if err != nil {
+				return 0, errors.Errorf("invalid operand for %s: %s", e.Op, err)
+			}
+			op = &LiteralOperand{Value: v}
+		}
+		el.FilterOperands = append(el.FilterOperands, NewExtensionObject(op))
+	}
+	return idx, nil
+}
+
+// Equals returns a == b.
+func Equals(a, b interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorEquals, Args: []interface{}{a, b}}
+}
+
+// GreaterThan returns a > b.
+func GreaterThan(a, b interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorGreaterThan, Args: []interface{}{a, b}}
+}
+
+// LessThan returns a < b.
+func LessThan(a, b interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorLessThan, Args: []interface{}{a, b}}
+}
+
+// GreaterThanOrEqual returns a >= b.
+func GreaterThanOrEqual(a, b interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorGreaterThanOrEqual, Args: []interface{}{a, b}}
+}
+
+// LessThanOrEqual returns a <= b.
+func LessThanOrEqual(a, b interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorLessThanOrEqual, Args: []interface{}{a, b}}
+}
+
+// Like returns true if a matches the pattern. The pattern
+// uses the wildcards described in Part 4, 7.4.3 Table 117.
+func Like(a interface{}, pattern string) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorLike, Args: []interface{}{a, pattern}}
+}
+
+// IsNull returns true if a is null.
+func IsNull(a interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorIsNull, Args: []interface{}{a}}
+}
+
+// Not returns the negation of a.
+func Not(a interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorNot, Args: []interface{}{a}}
+}
+
+// Between returns lo <= a <= hi.
+func Between(a, lo, hi interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorBetween, Args: []interface{}{a, lo, hi}}
+}
+
+// InList returns true if a is equal to one of the values.
+func InList(a interface{}, values ...interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorInList, Args: append([]interface{}{a}, values...)}
+}
+
+// OfType returns true if the event is of the type or one of its subtypes.
+func OfType(typeID *NodeID) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorOfType, Args: []interface{}{typeID}}
+}
+
+// BitwiseAnd returns a & b.
+func BitwiseAnd(a, b interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorBitwiseAnd, Args: []interface{}{a, b}}
+}
+
+// BitwiseOr returns a | b.
+func BitwiseOr(a, b interface{}) *FilterExpr {
+	return &FilterExpr{Op: FilterOperatorBitwiseOr, Args: []interface{}{a, b}}
+}
+
+// And returns the conjunction of the expressions. More than two
+// expressions are nested since the And operator has two operands.
+func And(a, b interface{}, more ...interface{}) *FilterExpr {
+	return nest(FilterOperatorAnd, append([]interface{}{a, b}, more...))
+}
+
+// Or returns the disjunction of the expressions. More than two
+// expressions are nested since the Or operator has two operands.
+func Or(a, b interface{}, more ...interface{}) *FilterExpr {
+	return nest(FilterOperatorOr, append([]interface{}{a, b}, more...))
+}
+
+func nest(op FilterOperator, args []interface{}) *FilterExpr {
+	if len(args) == 2 {
+		return &FilterExpr{Op: op, Args: args}
+	}
+	return &FilterExpr{Op: op, Args: []interface{}{args[0], nest(op, args[1:])}}
+}
@@ -0,0 +1,118 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"testing"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestEventFilterBuilder(t *testing.T) {
+	baseEventType := NewNumericNodeID(0, id.BaseEventType)
+	alarmType := NewNumericNodeID(0, id.AlarmConditionType)
+
+	f, err := NewEventFilterBuilder().
+		Select("Severity", "EnabledState/Id", "2:Custom").
+		Where(And(
+			OfType(alarmType),
+			GreaterThanOrEqual(EventField("Severity"), uint16(500)),
+			InList(EventField("2:Custom"), "a", "b"),
+		)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &EventFilter{
+		SelectClauses: []*SimpleAttributeOperand{
+			{
+				TypeDefinitionID: baseEventType,
+				BrowsePath:       []*QualifiedName{{Name: "Severity"}},
+				AttributeID:      AttributeIDValue,
+			},
+			{
+				TypeDefinitionID: baseEventType,
+				BrowsePath:       []*QualifiedName{{Name: "EnabledState"}, {Name: "Id"}},
+				AttributeID:      AttributeIDValue,
+			},
+			{
+				TypeDefinitionID: baseEventType,
+				BrowsePath:       []*QualifiedName{{NamespaceIndex: 2, Name: "Custom"}},
+				AttributeID:      AttributeIDValue,
+			},
+		},
+		WhereClause: &ContentFilter{
+			Elements: []*ContentFilterElement{
+				{
+					FilterOperator: FilterOperatorAnd,
+					FilterOperands: []*ExtensionObject{
+						NewExtensionObject(&ElementOperand{Index: 1}),
+						NewExtensionObject(&ElementOperand{Index: 2}),
+					},
+				},
+				{
+					FilterOperator: FilterOperatorOfType,
+					FilterOperands: []*ExtensionObject{
+						NewExtensionObject(&LiteralOperand{Value: MustVariant(alarmType)}),
+					},
+				},
+				{
+					FilterOperator: FilterOperatorAnd,
+					FilterOperands: []*ExtensionObject{
+						NewExtensionObject(&ElementOperand{Index: 3}),
+						NewExtensionObject(&ElementOperand{Index: 4}),
+					},
+				},
+				{
+					FilterOperator: FilterOperatorGreaterThanOrEqual,
+					FilterOperands: []*ExtensionObject{
+						NewExtensionObject(EventField("Severity")),
+						NewExtensionObject(&LiteralOperand{Value: MustVariant(uint16(500))}),
+					},
+				},
+				{
+					FilterOperator: FilterOperatorInList,
+					FilterOperands: []*ExtensionObject{
+						NewExtensionObject(EventField("2:Custom")),
+						NewExtensionObject(&LiteralOperand{Value: MustVariant("a")}),
+						NewExtensionObject(&LiteralOperand{Value: MustVariant("b")}),
+					},
+				},
+			},
+		},
+	}
+	verify.Values(t, "", f, want)
+
+	// the filter must survive an encode/decode round trip
+	b, err := Encode(NewExtensionObject(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eo := new(ExtensionObject)
+	if _, err := Decode(b, eo); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", eo.Value, want)
+}
+
+func TestEventFilterBuilderErrors(t *testing.T) {
+	if _, err := NewEventFilterBuilder().Build(); err == nil {
+		t.Fatal("got nil want error for filter without select clauses")
+	}
+	_, err := NewEventFilterBuilder().Select("Severity").Where(Equals(EventField("Severity"), struct{}{})).Build()
+	if err == nil {
+		t.Fatal("got nil want error for invalid literal")
+	}
+}
+
+func TestEventFieldName(t *testing.T) {
+	for _, path := range []string{"Severity", "EnabledState/Id", "2:Custom/3:Sub", "a:b"} {
+		if got, want := EventFieldName(EventField(path)), path; got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	}
+}
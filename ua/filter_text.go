@@ -0,0 +1,797 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/id"
+)
+
+// ParseContentFilter parses a filter expression and returns the content
+// filter for it. See ParseFilterExpr for the syntax.
+func ParseContentFilter(s string) (*ContentFilter, error) {
+	e, err := ParseFilterExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	return e.ContentFilter()
+}
+
+// ParseFilterExpr parses a filter expression like
+//
+//	OfType(ns=0;i=2915) AND Severity >= 500 AND SourceName LIKE 'Pump%'
+//
+// Operators in order of precedence, lowest first:
+//
+//	a OR b
+//	a AND b
+//	NOT a
+//	a = b, a != b, a <> b, a > b, a < b, a >= b, a <= b,
+//	a LIKE b, a BETWEEN b AND c, a IN (b, c, ...), a IS NULL
+//	a & b, a | b (bitwise)
+//
+// Keywords are case insensitive. Operands are
+//
+//	event fields     Severity, EnabledState/Id, 2:Custom, [ns=2;i=1000]Custom
+//	strings          'Pump%' with '' for a single quote
+//	numbers          500, -1, 0.5 as Int32, Int64 or Double
+//	booleans         TRUE, FALSE
+//	node ids         NodeId(ns=2;s=Pump1)
+//	event types      OfType(ns=0;i=2915)
+//
+// Event fields are browse paths as accepted by EventField. They refer to
+// the BaseEventType unless the type is given in brackets.
+func ParseFilterExpr(s string) (*FilterExpr, error) {
+	p := &filterParser{s: s}
+	p.next()
+	x, err := p.parseOr()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	e, ok := x.(*FilterExpr)
+	if !ok {
+		return nil, errors.Errorf("filter %q is not an expression", s)
+	}
+	return e, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokField
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type filterParser struct {
+	s   string
+	pos int
+	tok token
+	err error
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("filter: position %d: "+format, append([]interface{}{p.tok.pos}, args...)...)
+}
+
+// next reads the next token.
+func (p *filterParser) next() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.s) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.s[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		p.tok = token{kind: tokLParen, text: "(", pos: start}
+	case c == ')':
+		p.pos++
+		p.tok = token{kind: tokRParen, text: ")", pos: start}
+	case c == ',':
+		p.pos++
+		p.tok = token{kind: tokComma, text: ",", pos: start}
+	case c == '\'':
+		p.pos++
+		var sb strings.Builder
+		for {
+			if p.pos >= len(p.s) {
+				p.tok = token{kind: tokEOF, pos: start}
+				p.err = errors.Errorf("filter: position %d: unterminated string", start)
+				return
+			}
+			if p.s[p.pos] == '\'' {
+				if p.pos+1 < len(p.s) && p.s[p.pos+1] == '\'' {
+					sb.WriteByte('\'')
+					p.pos += 2
+					continue
+				}
+				p.pos++
+				break
+			}
+			sb.WriteByte(p.s[p.pos])
+			p.pos++
+		}
+		p.tok = token{kind: tokString, text: sb.String(), pos: start}
+	case strings.ContainsRune("=!<>&|", rune(c)):
+		p.pos++
+		if p.pos < len(p.s) && (p.s[p.pos] == '=' || (c == '<' && p.s[p.pos] == '>')) {
+			p.pos++
+		}
+		p.tok = token{kind: tokOp, text: p.s[start:p.pos], pos: start}
+	case c == '-' || c >= '0' && c <= '9':
+		p.pos++
+		for p.pos < len(p.s) && strings.ContainsRune("0123456789.eE+-", rune(p.s[p.pos])) {
+			if (p.s[p.pos] == '+' || p.s[p.pos] == '-') && !strings.ContainsRune("eE", rune(p.s[p.pos-1])) {
+				break
+			}
+			p.pos++
+		}
+		// a namespace index like 2:Name starts a field
+		if p.pos < len(p.s) && p.s[p.pos] == ':' && c != '-' {
+			p.pos = start
+			p.readField(start)
+			return
+		}
+		p.tok = token{kind: tokNumber, text: p.s[start:p.pos], pos: start}
+	case c == '[' || isFieldChar(c):
+		p.readField(start)
+	default:
+		p.pos++
+		p.tok = token{kind: tokOp, text: p.s[start:p.pos], pos: start}
+	}
+}
+
+func isFieldChar(c byte) bool {
+	return c == '_' || c == '.' || c == ':' || c == '/' || c >= '0' && c <= '9' || unicode.IsLetter(rune(c)) || c >= 0x80
+}
+
+// readField reads an identifier or a field with an optional
+// type definition in brackets.
+func (p *filterParser) readField(start int) {
+	kind := tokIdent
+	if p.s[p.pos] == '[' {
+		end := strings.IndexByte(p.s[p.pos:], ']')
+		if end < 0 {
+			p.tok = token{kind: tokEOF, pos: start}
+			p.err = errors.Errorf("filter: position %d: missing ]", start)
+			return
+		}
+		p.pos += end + 1
+		kind = tokField
+	}
+	for p.pos < len(p.s) && isFieldChar(p.s[p.pos]) {
+		if p.s[p.pos] == ':' || p.s[p.pos] == '/' {
+			kind = tokField
+		}
+		p.pos++
+	}
+	p.tok = token{kind: kind, text: p.s[start:p.pos], pos: start}
+}
+
+// readRaw reads the text up to the closing parenthesis.
+// It is used for node ids.
+func (p *filterParser) readRaw() string {
+	start := p.pos
+	end := strings.IndexByte(p.s[p.pos:], ')')
+	if end < 0 {
+		p.pos = len(p.s)
+		return strings.TrimSpace(p.s[start:])
+	}
+	p.pos += end
+	return strings.TrimSpace(p.s[start:p.pos])
+}
+
+func (p *filterParser) keyword(kw string) bool {
+	return p.tok.kind == tokIdent && strings.EqualFold(p.tok.text, kw)
+}
+
+func (p *filterParser) expect(kind tokKind, text string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.tok.kind != kind {
+		return p.errorf("got %q want %q", p.tok.text, text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *filterParser) parseOr() (interface{}, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	args := []interface{}{x}
+	for p.keyword("OR") {
+		p.next()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, y)
+	}
+	if len(args) == 1 {
+		return x, nil
+	}
+	return Or(args[0], args[1], args[2:]...), nil
+}
+
+func (p *filterParser) parseAnd() (interface{}, error) {
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	args := []interface{}{x}
+	for p.keyword("AND") {
+		p.next()
+		y, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, y)
+	}
+	if len(args) == 1 {
+		return x, nil
+	}
+	return And(args[0], args[1], args[2:]...), nil
+}
+
+func (p *filterParser) parseNot() (interface{}, error) {
+	if p.keyword("NOT") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not(x), nil
+	}
+	return p.parseCompare()
+}
+
+func (p *filterParser) parseCompare() (interface{}, error) {
+	x, err := p.parseBitwise()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.tok.kind == tokOp:
+		op := p.tok.text
+		var f func(a, b interface{}) *FilterExpr
+		switch op {
+		case "=", "==":
+			f = Equals
+		case "!=", "<>":
+			f = func(a, b interface{}) *FilterExpr { return Not(Equals(a, b)) }
+		case ">":
+			f = GreaterThan
+		case "<":
+			f = LessThan
+		case ">=":
+			f = GreaterThanOrEqual
+		case "<=":
+			f = LessThanOrEqual
+		default:
+			return nil, p.errorf("invalid operator %q", op)
+		}
+		p.next()
+		y, err := p.parseBitwise()
+		if err != nil {
+			return nil, err
+		}
+		return f(x, y), nil
+
+	case p.keyword("LIKE"):
+		p.next()
+		y, err := p.parseBitwise()
+		if err != nil {
+			return nil, err
+		}
+		return &FilterExpr{Op: FilterOperatorLike, Args: []interface{}{x, y}}, nil
+
+	case p.keyword("BETWEEN"):
+		p.next()
+		lo, err := p.parseBitwise()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, p.errorf("got %q want AND", p.tok.text)
+		}
+		p.next()
+		hi, err := p.parseBitwise()
+		if err != nil {
+			return nil, err
+		}
+		return Between(x, lo, hi), nil
+
+	case p.keyword("IN"):
+		p.next()
+		if err := p.expect(tokLParen, "("); err != nil {
+			return nil, err
+		}
+		var values []interface{}
+		for {
+			v, err := p.parseBitwise()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if p.tok.kind != tokComma {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return InList(x, values...), nil
+
+	case p.keyword("IS"):
+		p.next()
+		not := p.keyword("NOT")
+		if not {
+			p.next()
+		}
+		if !p.keyword("NULL") {
+			return nil, p.errorf("got %q want NULL", p.tok.text)
+		}
+		p.next()
+		if not {
+			return Not(IsNull(x)), nil
+		}
+		return IsNull(x), nil
+	}
+	return x, nil
+}
+
+func (p *filterParser) parseBitwise() (interface{}, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "&" || p.tok.text == "|") {
+		op := p.tok.text
+		p.next()
+		y, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "&" {
+			x = BitwiseAnd(x, y)
+		} else {
+			x = BitwiseOr(x, y)
+		}
+	}
+	return x, nil
+}
+
+func (p *filterParser) parsePrimary() (interface{}, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokLParen:
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+
+	case tokString:
+		p.next()
+		return tok.text, nil
+
+	case tokNumber:
+		p.next()
+		if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			if i >= math.MinInt32 && i <= math.MaxInt32 {
+				return int32(i), nil
+			}
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", tok.text)
+		}
+		return f, nil
+
+	case tokField:
+		p.next()
+		return parseFilterField(tok.text)
+
+	case tokIdent:
+		switch {
+		case p.keyword("TRUE"):
+			p.next()
+			return true, nil
+		case p.keyword("FALSE"):
+			p.next()
+			return false, nil
+		case p.keyword("OfType"), p.keyword("NodeId"):
+			ofType := p.keyword("OfType")
+			p.next()
+			if p.tok.kind != tokLParen {
+				return nil, p.errorf("got %q want (", p.tok.text)
+			}
+			raw := p.readRaw()
+			p.next()
+			if err := p.expect(tokRParen, ")"); err != nil {
+				return nil, err
+			}
+			nid, err := ParseNodeID(raw)
+			if err != nil {
+				return nil, errors.Errorf("filter: position %d: %s", tok.pos, err)
+			}
+			if ofType {
+				return OfType(nid), nil
+			}
+			return nid, nil
+		}
+		for _, kw := range []string{"AND", "OR", "NOT", "LIKE", "BETWEEN", "IN", "IS", "NULL"} {
+			if p.keyword(kw) {
+				return nil, p.errorf("unexpected %s", strings.ToUpper(tok.text))
+			}
+		}
+		p.next()
+		return EventField(tok.text), nil
+
+	case tokEOF:
+		return nil, p.errorf("unexpected end of filter")
+	default:
+		return nil, p.errorf("unexpected %q", tok.text)
+	}
+}
+
+// parseFilterField parses an event field with an optional type definition.
+func parseFilterField(s string) (*SimpleAttributeOperand, error) {
+	if !strings.HasPrefix(s, "[") {
+		return EventField(s), nil
+	}
+	end := strings.IndexByte(s, ']')
+	typeID, err := ParseNodeID(s[1:end])
+	if err != nil {
+		return nil, errors.Errorf("filter: invalid type of field %s: %s", s, err)
+	}
+	return EventFieldOf(typeID, s[end+1:]), nil
+}
+
+// FormatContentFilter returns the content filter as text in the syntax
+// of ParseFilterExpr. The type of numbers is not preserved. It returns
+// an error for operands which cannot be expressed in the syntax, e.g.
+// AttributeOperands or fields of attributes other than the value.
+func FormatContentFilter(cf *ContentFilter) (string, error) {
+	if cf == nil || len(cf.Elements) == 0 {
+		return "", nil
+	}
+	f := &filterFormatter{cf: cf}
+	var sb strings.Builder
+	if err := f.element(&sb, 0, 0); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// precedence of the operators, higher binds tighter.
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precCompare
+	precBitwise
+	precPrimary
+)
+
+var compareOps = map[FilterOperator]string{
+	FilterOperatorEquals:             "=",
+	FilterOperatorGreaterThan:        ">",
+	FilterOperatorLessThan:           "<",
+	FilterOperatorGreaterThanOrEqual: ">=",
+	FilterOperatorLessThanOrEqual:    "<=",
+	FilterOperatorLike:               "LIKE",
+}
+
+type filterFormatter struct {
+	cf *ContentFilter
+}
+
+func operatorPrec(op FilterOperator) int {
+	switch op {
+	case FilterOperatorOr:
+		return precOr
+	case FilterOperatorAnd:
+		return precAnd
+	case FilterOperatorNot:
+		return precNot
+	case FilterOperatorBitwiseAnd, FilterOperatorBitwiseOr:
+		return precBitwise
+	case FilterOperatorOfType:
+		return precPrimary
+	default:
+		return precCompare
+	}
+}
+
+// element writes the element at index i and wraps it in
+// parentheses if it binds less tightly than minPrec.
+func (f *filterFormatter) element(sb *strings.Builder, i uint32, minPrec int) error {
+	if int(i) >= len(f.cf.Elements) {
+		return errors.Errorf("filter: invalid element index %d", i)
+	}
+	el := f.cf.Elements[i]
+	ops := el.FilterOperands
+	nargs := func(n int) error {
+		if len(ops) != n {
+			return errors.Errorf("filter: %s has %d operands want %d", el.FilterOperator, len(ops), n)
+		}
+		return nil
+	}
+	operand := func(j, prec int) error {
+		return f.operand(sb, i, ops[j], prec)
+	}
+
+	prec := operatorPrec(el.FilterOperator)
+	if prec < minPrec {
+		sb.WriteString("(")
+		defer sb.WriteString(")")
+	}
+
+	switch op := el.FilterOperator; op {
+	case FilterOperatorOr, FilterOperatorAnd:
+		if err := nargs(2); err != nil {
+			return err
+		}
+		if err := operand(0, prec+1); err != nil {
+			return err
+		}
+		if op == FilterOperatorOr {
+			sb.WriteString(" OR ")
+		} else {
+			sb.WriteString(" AND ")
+		}
+		return operand(1, prec)
+
+	case FilterOperatorNot:
+		if err := nargs(1); err != nil {
+			return err
+		}
+		sb.WriteString("NOT ")
+		return operand(0, prec)
+
+	case FilterOperatorEquals, FilterOperatorGreaterThan, FilterOperatorLessThan,
+		FilterOperatorGreaterThanOrEqual, FilterOperatorLessThanOrEqual, FilterOperatorLike:
+		if err := nargs(2); err != nil {
+			return err
+		}
+		if err := operand(0, precBitwise); err != nil {
+			return err
+		}
+		sb.WriteString(" " + compareOps[op] + " ")
+		return operand(1, precBitwise)
+
+	case FilterOperatorBitwiseAnd, FilterOperatorBitwiseOr:
+		if err := nargs(2); err != nil {
+			return err
+		}
+		if err := operand(0, precBitwise); err != nil {
+			return err
+		}
+		if op == FilterOperatorBitwiseAnd {
+			sb.WriteString(" & ")
+		} else {
+			sb.WriteString(" | ")
+		}
+		return operand(1, precPrimary)
+
+	case FilterOperatorIsNull:
+		if err := nargs(1); err != nil {
+			return err
+		}
+		if err := operand(0, precBitwise); err != nil {
+			return err
+		}
+		sb.WriteString(" IS NULL")
+		return nil
+
+	case FilterOperatorBetween:
+		if err := nargs(3); err != nil {
+			return err
+		}
+		if err := operand(0, precBitwise); err != nil {
+			return err
+		}
+		sb.WriteString(" BETWEEN ")
+		if err := operand(1, precBitwise); err != nil {
+			return err
+		}
+		sb.WriteString(" AND ")
+		return operand(2, precBitwise)
+
+	case FilterOperatorInList:
+		if len(ops) < 2 {
+			return errors.Errorf("filter: %s has %d operands want at least 2", op, len(ops))
+		}
+		if err := operand(0, precBitwise); err != nil {
+			return err
+		}
+		sb.WriteString(" IN (")
+		for j := 1; j < len(ops); j++ {
+			if j > 1 {
+				sb.WriteString(", ")
+			}
+			if err := operand(j, precBitwise); err != nil {
+				return err
+			}
+		}
+		sb.WriteString(")")
+		return nil
+
+	case FilterOperatorOfType:
+		if err := nargs(1); err != nil {
+			return err
+		}
+		nid, ok := literalValue(ops[0]).(*NodeID)
+		if !ok {
+			return errors.Errorf("filter: OfType operand is not a node id")
+		}
+		sb.WriteString("OfType(" + nid.String() + ")")
+		return nil
+
+	default:
+		return errors.Errorf("filter: operator %s is not supported", op)
+	}
+}
+
+// operand writes the operand of the element at index parent.
+func (f *filterFormatter) operand(sb *strings.Builder, parent uint32, eo *ExtensionObject, minPrec int) error {
+	if eo == nil {
+		return errors.Errorf("filter: missing operand")
+	}
+	switch x := eo.Value.(type) {
+	case *ElementOperand, ElementOperand:
+		idx := elementIndex(x)
+		// only forward references prevent endless loops
+		if idx <= parent {
+			return errors.Errorf("filter: element %d refers to element %d", parent, idx)
+		}
+		return f.element(sb, idx, minPrec)
+	case *LiteralOperand, LiteralOperand:
+		return formatLiteral(sb, literalValue(eo))
+	case *SimpleAttributeOperand:
+		return formatField(sb, x)
+	case SimpleAttributeOperand:
+		return formatField(sb, &x)
+	default:
+		return errors.Errorf("filter: operand %T is not supported", eo.Value)
+	}
+}
+
+func elementIndex(v interface{}) uint32 {
+	if x, ok := v.(*ElementOperand); ok {
+		return x.Index
+	}
+	return v.(ElementOperand).Index
+}
+
+func literalValue(eo *ExtensionObject) interface{} {
+	var v *Variant
+	switch x := eo.Value.(type) {
+	case *LiteralOperand:
+		v = x.Value
+	case LiteralOperand:
+		v = x.Value
+	}
+	if v == nil {
+		return nil
+	}
+	return v.Value()
+}
+
+func formatField(sb *strings.Builder, op *SimpleAttributeOperand) error {
+	if op.AttributeID != AttributeIDValue || op.IndexRange != "" {
+		return errors.Errorf("filter: field %s with attribute %d or index range is not supported", EventFieldName(op), op.AttributeID)
+	}
+	name := EventFieldName(op)
+	if name == "" {
+		return errors.Errorf("filter: field without browse path is not supported")
+	}
+	if t := op.TypeDefinitionID; t != nil && !(t.Namespace() == 0 && t.IntID() == id.BaseEventType) {
+		sb.WriteString("[" + t.String() + "]")
+	}
+	sb.WriteString(name)
+	return nil
+}
+
+func formatLiteral(sb *strings.Builder, v interface{}) error {
+	switch x := v.(type) {
+	case string:
+		sb.WriteString("'" + strings.Replace(x, "'", "''", -1) + "'")
+	case bool:
+		if x {
+			sb.WriteString("TRUE")
+		} else {
+			sb.WriteString("FALSE")
+		}
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		if u, ok := x.(uint64); ok && u > math.MaxInt64 {
+			return errors.Errorf("filter: literal %d is out of range", u)
+		}
+		sb.WriteString(strconv.FormatInt(toInt64(x), 10))
+	case float32:
+		sb.WriteString(formatFloat(float64(x)))
+	case float64:
+		sb.WriteString(formatFloat(x))
+	case *NodeID:
+		sb.WriteString("NodeId(" + x.String() + ")")
+	default:
+		return errors.Errorf("filter: literal of type %T is not supported", v)
+	}
+	return nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch x := v.(type) {
+	case int8:
+		return int64(x)
+	case int16:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case int64:
+		return x
+	case uint8:
+		return int64(x)
+	case uint16:
+		return int64(x)
+	case uint32:
+		return int64(x)
+	case uint64:
+		return int64(x)
+	}
+	return 0
+}
+
+// formatFloat formats the number so that it is parsed as a float again.
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEn") {
+		s += ".0"
+	}
+	return s
+}
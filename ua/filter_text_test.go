@@ -0,0 +1,155 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"testing"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestParseFilterExpr(t *testing.T) {
+	alarmType := NewFourByteNodeID(0, id.AlarmConditionType)
+
+	tests := []struct {
+		s    string
+		want *FilterExpr
+	}{
+		{
+			s: "OfType(ns=0;i=2915) AND Severity >= 500 AND SourceName LIKE 'Pump%'",
+			want: And(
+				OfType(alarmType),
+				GreaterThanOrEqual(EventField("Severity"), int32(500)),
+				Like(EventField("SourceName"), "Pump%"),
+			),
+		},
+		{
+			s: "severity > 100 and (Message = 'it''s' or not ActiveState/Id = true)",
+			want: And(
+				GreaterThan(EventField("severity"), int32(100)),
+				Or(
+					Equals(EventField("Message"), "it's"),
+					Not(Equals(EventField("ActiveState/Id"), true)),
+				),
+			),
+		},
+		{
+			s:    "Severity BETWEEN 100 AND 500 AND 2:Custom IN (1, 2.5, -3)",
+			want: And(Between(EventField("Severity"), int32(100), int32(500)), InList(EventField("2:Custom"), int32(1), 2.5, int32(-3))),
+		},
+		{
+			s:    "[ns=0;i=2915]Quality IS NOT NULL",
+			want: Not(IsNull(EventFieldOf(alarmType, "Quality"))),
+		},
+		{
+			s:    "Flags & 4 != 0",
+			want: Not(Equals(BitwiseAnd(EventField("Flags"), int32(4)), int32(0))),
+		},
+		{
+			s:    "SourceNode = NodeId(ns=2;s=Pump1) OR Time > 5000000000",
+			want: Or(Equals(EventField("SourceNode"), NewStringNodeID(2, "Pump1")), GreaterThan(EventField("Time"), int64(5000000000))),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseFilterExpr(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "", got, tt.want)
+		})
+	}
+}
+
+func TestParseFilterExprErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"Severity",
+		"Severity >",
+		"Severity > 'abc",
+		"Severity ! 1",
+		"(Severity > 1",
+		"Severity > 1 Message",
+		"Severity BETWEEN 1 OR 2",
+		"OfType(i=abc)",
+		"AND Severity > 1",
+	} {
+		if _, err := ParseFilterExpr(s); err == nil {
+			t.Errorf("%q: got nil want error", s)
+		}
+	}
+}
+
+func TestFormatContentFilter(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{
+			in:  "OfType(ns=0;i=2915) AND Severity >= 500 AND SourceName LIKE 'Pump%'",
+			out: "OfType(i=2915) AND Severity >= 500 AND SourceName LIKE 'Pump%'",
+		},
+		{
+			in:  "(a = 1 OR b = 2) AND NOT (c = 'it''s' AND d IS NULL)",
+			out: "(a = 1 OR b = 2) AND NOT (c = 'it''s' AND d IS NULL)",
+		},
+		{
+			in:  "x BETWEEN 1.5 AND 2.0 OR y IN (TRUE, FALSE) OR z != NodeId(ns=2;s=a)",
+			out: "x BETWEEN 1.5 AND 2.0 OR y IN (TRUE, FALSE) OR NOT z = NodeId(ns=2;s=a)",
+		},
+		{
+			in:  "[ns=0;i=2915]ActiveState/Id = TRUE AND 2:Flags & 3 | 4 > 0",
+			out: "[i=2915]ActiveState/Id = TRUE AND 2:Flags & 3 | 4 > 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			cf, err := ParseContentFilter(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := FormatContentFilter(cf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.out {
+				t.Fatalf("got %q want %q", got, tt.out)
+			}
+
+			// the formatted filter must result in the same content filter
+			cf2, err := ParseContentFilter(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "", cf2, cf)
+		})
+	}
+}
+
+func TestFormatContentFilterErrors(t *testing.T) {
+	loop := &ContentFilter{
+		Elements: []*ContentFilterElement{
+			{
+				FilterOperator: FilterOperatorNot,
+				FilterOperands: []*ExtensionObject{NewExtensionObject(&ElementOperand{Index: 0})},
+			},
+		},
+	}
+	if _, err := FormatContentFilter(loop); err == nil {
+		t.Error("got nil want error for element loop")
+	}
+
+	attr := &ContentFilter{
+		Elements: []*ContentFilterElement{
+			{
+				FilterOperator: FilterOperatorIsNull,
+				FilterOperands: []*ExtensionObject{NewExtensionObject(&AttributeOperand{})},
+			},
+		},
+	}
+	if _, err := FormatContentFilter(attr); err == nil {
+		t.Error("got nil want error for attribute operand")
+	}
+}
@@ -165,7 +165,8 @@ func ParseNodeID(s string) (*NodeID, error) {
 		}
 		return n, nil
 
-	case strings.HasPrefix(idval, "b="):
+	// String() uses the 'o=' prefix for opaque node ids
+	case strings.HasPrefix(idval, "b="), strings.HasPrefix(idval, "o="):
 		b, err := base64.StdEncoding.DecodeString(idval[2:])
 		if err != nil {
 			return nil, errors.Errorf("invalid opaque node id: %s", s)
@@ -9,7 +9,9 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/gopcua/opcua/debug"
 	"github.com/gopcua/opcua/errors"
@@ -167,6 +169,171 @@ func (l *Listener) Endpoint() string {
 	return l.endpoint
 }
 
+// ReverseListener accepts connections which are initiated by servers
+// with a ReverseHello message. This allows clients to connect to servers
+// which are behind a firewall that only permits outgoing connections.
+//
+// Specification: Part 6, 7.1.3
+type ReverseListener struct {
+	l   *net.TCPListener
+	ack *Acknowledge
+}
+
+// ListenReverse listens for reverse connections from servers on the given
+// address. The address can be specified either in "opc.tcp://<addr[:port]>"
+// or in "<addr:port>" format. ack defines the connection parameters which
+// the client requests during the HEL/ACK handshake and defaults to
+// DefaultClientACK.
+func ListenReverse(addr string, ack *Acknowledge) (*ReverseListener, error) {
+	if ack == nil {
+		ack = DefaultClientACK
+	}
+	network, laddr := "tcp", (*net.TCPAddr)(nil)
+	var err error
+	if strings.HasPrefix(addr, "opc.tcp://") {
+		network, laddr, err = ResolveEndpoint(addr)
+	} else {
+		laddr, err = net.ResolveTCPAddr(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.ListenTCP(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	return &ReverseListener{l: l, ack: ack}, nil
+}
+
+// Accept waits for the next server to connect and reads its ReverseHello
+// message. The caller must complete the connection by calling Handshake
+// with the EndpointURL of the ReverseHello message.
+//
+// Cancelling the context or reaching its deadline aborts the call.
+func (l *ReverseListener) Accept(ctx context.Context) (*Conn, *ReverseHello, error) {
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			_ = l.l.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	defer func() {
+		// stop the goroutine before the deadline is reset so that a
+		// late cancellation does not leave an expired deadline for the
+		// next call.
+		close(done)
+		<-stopped
+		_ = l.l.SetDeadline(time.Time{})
+	}()
+
+	c, err := l.l.AcceptTCP()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+
+	conn, err := NewConn(c, l.ack)
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(dl)
+	}
+	b, err := conn.Receive()
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	msgtyp := string(b[:4])
+	if msgtyp != "RHEF" {
+		conn.SendError(ua.StatusBadTCPMessageTypeInvalid)
+		conn.Close()
+		return nil, nil, errors.Errorf("uacp: invalid reverse hello packet %q", msgtyp)
+	}
+
+	rhe := new(ReverseHello)
+	if _, err := rhe.Decode(b[hdrlen:]); err != nil {
+		conn.SendError(ua.StatusBadTCPInternalError)
+		conn.Close()
+		return nil, nil, errors.Errorf("uacp: decode RHE failed: %s", err)
+	}
+	debug.Printf("conn %d: recv %#v", conn.id, rhe)
+	return conn, rhe, nil
+}
+
+// Close closes the ReverseListener.
+func (l *ReverseListener) Close() error {
+	return l.l.Close()
+}
+
+// Addr returns the listener's network address.
+func (l *ReverseListener) Addr() net.Addr {
+	return l.l.Addr()
+}
+
+// DialReverse establishes a reverse connection from a server to a client
+// which is listening on addr. It sends a ReverseHello message with the
+// serverURI and the endpoint and waits for the client to continue with
+// the HEL/ACK handshake for the endpoint. ack defines the connection
+// parameters of the server and defaults to DefaultServerACK.
+//
+// Specification: Part 6, 7.1.3
+func DialReverse(ctx context.Context, addr, serverURI, endpoint string, ack *Acknowledge) (*Conn, error) {
+	if ack == nil {
+		ack = DefaultServerACK
+	}
+	if strings.HasPrefix(addr, "opc.tcp://") {
+		_, raddr, err := ResolveEndpoint(addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = raddr.String()
+	}
+
+	debug.Printf("Reverse connecting to %s", addr)
+	var dl net.Dialer
+	c, err := dl.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := NewConn(c.(*net.TCPConn), ack)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	rhe := &ReverseHello{
+		ServerURI:   serverURI,
+		EndpointURL: endpoint,
+	}
+	if err := conn.Send("RHEF", rhe); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(dl)
+	}
+	debug.Printf("conn %d: start HEL/ACK handshake", conn.id)
+	if err := conn.srvhandshake(endpoint); err != nil {
+		debug.Printf("conn %d: HEL/ACK handshake failed: %s", conn.id, err)
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	return conn, nil
+}
+
 type Conn struct {
 	*net.TCPConn
 	id  uint32
@@ -183,3 +183,40 @@ NEXT:
 	got = got[:n]
 	verify.Values(t, "", got, want)
 }
+
+func TestReverseConn(t *testing.T) {
+	ln, err := ListenReverse("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ep := "opc.tcp://127.0.0.1:4840/foo/bar"
+	srvErr := make(chan error, 1)
+	go func() {
+		c, err := DialReverse(ctx, ln.Addr().String(), "urn:foo", ep, nil)
+		if err != nil {
+			srvErr <- err
+			return
+		}
+		defer c.Close()
+		srvErr <- nil
+	}()
+
+	c, rhe, err := ln.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	verify.Values(t, "", rhe, &ReverseHello{ServerURI: "urn:foo", EndpointURL: ep})
+
+	if err := c.Handshake(rhe.EndpointURL); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-srvErr; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -65,6 +65,22 @@ type Config struct {
 	// ignored if AutoReconnect is set to false.
 	ReconnectInterval time.Duration
 
+	// KeepAliveInterval is the interval in which the client reads the
+	// server state to detect a dead server or a half-open connection while
+	// it is idle. 0 disables the keep-alive.
+	KeepAliveInterval time.Duration
+
+	// KeepAliveMaxMisses is the number of consecutive failed keep-alive
+	// reads after which the client considers the connection lost and
+	// starts to reconnect. Ignored if KeepAliveInterval is 0.
+	KeepAliveMaxMisses int
+
+	// ReconnectPolicy decides whether and when the client tries to
+	// reconnect after the connection has been lost. If it is nil the client
+	// retries every ReconnectInterval until it succeeds. Ignored if
+	// AutoReconnect is set to false.
+	ReconnectPolicy ReconnectPolicy
+
 	// Lifetime is the requested lifetime, in milliseconds, for the new SecurityToken when the
 	// SecureChannel works as client. It specifies when the Client expects to renew the SecureChannel
 	// by calling the OpenSecureChannel Service again. If a SecureChannel is not renewed, then all
@@ -76,6 +92,45 @@ type Config struct {
 	// RequestTimeout is timeout duration for all synchronous requests over SecureChannel.
 	// If the Server doesn't respond within RequestTimeout time, Client returns StatusBadTimeout
 	RequestTimeout time.Duration
+
+	// MaxInflightRequests limits the number of requests which are sent over the
+	// SecureChannel but have not yet received a response. Publish requests are
+	// not counted since the server holds them until it has data to send.
+	// 0 means no limit.
+	MaxInflightRequests int
+
+	// MaxInflightRequestsPerService limits the number of requests in flight per
+	// service. The key is the type id of the request as returned by
+	// ua.ServiceTypeID, e.g. id.BrowseRequest_Encoding_DefaultBinary.
+	MaxInflightRequestsPerService map[uint16]int
+
+	// InflightFailFast makes requests which exceed one of the in-flight limits
+	// fail immediately with StatusBadTooManyOperations. Otherwise, they wait
+	// for a free slot up to their timeout.
+	InflightFailFast bool
+
+	// AutoTune makes the client read the ServerCapabilities after it has
+	// connected and adjust itself to the limits of the server.
+	AutoTune bool
+
+	// MaxPublishRequests limits the number of publish requests the client
+	// keeps in flight. The client sends one more publish request than it
+	// has subscriptions up to this limit. Values less than 1 are treated
+	// as 1.
+	MaxPublishRequests int
+}
+
+// ReconnectPolicy decides whether and when a client retries to establish
+// a lost connection.
+type ReconnectPolicy interface {
+	// Retry is called when the connection has been lost and after every
+	// failed reconnect attempt. attempt is the number of failed attempts so
+	// far and is 0 for the initial error. elapsed is the time since the
+	// connection was lost and err is the error of the last attempt.
+	//
+	// Retry returns the delay before the next attempt or false if the
+	// client should give up.
+	Retry(attempt int, elapsed time.Duration, err error) (time.Duration, bool)
 }
 
 // SessionConfig is a set of common configurations used in Session.
@@ -0,0 +1,134 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uasc
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// inflightLimiter limits the number of concurrent requests on
+// a secure channel, both in total and per service.
+type inflightLimiter struct {
+	mu       sync.Mutex
+	max      int
+	perSvc   map[uint16]int
+	n        int
+	nSvc     map[uint16]int
+	failFast bool
+
+	// wake is closed and replaced whenever a slot is released
+	// or the limits change.
+	wake chan struct{}
+}
+
+func newInflightLimiter(cfg *Config) *inflightLimiter {
+	l := &inflightLimiter{
+		nSvc: make(map[uint16]int),
+		wake: make(chan struct{}),
+	}
+	l.setLimits(cfg.MaxInflightRequests, cfg.MaxInflightRequestsPerService, cfg.InflightFailFast)
+	return l
+}
+
+// setLimits updates the limits. Requests which are already in
+// flight are not affected.
+func (l *inflightLimiter) setLimits(max int, perSvc map[uint16]int, failFast bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.max = max
+	l.perSvc = make(map[uint16]int, len(perSvc))
+	for k, v := range perSvc {
+		l.perSvc[k] = v
+	}
+	l.failFast = failFast
+	l.signal()
+}
+
+// acquire reserves a slot for a request of the given service type. It
+// waits up to timeout for a free slot unless the limiter is configured
+// to fail fast and returns the part of the timeout which is left for the
+// request. The caller must call release when the request is done.
+func (l *inflightLimiter) acquire(svc uint16, timeout time.Duration, done <-chan struct{}) (time.Duration, error) {
+	var (
+		start = time.Now()
+		timer *time.Timer
+	)
+	for {
+		l.mu.Lock()
+		if l.fits(svc) {
+			if !parked(svc) {
+				l.n++
+			}
+			l.nSvc[svc]++
+			l.mu.Unlock()
+			if timer != nil {
+				timer.Stop()
+			}
+			return timeout - time.Since(start), nil
+		}
+		if l.failFast {
+			l.mu.Unlock()
+			return 0, ua.StatusBadTooManyOperations
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(timeout)
+			defer timer.Stop()
+		}
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			return 0, ua.StatusBadTimeout
+		case <-done:
+			return 0, io.EOF
+		}
+	}
+}
+
+// release frees the slot of a request of the given service type.
+func (l *inflightLimiter) release(svc uint16) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !parked(svc) {
+		l.n--
+	}
+	l.nSvc[svc]--
+	l.signal()
+}
+
+// fits returns true if another request of the given service type
+// can be sent. The caller must hold the lock.
+func (l *inflightLimiter) fits(svc uint16) bool {
+	if l.max > 0 && l.n >= l.max && !parked(svc) {
+		return false
+	}
+	if max, ok := l.perSvc[svc]; ok && max > 0 && l.nSvc[svc] >= max {
+		return false
+	}
+	return true
+}
+
+// parked returns true for requests which the server holds until it has
+// data to send. They are only subject to the service limit since they
+// would otherwise block the channel for other requests.
+func parked(svc uint16) bool {
+	return svc == id.PublishRequest_Encoding_DefaultBinary
+}
+
+// signal wakes up all waiting requests. The caller must hold the lock.
+func (l *inflightLimiter) signal() {
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
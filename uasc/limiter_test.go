@@ -0,0 +1,105 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uasc
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestInflightLimiter(t *testing.T) {
+	const (
+		read    = id.ReadRequest_Encoding_DefaultBinary
+		browse  = id.BrowseRequest_Encoding_DefaultBinary
+		publish = id.PublishRequest_Encoding_DefaultBinary
+	)
+
+	t.Run("fail fast", func(t *testing.T) {
+		l := newInflightLimiter(&Config{
+			MaxInflightRequests:           2,
+			MaxInflightRequestsPerService: map[uint16]int{browse: 1},
+			InflightFailFast:              true,
+		})
+		if _, err := l.acquire(browse, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.acquire(browse, time.Second, nil); err != ua.StatusBadTooManyOperations {
+			t.Fatalf("got %v want %v", err, ua.StatusBadTooManyOperations)
+		}
+		if _, err := l.acquire(read, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.acquire(read, time.Second, nil); err != ua.StatusBadTooManyOperations {
+			t.Fatalf("got %v want %v", err, ua.StatusBadTooManyOperations)
+		}
+		// publish requests are not subject to the channel limit
+		if _, err := l.acquire(publish, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+		l.release(browse)
+		if _, err := l.acquire(read, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		l := newInflightLimiter(&Config{MaxInflightRequests: 1})
+		if _, err := l.acquire(read, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			l.release(read)
+		}()
+		left, err := l.acquire(read, time.Second, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the wait counts against the timeout
+		if left > time.Second-10*time.Millisecond {
+			t.Fatalf("got %v left want at most %v", left, time.Second-10*time.Millisecond)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		l := newInflightLimiter(&Config{MaxInflightRequests: 1})
+		if _, err := l.acquire(read, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.acquire(read, 10*time.Millisecond, nil); err != ua.StatusBadTimeout {
+			t.Fatalf("got %v want %v", err, ua.StatusBadTimeout)
+		}
+	})
+
+	t.Run("disconnected", func(t *testing.T) {
+		l := newInflightLimiter(&Config{MaxInflightRequests: 1})
+		if _, err := l.acquire(read, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan struct{})
+		close(done)
+		if _, err := l.acquire(read, time.Second, done); err != io.EOF {
+			t.Fatalf("got %v want %v", err, io.EOF)
+		}
+	})
+
+	t.Run("set limits", func(t *testing.T) {
+		l := newInflightLimiter(&Config{MaxInflightRequests: 1})
+		if _, err := l.acquire(read, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			l.setLimits(2, nil, false)
+		}()
+		if _, err := l.acquire(read, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -121,6 +121,9 @@ type SecureChannel struct {
 
 	// errorCh receive dispatcher errors
 	errCh chan<- error
+
+	// limiter limits the number of requests in flight
+	limiter *inflightLimiter
 }
 
 func NewSecureChannel(endpoint string, c *uacp.Conn, cfg *Config, errCh chan<- error) (*SecureChannel, error) {
@@ -155,6 +158,7 @@ func NewSecureChannel(endpoint string, c *uacp.Conn, cfg *Config, errCh chan<- e
 		reqLocker:   newConditionLocker(),
 		rcvLocker:   newConditionLocker(),
 		errCh:       errCh,
+		limiter:     newInflightLimiter(cfg),
 	}
 	s.reset()
 
@@ -751,6 +755,25 @@ func (s *SecureChannel) SendRequest(req ua.Request, authToken *ua.NodeID, h func
 }
 
 func (s *SecureChannel) SendRequestWithTimeout(req ua.Request, authToken *ua.NodeID, timeout time.Duration, h func(interface{}) error) error {
+	// requests without a response handler are not tracked
+	if h != nil {
+		svc := ua.ServiceTypeID(req)
+		s.closingMu.RLock()
+		disconnected := s.disconnected
+		s.closingMu.RUnlock()
+		left, err := s.limiter.acquire(svc, timeout, disconnected)
+		if err != nil {
+			return err
+		}
+		defer s.limiter.release(svc)
+
+		// the time spent waiting for a slot counts against the timeout
+		if left <= 0 {
+			return ua.StatusBadTimeout
+		}
+		timeout = left
+	}
+
 	s.reqLocker.waitIfLock()
 	active, err := s.getActiveChannelInstance()
 	if err != nil {
@@ -760,6 +783,12 @@ func (s *SecureChannel) SendRequestWithTimeout(req ua.Request, authToken *ua.Nod
 	return s.sendRequestWithTimeout(req, s.nextRequestID(), active, authToken, timeout, h)
 }
 
+// SetInflightLimits changes the limits for the number of requests in flight.
+// See Config.MaxInflightRequests for details.
+func (s *SecureChannel) SetInflightLimits(max int, perService map[uint16]int, failFast bool) {
+	s.limiter.setLimits(max, perService, failFast)
+}
+
 func (s *SecureChannel) sendAsyncWithTimeout(
 	req ua.Request,
 	reqID uint32,
@@ -0,0 +1,30 @@
+// +build integration
+
+package uatest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+)
+
+// TestFindServers performs an integration test to find
+// the servers known to an OPC/UA server.
+func TestFindServers(t *testing.T) {
+	srv := NewServer("rw_server.py")
+	defer srv.Close()
+
+	servers, err := opcua.FindServers(context.Background(), srv.Endpoint, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) == 0 {
+		t.Fatal("got no servers")
+	}
+	for _, s := range servers {
+		if s.ApplicationURI == "" {
+			t.Fatalf("server without application uri: %#v", s)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package wal
+
+import (
+	"encoding/binary"
+
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Store appends the notification message of the subscription to the log.
+// It implements opcua.NotificationStore.
+//
+// The records are keyed by the subscription id of the server which
+// changes when the client recreates the subscription. Consumers which
+// need a stable key have to follow the opcua.RecreatedEvent of the
+// subscription.
+func (l *Log) Store(subID uint32, msg *ua.NotificationMessage) error {
+	b, err := ua.Encode(msg)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, 4+len(b))
+	binary.LittleEndian.PutUint32(data, subID)
+	copy(data[4:], b)
+	_, err = l.Append(data)
+	return err
+}
+
+// DecodeNotification decodes a record which has been written with Store.
+func DecodeNotification(data []byte) (uint32, *ua.NotificationMessage, error) {
+	if len(data) < 4 {
+		return 0, nil, errors.Errorf("wal: notification record too short")
+	}
+	subID := binary.LittleEndian.Uint32(data)
+	msg := new(ua.NotificationMessage)
+	if _, err := ua.Decode(data[4:], msg); err != nil {
+		return 0, nil, err
+	}
+	return subID, msg, nil
+}
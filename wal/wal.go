@@ -0,0 +1,543 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+// Package wal implements a write-ahead log of segment files which
+// buffers subscription notifications on disk until the consumers
+// have committed them.
+//
+// The log implements opcua.NotificationStore:
+//
+//	l, err := wal.Open("/var/lib/opcua/wal")
+//	...
+//	sub, err := c.Subscribe(params, nil)
+//	sub.SetStore(l)
+//
+// A consumer reads the notifications from its last committed offset
+// and commits them once they have been processed:
+//
+//	r, err := l.NewReader("database")
+//	for {
+//		off, data, err := r.Next(ctx)
+//		...
+//		subID, msg, err := wal.DecodeNotification(data)
+//		...
+//		r.Commit(off)
+//	}
+//
+// Segments are removed when all of their records have been committed
+// by all consumers. A consumer is registered when its first reader is
+// created and the log keeps its records from then on.
+package wal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gopcua/opcua/errors"
+)
+
+// DefaultSegmentSize is the size after which a new segment file is started.
+const DefaultSegmentSize = 64 << 20
+
+const (
+	segmentExt = ".seg"
+	commitExt  = ".commit"
+
+	// headerLen is the length of the record header which contains
+	// the length and the CRC-32C checksum of the data.
+	headerLen = 8
+)
+
+// ErrClosed is returned when the log has been closed.
+var ErrClosed = errors.New("wal: log closed")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Option configures the log.
+type Option func(*Log)
+
+// SegmentSize sets the size after which a new segment file is started.
+func SegmentSize(n int64) Option {
+	return func(l *Log) {
+		l.segmentSize = n
+	}
+}
+
+// Log is a write-ahead log of records which are addressed by their
+// offset. The offset of the first record is 0.
+type Log struct {
+	dir         string
+	segmentSize int64
+
+	mu       sync.Mutex
+	segments []uint64 // base offsets of the segments in ascending order
+	f        *os.File // active segment
+	size     int64    // size of the active segment
+	next     uint64   // offset of the next record
+	commits  map[string]uint64
+	notify   chan struct{}
+	closed   bool
+	err      error // set when the active segment could not be repaired
+}
+
+// Open opens the log in dir and creates the directory if necessary.
+// A partially written record at the end of the log is removed.
+func Open(dir string, opts ...Option) (*Log, error) {
+	l := &Log{
+		dir:         dir,
+		segmentSize: DefaultSegmentSize,
+		commits:     make(map[string]uint64),
+		notify:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Errorf("wal: %s", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Errorf("wal: %s", err)
+	}
+	for _, fi := range files {
+		name := fi.Name()
+		switch filepath.Ext(name) {
+		case segmentExt:
+			base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("wal: invalid segment file %s", name)
+			}
+			l.segments = append(l.segments, base)
+		case commitExt:
+			off, err := readCommit(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			l.commits[strings.TrimSuffix(name, commitExt)] = off
+		}
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i] < l.segments[j] })
+
+	if len(l.segments) == 0 {
+		if err := l.createSegment(0); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	if err := l.recover(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// recover opens the last segment for writing and truncates it
+// after the last complete record.
+func (l *Log) recover() error {
+	base := l.segments[len(l.segments)-1]
+	f, err := os.OpenFile(l.segmentPath(base), os.O_RDWR, 0644)
+	if err != nil {
+		return errors.Errorf("wal: %s", err)
+	}
+
+	var n uint64
+	var size int64
+	r := bufio.NewReader(f)
+	for {
+		data, err := readRecord(r)
+		if err != nil {
+			break
+		}
+		n++
+		size += headerLen + int64(len(data))
+	}
+
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return errors.Errorf("wal: %s", err)
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return errors.Errorf("wal: %s", err)
+	}
+	l.f = f
+	l.size = size
+	l.next = base + n
+	return nil
+}
+
+func (l *Log) segmentPath(base uint64) string {
+	return filepath.Join(l.dir, strconv.FormatUint(base, 10)+segmentExt)
+}
+
+// createSegment starts a new segment with the base offset.
+//
+// The caller must hold the mu lock or be the only user of the log.
+func (l *Log) createSegment(base uint64) error {
+	f, err := os.OpenFile(l.segmentPath(base), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.Errorf("wal: %s", err)
+	}
+	if err := syncDir(l.dir); err != nil {
+		f.Close()
+		return err
+	}
+	if l.f != nil {
+		l.f.Close()
+	}
+	l.f = f
+	l.size = 0
+	l.next = base
+	l.segments = append(l.segments, base)
+	return nil
+}
+
+// Append writes the records to the log and returns the offset of the
+// first record. The records have been synced to disk when Append returns.
+func (l *Log) Append(data ...[]byte) (uint64, error) {
+	var buf []byte
+	for _, d := range data {
+		var h [headerLen]byte
+		binary.LittleEndian.PutUint32(h[:4], uint32(len(d)))
+		binary.LittleEndian.PutUint32(h[4:], crc32.Checksum(d, crcTable))
+		buf = append(buf, h[:]...)
+		buf = append(buf, d...)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return 0, ErrClosed
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	if l.size > 0 && l.size+int64(len(buf)) > l.segmentSize {
+		if err := l.createSegment(l.next); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := l.f.Write(buf); err != nil {
+		return 0, l.rollback(err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return 0, l.rollback(err)
+	}
+
+	first := l.next
+	l.size += int64(len(buf))
+	l.next += uint64(len(data))
+
+	// wake up the readers
+	close(l.notify)
+	l.notify = make(chan struct{})
+
+	return first, nil
+}
+
+// rollback removes a partially written or unsynced append so that the
+// next append does not write after a broken record. If the segment
+// cannot be repaired the log fails all further appends.
+//
+// The caller must hold the mu lock.
+func (l *Log) rollback(err error) error {
+	if terr := l.f.Truncate(l.size); terr != nil {
+		l.err = errors.Errorf("wal: log failed: %s after %s", terr, err)
+		return l.err
+	}
+	if _, serr := l.f.Seek(l.size, io.SeekStart); serr != nil {
+		l.err = errors.Errorf("wal: log failed: %s after %s", serr, err)
+		return l.err
+	}
+	return errors.Errorf("wal: %s", err)
+}
+
+// Commit records that the consumer has processed all records up to
+// and including off and removes the segments which are no longer
+// needed by any consumer.
+func (l *Log) Commit(consumer string, off uint64) error {
+	if err := validConsumer(consumer); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	if off >= l.next {
+		return errors.Errorf("wal: commit of offset %d beyond end of log %d", off, l.next)
+	}
+
+	path := filepath.Join(l.dir, consumer+commitExt)
+	if err := writeCommit(path, off+1); err != nil {
+		return err
+	}
+	l.commits[consumer] = off + 1
+	return l.removeSegments()
+}
+
+// Committed returns the offset of the next record for the consumer.
+func (l *Log) Committed(consumer string) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.start(consumer)
+}
+
+// start returns the offset at which the consumer continues.
+//
+// The caller must hold the mu lock.
+func (l *Log) start(consumer string) uint64 {
+	off := l.commits[consumer]
+	if first := l.segments[0]; off < first {
+		off = first
+	}
+	return off
+}
+
+// removeSegments removes the segments with records which all
+// consumers have committed. The active segment is never removed.
+//
+// The caller must hold the mu lock.
+func (l *Log) removeSegments() error {
+	min := l.next
+	for _, off := range l.commits {
+		if off < min {
+			min = off
+		}
+	}
+
+	n := 0
+	for n < len(l.segments)-1 && l.segments[n+1] <= min {
+		if err := os.Remove(l.segmentPath(l.segments[n])); err != nil && !os.IsNotExist(err) {
+			return errors.Errorf("wal: %s", err)
+		}
+		n++
+	}
+	l.segments = l.segments[n:]
+	return nil
+}
+
+// Close closes the log. Readers return ErrClosed.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	close(l.notify)
+	return l.f.Close()
+}
+
+// Reader reads the records of the log for a consumer.
+type Reader struct {
+	l        *Log
+	consumer string
+	off      uint64 // offset of the next record
+	f        *os.File
+	r        *bufio.Reader
+}
+
+// NewReader returns a reader which starts after the last
+// record which the consumer has committed. A new consumer starts at
+// the oldest record in the log and is registered with a commit file
+// so that its records are kept until it commits them.
+func (l *Log) NewReader(consumer string) (*Reader, error) {
+	if err := validConsumer(consumer); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil, ErrClosed
+	}
+	off := l.start(consumer)
+	if _, ok := l.commits[consumer]; !ok {
+		if err := writeCommit(filepath.Join(l.dir, consumer+commitExt), off); err != nil {
+			return nil, err
+		}
+		l.commits[consumer] = off
+	}
+	return &Reader{l: l, consumer: consumer, off: off}, nil
+}
+
+// Next returns the next record and its offset. It blocks until a
+// record is available, the context is done or the log is closed.
+func (r *Reader) Next(ctx context.Context) (uint64, []byte, error) {
+	for {
+		r.l.mu.Lock()
+		next, notify, closed := r.l.next, r.l.notify, r.l.closed
+		r.l.mu.Unlock()
+
+		if closed {
+			return 0, nil, ErrClosed
+		}
+		if r.off < next {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		case <-notify:
+		}
+	}
+
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, nil, err
+		}
+	}
+	data, err := readRecord(r.r)
+	if err == io.EOF {
+		// continue with the next segment
+		r.f.Close()
+		if err := r.open(); err != nil {
+			return 0, nil, err
+		}
+		data, err = readRecord(r.r)
+	}
+	if err != nil {
+		return 0, nil, errors.Errorf("wal: read offset %d: %s", r.off, err)
+	}
+
+	off := r.off
+	r.off++
+	return off, data, nil
+}
+
+// open opens the segment with the next record and skips the
+// records before it.
+func (r *Reader) open() error {
+	r.l.mu.Lock()
+	segments := r.l.segments
+	r.l.mu.Unlock()
+
+	i := sort.Search(len(segments), func(i int) bool { return segments[i] > r.off }) - 1
+	if i < 0 {
+		return errors.Errorf("wal: offset %d has been removed", r.off)
+	}
+	base := segments[i]
+
+	f, err := os.Open(r.l.segmentPath(base))
+	if err != nil {
+		return errors.Errorf("wal: %s", err)
+	}
+	r.f = f
+	r.r = bufio.NewReader(f)
+	for off := base; off < r.off; off++ {
+		if _, err := readRecord(r.r); err != nil {
+			return errors.Errorf("wal: skip to offset %d: %s", r.off, err)
+		}
+	}
+	return nil
+}
+
+// Commit records that the consumer has processed all
+// records up to and including off.
+func (r *Reader) Commit(off uint64) error {
+	return r.l.Commit(r.consumer, off)
+}
+
+// Close closes the reader.
+func (r *Reader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// readRecord reads the next record. It returns io.EOF at the end of
+// the segment and an error for a partial or corrupted record.
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	var h [headerLen]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.Errorf("wal: partial record header")
+		}
+		return nil, err
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(h[:4]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errors.Errorf("wal: partial record")
+	}
+	if crc32.Checksum(data, crcTable) != binary.LittleEndian.Uint32(h[4:]) {
+		return nil, errors.Errorf("wal: checksum mismatch")
+	}
+	return data, nil
+}
+
+func validConsumer(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return errors.Errorf("wal: invalid consumer name %q", name)
+	}
+	return nil
+}
+
+func readCommit(path string) (uint64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, errors.Errorf("wal: %s", err)
+	}
+	off, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("wal: invalid commit file %s", path)
+	}
+	return off, nil
+}
+
+// writeCommit replaces the commit file atomically.
+func writeCommit(path string, off uint64) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return errors.Errorf("wal: %s", err)
+	}
+	if _, err := f.WriteString(strconv.FormatUint(off, 10) + "\n"); err != nil {
+		f.Close()
+		return errors.Errorf("wal: %s", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Errorf("wal: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Errorf("wal: %s", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Errorf("wal: %s", err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir syncs the directory so that new and renamed files are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Errorf("wal: %s", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return errors.Errorf("wal: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package wal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+var _ opcua.NotificationStore = (*Log)(nil)
+
+func tempDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func readAll(t *testing.T, r *Reader, n int) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var got []string
+	for i := 0; i < n; i++ {
+		off, data, err := r.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%d:%s", off, data))
+	}
+	return got
+}
+
+func TestLog(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	l, err := Open(dir, SegmentSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := l.Append([]byte(fmt.Sprintf("rec%d", i)), []byte(fmt.Sprintf("rec%d", i+10))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := l.NewReader("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", readAll(t, r, 4), []string{"0:rec0", "1:rec10", "2:rec1", "3:rec11"})
+	if err := r.Commit(3); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	l.Close()
+
+	// resume after the last commit
+	l, err = Open(dir, SegmentSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	r, err = l.NewReader("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	verify.Values(t, "", readAll(t, r, 2), []string{"4:rec2", "5:rec12"})
+
+	// wait for new records
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.Append([]byte("new"))
+	}()
+	verify.Values(t, "", readAll(t, r, 5), []string{"6:rec3", "7:rec13", "8:rec4", "9:rec14", "10:new"})
+}
+
+func TestLogRemoveSegments(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	l, err := Open(dir, SegmentSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for i := 0; i < 4; i++ {
+		if _, err := l.Append([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Commit("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Commit("b", 2); err != nil {
+		t.Fatal(err)
+	}
+	segs, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if got, want := len(segs), 2; got != want {
+		t.Fatalf("got %d segments want %d", got, want)
+	}
+	if got, want := l.Committed("a"), uint64(2); got != want {
+		t.Fatalf("got committed offset %d want %d", got, want)
+	}
+	if got, want := l.Committed("new"), uint64(2); got != want {
+		t.Fatalf("got start offset %d for new consumer want %d", got, want)
+	}
+	if err := l.Commit("a", 4); err == nil {
+		t.Fatal("got nil want error for commit beyond end of log")
+	}
+}
+
+func TestLogNewReaderKeepsRecords(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	l, err := Open(dir, SegmentSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if _, err := l.Append([]byte("rec0")); err != nil {
+		t.Fatal(err)
+	}
+	r, err := l.NewReader("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for i := 1; i < 4; i++ {
+		if _, err := l.Append([]byte(fmt.Sprintf("rec%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the records of the new consumer are kept although it has not
+	// committed yet
+	if err := l.Commit("a", 3); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", readAll(t, r, 4), []string{"0:rec0", "1:rec1", "2:rec2", "3:rec3"})
+
+	// the consumer stays registered after a restart
+	l.Close()
+	l, err = Open(dir, SegmentSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if got, want := l.Committed("new"), uint64(0); got != want {
+		t.Fatalf("got start offset %d want %d", got, want)
+	}
+}
+
+func TestLogRecover(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append([]byte("one"), []byte("two"))
+	l.Close()
+
+	// simulate a crash during a write
+	f, err := os.OpenFile(filepath.Join(dir, "0"+segmentExt), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{10, 0, 0, 0, 1, 2})
+	f.Close()
+
+	l, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	off, err := l.Append([]byte("three"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off != 2 {
+		t.Fatalf("got offset %d want 2", off)
+	}
+	r, err := l.NewReader("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	verify.Values(t, "", readAll(t, r, 3), []string{"0:one", "1:two", "2:three"})
+}
+
+func TestNotification(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	msg := &ua.NotificationMessage{
+		SequenceNumber: 7,
+		PublishTime:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		NotificationData: []*ua.ExtensionObject{
+			ua.NewExtensionObject(&ua.DataChangeNotification{
+				MonitoredItems: []*ua.MonitoredItemNotification{
+					{ClientHandle: 1, Value: &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(1.5)}},
+				},
+				DiagnosticInfos: []*ua.DiagnosticInfo{},
+			}),
+		},
+	}
+	if err := l.Store(42, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := l.NewReader("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	_, data, err := r.Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	subID, got, err := DecodeNotification(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subID != 42 {
+		t.Fatalf("got sub id %d want 42", subID)
+	}
+	verify.Values(t, "", got, msg)
+}
+
+func TestLogAppendError(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Append([]byte("one")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the write fails and the segment cannot be truncated
+	l.f.Close()
+	if _, err := l.Append([]byte("two")); err == nil {
+		t.Fatal("got nil want error")
+	}
+	if l.err == nil {
+		t.Fatal("log not failed")
+	}
+	if _, err := l.Append([]byte("three")); err != l.err {
+		t.Fatalf("got %v want %v", err, l.err)
+	}
+	l.closed = true
+
+	// the records before the failure are intact
+	l, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if got, want := l.next, uint64(1); got != want {
+		t.Fatalf("got next offset %d want %d", got, want)
+	}
+}
+
+func TestLogRollback(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err := l.Append([]byte("one")); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a partial write
+	l.f.Write([]byte{10, 0, 0})
+	if err := l.rollback(io.ErrShortWrite); err == nil || l.err != nil {
+		t.Fatalf("got %v, %v want error and a working log", err, l.err)
+	}
+	if _, err := l.Append([]byte("two")); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := l.NewReader("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	verify.Values(t, "", readAll(t, r, 2), []string{"0:one", "1:two"})
+}